@@ -0,0 +1,49 @@
+// Package auth provides request authentication with API keys and JWTs.
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// Authentication methods of a Principal
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+// ErrNoCredentials is returned by an Authenticator when a request carries no credentials it understands
+var ErrNoCredentials = errors.New("no credentials")
+
+// ErrInvalidCredentials is wrapped by the errors an Authenticator returns for credentials that are
+// present but not valid, such as an unknown API key or an expired token
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	ID        string         // The stable ID of the caller, such as a user or service name
+	Method    string         // How the caller authenticated, MethodAPIKey or MethodJWT
+	Workspace string         // The workspace the credentials are bound to, empty when the caller picks one per request
+	Claims    map[string]any // The claims of the token for MethodJWT, nil otherwise
+}
+
+// Authenticator authenticates a request from one kind of credentials
+type Authenticator interface {
+	// Authenticate returns the principal of the request, ErrNoCredentials when the request has
+	// no credentials of this kind, or an error wrapping ErrInvalidCredentials when they are invalid
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// FromContext returns the principal of an authenticated request
+func FromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok && principal != nil
+}
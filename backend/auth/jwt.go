@@ -0,0 +1,264 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Supported signing algorithms
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+)
+
+// WorkspaceClaim is the token claim that binds a principal to a workspace
+const WorkspaceClaim = "workspace"
+
+// clockSkew is how far the clocks of the token issuer and this server may drift apart
+const clockSkew = time.Minute
+
+// jwk is a verification key from a JSON Web Key Set
+type jwk struct {
+	id     string
+	alg    string
+	secret []byte         // The shared secret of an HS256 key
+	public *rsa.PublicKey // The public key of an RS256 key
+}
+
+// JWTAuthenticator authenticates requests by a bearer JWT signed with HS256 or RS256 by one of
+// the keys of a local JWKS file. The token's sub claim becomes the principal ID and its workspace
+// claim, if any, the principal's workspace.
+type JWTAuthenticator struct {
+	keys     []jwk
+	issuer   string
+	audience string
+	now      func() time.Time
+}
+
+// NewJWTAuthenticator loads the keys of a JWKS file. When issuer or audience are not empty, tokens
+// must carry a matching iss or aud claim.
+func NewJWTAuthenticator(jwksPath, issuer, audience string) (*JWTAuthenticator, error) {
+	data, err := os.ReadFile(jwksPath)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", jwksPath, err)
+	}
+
+	return &JWTAuthenticator{keys: keys, issuer: issuer, audience: audience, now: time.Now}, nil
+}
+
+// parseJWKS reads the oct and RSA keys of a JSON Web Key Set. Keys of other types are rejected
+// rather than ignored, so a misconfigured set fails at startup.
+func parseJWKS(data []byte) ([]jwk, error) {
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Alg string `json:"alg"`
+			Use string `json:"use"`
+			K   string `json:"k"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+	if len(set.Keys) == 0 {
+		return nil, errors.New("JWKS has no keys")
+	}
+
+	keys := make([]jwk, 0, len(set.Keys))
+	for i, raw := range set.Keys {
+		if raw.Use != "" && raw.Use != "sig" {
+			return nil, fmt.Errorf("key %d: unsupported use %q", i, raw.Use)
+		}
+
+		key := jwk{id: raw.Kid}
+		switch raw.Kty {
+		case "oct":
+			secret, err := base64.RawURLEncoding.DecodeString(raw.K)
+			if err != nil || len(secret) < 32 {
+				return nil, fmt.Errorf("key %d: k must be a base64url encoded secret of at least 32 bytes", i)
+			}
+			key.alg, key.secret = AlgHS256, secret
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(raw.N)
+			e, errE := base64.RawURLEncoding.DecodeString(raw.E)
+			if errN != nil || errE != nil || len(n) == 0 || len(e) == 0 || len(e) > 4 {
+				return nil, fmt.Errorf("key %d: n and e must be base64url encoded", i)
+			}
+			public := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+			if public.N.BitLen() < 2048 {
+				return nil, fmt.Errorf("key %d: RSA keys must have at least 2048 bits", i)
+			}
+			key.alg, key.public = AlgRS256, public
+		default:
+			return nil, fmt.Errorf("key %d: unsupported kty %q", i, raw.Kty)
+		}
+
+		if raw.Alg != "" && raw.Alg != key.alg {
+			return nil, fmt.Errorf("key %d: unsupported alg %q for kty %s", i, raw.Alg, raw.Kty)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// Authenticate verifies the bearer token in the Authorization header
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+
+	claims, err := a.verify(strings.TrimSpace(token))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, fmt.Errorf("%w: token has no sub claim", ErrInvalidCredentials)
+	}
+
+	workspace, _ := claims[WorkspaceClaim].(string)
+	return &Principal{ID: subject, Method: MethodJWT, Workspace: workspace, Claims: claims}, nil
+}
+
+// verify checks the signature and the registered claims of a compact JWT and returns its claims
+func (a *JWTAuthenticator) verify(token string) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed header: %w", err)
+	}
+	if header.Alg != AlgHS256 && header.Alg != AlgRS256 {
+		return nil, fmt.Errorf("unsupported alg %q", header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed signature")
+	}
+
+	// Only keys of the token's algorithm are tried, so an RSA public key is never used as an HMAC secret
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := slices.ContainsFunc(a.keys, func(key jwk) bool {
+		if key.alg != header.Alg || (header.Kid != "" && key.id != header.Kid) {
+			return false
+		}
+		return key.verify(signed, signature)
+	})
+	if !verified {
+		return nil, errors.New("invalid signature")
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed claims: %w", err)
+	}
+	if err := a.checkClaims(claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// verify checks the signature of signed with the key
+func (key jwk) verify(signed, signature []byte) bool {
+	digest := sha256.Sum256(signed)
+	if key.public != nil {
+		return rsa.VerifyPKCS1v15(key.public, crypto.SHA256, digest[:], signature) == nil
+	}
+
+	mac := hmac.New(sha256.New, key.secret)
+	mac.Write(signed)
+	return hmac.Equal(mac.Sum(nil), signature)
+}
+
+// checkClaims validates the exp, nbf, iss and aud claims. Tokens without exp are rejected so a
+// leaked token cannot be used forever.
+func (a *JWTAuthenticator) checkClaims(claims map[string]any) error {
+	now := a.now()
+
+	exp, ok := numericDate(claims["exp"])
+	if !ok {
+		return errors.New("token has no exp claim")
+	}
+	if now.After(exp.Add(clockSkew)) {
+		return errors.New("token has expired")
+	}
+
+	if nbf, ok := numericDate(claims["nbf"]); ok && now.Add(clockSkew).Before(nbf) {
+		return errors.New("token is not valid yet")
+	}
+
+	if a.issuer != "" && claims["iss"] != a.issuer {
+		return errors.New("token has an unexpected issuer")
+	}
+
+	if a.audience != "" {
+		var audiences []any
+		switch aud := claims["aud"].(type) {
+		case string:
+			audiences = []any{aud}
+		case []any:
+			audiences = aud
+		}
+		if !slices.Contains(audiences, any(a.audience)) {
+			return errors.New("token has an unexpected audience")
+		}
+	}
+
+	return nil
+}
+
+// numericDate converts a NumericDate claim, which is decoded as a json.Number, to a time
+func numericDate(value any) (time.Time, bool) {
+	number, ok := value.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	seconds, err := number.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(seconds), 0), true
+}
+
+// decodeSegment decodes a base64url encoded JSON segment of a token, keeping numbers exact
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
@@ -0,0 +1,70 @@
+// Command apikey creates an API key for the Calculation API. The key is printed once; only its
+// hash is stored, so it cannot be recovered later.
+//
+// Usage:
+//
+//	go run ./cmd/apikey -name "ci pipeline" -principal ci [-workspace <id>]
+package main
+
+import (
+	"backend/auth"
+	"backend/config"
+	"backend/prisma/db"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	name := flag.String("name", "", "A description of what the key is used for")
+	principal := flag.String("principal", "", "The principal ID requests with the key authenticate as")
+	workspace := flag.String("workspace", "", "Optional workspace ID to bind the key to")
+	flag.Parse()
+
+	if *name == "" || *principal == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(*name, *principal, *workspace); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create API key: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(name, principal, workspace string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	client := db.NewClient(db.WithDatasourceURL(cfg.DatabaseURL))
+	if err := client.Prisma.Connect(); err != nil {
+		return err
+	}
+	defer client.Prisma.Disconnect()
+
+	key, err := auth.NewAPIKey()
+	if err != nil {
+		return err
+	}
+
+	var workspaceID *string
+	if workspace != "" {
+		workspaceID = &workspace
+	}
+
+	apiKey, err := client.APIKey.CreateOne(
+		db.APIKey.Name.Set(name),
+		db.APIKey.Principal.Set(principal),
+		db.APIKey.Hash.Set(auth.HashAPIKey(key)),
+		db.APIKey.WorkspaceID.SetIfPresent(workspaceID),
+	).Exec(context.Background())
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Created API key %s for %s:\n%s\n", apiKey.ID, principal, key)
+	return nil
+}
@@ -0,0 +1,119 @@
+// Command claim makes a principal the owner of every calculation, formular and node of a
+// workspace that has no owner, such as the records created before permissions were enforced.
+// Records without an owner are invisible to every caller of the API, and the server warns about
+// them at startup. The principal is made an owner of the workspace unless it is the default one,
+// which every principal may use, so that it can manage the members of workspaces created before
+// members had roles.
+//
+// Usage:
+//
+//	go run ./cmd/claim -principal alice
+//	go run ./cmd/claim -principal alice -workspace 123e4567-e89b-12d3-a456-426614174000
+package main
+
+import (
+	"backend/config"
+	"backend/handlers"
+	"backend/prisma/db"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	principal := flag.String("principal", "", "The principal ID to make the owner of unowned records")
+	workspace := flag.String("workspace", handlers.DefaultWorkspace, "The ID of the workspace whose records to claim")
+	flag.Parse()
+
+	if *principal == "" || *workspace == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(*principal, *workspace); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to claim records: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(principal, workspaceID string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	client := db.NewClient(db.WithDatasourceURL(cfg.DatabaseURL))
+	if err := client.Prisma.Connect(); err != nil {
+		return err
+	}
+	defer client.Prisma.Disconnect()
+
+	ctx := context.Background()
+
+	// Records created before workspaces existed are in the default workspace, which the server
+	// creates at startup
+	if err := handlers.EnsureDefaultWorkspace(ctx, client); err != nil {
+		return err
+	}
+	if _, err := client.Workspace.FindUnique(db.Workspace.ID.Equals(workspaceID)).Exec(ctx); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return fmt.Errorf("workspace %q not found", workspaceID)
+		}
+		return err
+	}
+
+	// Owning records of a workspace is of no use without being able to select it
+	if workspaceID != handlers.DefaultWorkspace {
+		if _, err := client.WorkspaceMember.UpsertOne(
+			db.WorkspaceMember.WorkspaceIDPrincipal(
+				db.WorkspaceMember.WorkspaceID.Equals(workspaceID),
+				db.WorkspaceMember.Principal.Equals(principal),
+			),
+		).Create(
+			db.WorkspaceMember.Workspace.Link(db.Workspace.ID.Equals(workspaceID)),
+			db.WorkspaceMember.Principal.Set(principal),
+			db.WorkspaceMember.Role.Set(handlers.MemberRoleOwner),
+		).Update(
+			db.WorkspaceMember.Role.Set(handlers.MemberRoleOwner),
+		).Exec(ctx); err != nil {
+			return err
+		}
+		fmt.Printf("Made %s an owner of workspace %s\n", principal, workspaceID)
+	}
+
+	unowned, err := handlers.UnownedIDs(ctx, client, &workspaceID)
+	if err != nil {
+		return err
+	}
+
+	for _, resource := range []string{handlers.ResourceCalculation, handlers.ResourceFormular, handlers.ResourceNode} {
+		var txs []db.PrismaTransaction
+		for _, id := range unowned[resource] {
+			// Replace a lesser role the principal may already have on the record
+			txs = append(txs,
+				client.Permission.FindMany(
+					db.Permission.Resource.Equals(resource),
+					db.Permission.ResourceID.Equals(id),
+					db.Permission.Principal.Equals(principal),
+				).Delete().Tx(),
+				client.Permission.CreateOne(
+					db.Permission.Resource.Set(resource),
+					db.Permission.ResourceID.Set(id),
+					db.Permission.Principal.Set(principal),
+					db.Permission.Role.Set(handlers.RoleOwner),
+				).Tx(),
+			)
+		}
+
+		if len(txs) > 0 {
+			if err := client.Prisma.Transaction(txs...).Exec(ctx); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("Claimed %d %s records in workspace %s for %s\n", len(txs)/2, resource, workspaceID, principal)
+	}
+
+	return nil
+}
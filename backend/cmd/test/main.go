@@ -39,6 +39,9 @@ func main() {
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	if apiKey := os.Getenv("API_KEY"); apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
 
 	// Send the request
 	client := &http.Client{}
@@ -0,0 +1,263 @@
+// Package config loads the server configuration. Values are read, in increasing order of
+// precedence, from the defaults, an optional YAML file named by CONFIG_FILE and the environment,
+// which may be populated from a .env file in the working directory.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the configuration of the server
+type Config struct {
+	Addr        string   `yaml:"addr"`        // The address to listen on, LISTEN_ADDR
+	DatabaseURL string   `yaml:"databaseUrl"` // The SQLite database, DATABASE_URL
+	CORSOrigins []string `yaml:"corsOrigins"` // The origins browsers may call the API from, "*" for any, CORS_ORIGINS as a comma separated list
+	AI          AI       `yaml:"ai"`
+	Auth        Auth     `yaml:"auth"`
+	Purge       Purge    `yaml:"purge"`
+	Timeouts    Timeouts `yaml:"timeouts"`
+}
+
+// AI configures the AI provider, an OpenRouter compatible chat completions API
+type AI struct {
+	APIKey  string `yaml:"apiKey"`  // The provider API key, OPENROUTER_API_KEY. AI requests fail while it is unset.
+	BaseURL string `yaml:"baseUrl"` // The base URL of the API, AI_BASE_URL
+	Model   string `yaml:"model"`   // The model of requests that do not name one, AI_MODEL
+	AppURL  string `yaml:"appUrl"`  // The URL the provider attributes requests to, APP_URL
+	AppName string `yaml:"appName"` // The name the provider attributes requests to, APP_NAME
+}
+
+// Configured reports whether AI requests can be made
+func (a AI) Configured() bool {
+	return a.APIKey != ""
+}
+
+// Auth configures JWT authentication. API keys are always accepted.
+type Auth struct {
+	JWKSFile string `yaml:"jwksFile"` // The JWKS with the keys JWTs are signed with, JWT_JWKS_FILE. JWTs are rejected while it is unset.
+	Issuer   string `yaml:"issuer"`   // The required iss claim, JWT_ISSUER
+	Audience string `yaml:"audience"` // The required aud claim, JWT_AUDIENCE
+}
+
+// Purge configures the permanent deletion of soft deleted records
+type Purge struct {
+	Retention time.Duration `yaml:"retention"` // How long soft deleted records are kept, PURGE_RETENTION
+	Interval  time.Duration `yaml:"interval"`  // How often expired records are purged, PURGE_INTERVAL
+}
+
+// Timeouts bounds how long requests and shutdown may take
+type Timeouts struct {
+	Request    time.Duration `yaml:"request"`    // How long an API request may take before it fails with a 504, REQUEST_TIMEOUT
+	AI         time.Duration `yaml:"ai"`         // How long a call to the AI provider may take, AI_TIMEOUT
+	ReadHeader time.Duration `yaml:"readHeader"` // How long a client may take to send the request headers, READ_HEADER_TIMEOUT
+	Read       time.Duration `yaml:"read"`       // How long a client may take to send the whole request, READ_TIMEOUT
+	Write      time.Duration `yaml:"write"`      // How long writing the response may take after the request was read, WRITE_TIMEOUT
+	Idle       time.Duration `yaml:"idle"`       // How long a keep-alive connection may wait for the next request, IDLE_TIMEOUT
+	Shutdown   time.Duration `yaml:"shutdown"`   // How long in-flight requests may take to finish on SIGINT or SIGTERM, SHUTDOWN_TIMEOUT
+}
+
+// Default returns the configuration used for every value that is not set
+func Default() Config {
+	return Config{
+		Addr:        ":8080",
+		DatabaseURL: "file:dev.db",
+		CORSOrigins: []string{"*"},
+		AI: AI{
+			BaseURL: "https://openrouter.ai/api/v1",
+			Model:   "meta-llama/llama-3-8b-instruct:free",
+		},
+		Purge: Purge{
+			Retention: 30 * 24 * time.Hour,
+			Interval:  time.Hour,
+		},
+		Timeouts: Timeouts{
+			Request:    2 * time.Minute,
+			AI:         time.Minute,
+			ReadHeader: 10 * time.Second,
+			Read:       30 * time.Second,
+			Write:      150 * time.Second,
+			Idle:       2 * time.Minute,
+			Shutdown:   150 * time.Second,
+		},
+	}
+}
+
+// Load reads and validates the configuration
+func Load() (Config, error) {
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return Config{}, fmt.Errorf("invalid .env: %w", err)
+	}
+
+	config := Default()
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := config.readFile(path); err != nil {
+			return Config{}, fmt.Errorf("invalid CONFIG_FILE: %w", err)
+		}
+	}
+
+	if err := config.readEnv(); err != nil {
+		return Config{}, err
+	}
+	if err := config.Validate(); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+// readFile overrides the configuration with the values set in a YAML file. Unknown keys are
+// rejected so that typos do not go unnoticed.
+func (c *Config) readFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// readEnv overrides the configuration with the environment variables that are set
+func (c *Config) readEnv() error {
+	stringEnv("LISTEN_ADDR", &c.Addr)
+	stringEnv("DATABASE_URL", &c.DatabaseURL)
+	if value := os.Getenv("CORS_ORIGINS"); value != "" {
+		c.CORSOrigins = nil
+		for _, origin := range strings.Split(value, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				c.CORSOrigins = append(c.CORSOrigins, origin)
+			}
+		}
+	}
+
+	stringEnv("OPENROUTER_API_KEY", &c.AI.APIKey)
+	stringEnv("AI_BASE_URL", &c.AI.BaseURL)
+	stringEnv("AI_MODEL", &c.AI.Model)
+	stringEnv("APP_URL", &c.AI.AppURL)
+	stringEnv("APP_NAME", &c.AI.AppName)
+
+	stringEnv("JWT_JWKS_FILE", &c.Auth.JWKSFile)
+	stringEnv("JWT_ISSUER", &c.Auth.Issuer)
+	stringEnv("JWT_AUDIENCE", &c.Auth.Audience)
+
+	return errors.Join(
+		durationEnv("PURGE_RETENTION", &c.Purge.Retention),
+		durationEnv("PURGE_INTERVAL", &c.Purge.Interval),
+		durationEnv("REQUEST_TIMEOUT", &c.Timeouts.Request),
+		durationEnv("AI_TIMEOUT", &c.Timeouts.AI),
+		durationEnv("READ_HEADER_TIMEOUT", &c.Timeouts.ReadHeader),
+		durationEnv("READ_TIMEOUT", &c.Timeouts.Read),
+		durationEnv("WRITE_TIMEOUT", &c.Timeouts.Write),
+		durationEnv("IDLE_TIMEOUT", &c.Timeouts.Idle),
+		durationEnv("SHUTDOWN_TIMEOUT", &c.Timeouts.Shutdown),
+	)
+}
+
+// Validate reports every invalid value of the configuration
+func (c Config) Validate() error {
+	var errs []error
+	invalid := func(name, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("invalid %s: %s", name, fmt.Sprintf(format, args...)))
+	}
+
+	if _, _, err := net.SplitHostPort(c.Addr); err != nil {
+		invalid("addr", "%v", err)
+	}
+
+	if !strings.HasPrefix(c.DatabaseURL, "file:") {
+		invalid("databaseUrl", "must be a SQLite file: URL")
+	}
+
+	if len(c.CORSOrigins) == 0 {
+		invalid("corsOrigins", "must not be empty")
+	}
+	for _, origin := range c.CORSOrigins {
+		if origin == "*" {
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" {
+			invalid("corsOrigins", "%q is not an origin such as https://example.com", origin)
+		}
+	}
+
+	if u, err := url.Parse(c.AI.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		invalid("ai.baseUrl", "%q is not an http(s) URL", c.AI.BaseURL)
+	}
+	if c.AI.Model == "" {
+		invalid("ai.model", "must not be empty")
+	}
+
+	if c.Auth.JWKSFile == "" && (c.Auth.Issuer != "" || c.Auth.Audience != "") {
+		invalid("auth", "issuer and audience require a jwksFile")
+	}
+
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"purge.retention", c.Purge.Retention},
+		{"purge.interval", c.Purge.Interval},
+		{"timeouts.request", c.Timeouts.Request},
+		{"timeouts.ai", c.Timeouts.AI},
+		{"timeouts.readHeader", c.Timeouts.ReadHeader},
+		{"timeouts.read", c.Timeouts.Read},
+		{"timeouts.write", c.Timeouts.Write},
+		{"timeouts.idle", c.Timeouts.Idle},
+		{"timeouts.shutdown", c.Timeouts.Shutdown},
+	}
+	for _, duration := range durations {
+		if duration.value <= 0 {
+			invalid(duration.name, "must be positive")
+		}
+	}
+	if c.Timeouts.AI > c.Timeouts.Request {
+		invalid("timeouts.ai", "must not exceed timeouts.request")
+	}
+	// Requests that time out must still be able to write their 504
+	if c.Timeouts.Write <= c.Timeouts.Request {
+		invalid("timeouts.write", "must exceed timeouts.request")
+	}
+	if c.Timeouts.ReadHeader > c.Timeouts.Read {
+		invalid("timeouts.readHeader", "must not exceed timeouts.read")
+	}
+
+	return errors.Join(errs...)
+}
+
+// stringEnv sets value from an environment variable when it is set
+func stringEnv(name string, value *string) {
+	if env := os.Getenv(name); env != "" {
+		*value = env
+	}
+}
+
+// durationEnv sets value from an environment variable holding a duration such as "720h" when it
+// is set
+func durationEnv(name string, value *time.Duration) error {
+	env := os.Getenv(name)
+	if env == "" {
+		return nil
+	}
+
+	duration, err := time.ParseDuration(env)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", name, err)
+	}
+	*value = duration
+	return nil
+}
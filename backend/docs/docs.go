@@ -15,9 +15,137 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/audit": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a page of the audit events of the request's workspace, newest first, limited to the workspace itself and the calculations, formulars and nodes the caller has a role on, optionally filtered by actor, request, action, resource and time. Pass nextCursor as cursor to fetch the following page.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "audit"
+                ],
+                "summary": "List audit events",
+                "parameters": [
+                    {
+                        "maximum": 200,
+                        "minimum": 1,
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Maximum number of items to return",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor returned as nextCursor by the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only return events of this actor",
+                        "name": "actor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only return events of this request",
+                        "name": "requestId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only return events with this action",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "calculation",
+                            "formular",
+                            "node",
+                            "workspace"
+                        ],
+                        "type": "string",
+                        "description": "Only return events for this kind of resource",
+                        "name": "resource",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only return events for the resource with this ID",
+                        "name": "resourceId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only return events recorded at or after this RFC 3339 timestamp",
+                        "name": "createdAfter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only return events recorded before this RFC 3339 timestamp",
+                        "name": "createdBefore",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Page-handlers_AuditEvent"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid query parameters",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.ValidationError"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
         "/calculations": {
             "get": {
-                "description": "Get all calculations",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a page of calculations, optionally filtered by name and timestamps. Pass nextCursor as cursor to fetch the following page.",
                 "consumes": [
                     "application/json"
                 ],
@@ -28,19 +156,117 @@ const docTemplate = `{
                     "calculations"
                 ],
                 "summary": "List calculations",
+                "parameters": [
+                    {
+                        "maximum": 200,
+                        "minimum": 1,
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Maximum number of items to return",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor returned as nextCursor by the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only return calculations whose name contains this text",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only return calculations created at or after this RFC 3339 timestamp",
+                        "name": "createdAfter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only return calculations created before this RFC 3339 timestamp",
+                        "name": "createdBefore",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only return calculations updated at or after this RFC 3339 timestamp",
+                        "name": "updatedAfter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only return calculations updated before this RFC 3339 timestamp",
+                        "name": "updatedBefore",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "name",
+                            "-name",
+                            "createdAt",
+                            "-createdAt",
+                            "updatedAt",
+                            "-updatedAt"
+                        ],
+                        "type": "string",
+                        "default": "createdAt",
+                        "description": "Sort field, prefixed with - for descending order",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Include soft-deleted calculations",
+                        "name": "includeDeleted",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/db.CalculationModel"
-                            }
+                            "$ref": "#/definitions/handlers.Page-db_CalculationModel"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid query parameters",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.ValidationError"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     }
                 }
             },
             "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
                 "description": "Create a new calculation",
                 "consumes": [
                     "application/json"
@@ -69,13 +295,45 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/db.CalculationModel"
                         }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.ValidationError"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
                     }
                 }
             }
         },
         "/calculations/{id}": {
             "get": {
-                "description": "Get calculation by ID",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get calculation by ID, optionally with its related records. Expanded sequences are returned in sequence order.",
                 "consumes": [
                     "application/json"
                 ],
@@ -93,6 +351,19 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Return the calculation even if it is soft-deleted",
+                        "name": "includeDeleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated relations to include, any prefix of formulars.formular.nodes.node or variables",
+                        "name": "expand",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -102,15 +373,47 @@ const docTemplate = `{
                             "$ref": "#/definitions/db.CalculationModel"
                         }
                     },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
                     "404": {
                         "description": "Calculation not found",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid query parameters",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.ValidationError"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     }
                 }
             },
             "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
                 "description": "Update a calculation by ID",
                 "consumes": [
                     "application/json"
@@ -147,16 +450,54 @@ const docTemplate = `{
                             "$ref": "#/definitions/db.CalculationModel"
                         }
                     },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
                     "404": {
                         "description": "Calculation not found",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.ValidationError"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     }
                 }
             },
             "delete": {
-                "description": "Delete a calculation by ID",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Soft delete a calculation by ID so it can be restored. With permanent=true the calculation is deleted immediately: in restrict mode a calculation that has formulars or variables is not deleted. Detach deletes its formular sequence and variables; cascade also deletes the formulars no other calculation uses and their unused nodes, as far as the caller owns them.",
                 "consumes": [
                     "application/json"
                 ],
@@ -174,16 +515,159 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Delete the calculation immediately instead of soft deleting it",
+                        "name": "permanent",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "restrict",
+                            "detach",
+                            "cascade"
+                        ],
+                        "type": "string",
+                        "default": "restrict",
+                        "description": "How a permanent delete handles related records, only with permanent=true",
+                        "name": "mode",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "204": {
                         "description": "No Content"
                     },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
                     "404": {
                         "description": "Calculation not found",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "409": {
+                        "description": "Calculation has formulars or variables",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.DependentsConflict"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid query parameters",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.ValidationError"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/calculations/{id}/evaluate": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Compute a calculation by evaluating its formulars and their nodes in sequence order, using the default value of every variable. A soft-deleted formular, or a soft-deleted node of an unpinned formular, fails the evaluation instead of being skipped.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "calculations"
+                ],
+                "summary": "Evaluate a calculation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Calculation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/engine.Result"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Calculation not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "409": {
+                        "description": "Broken formular or node sequence, or a deleted formular or node in it",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "422": {
+                        "description": "Calculation cannot be evaluated",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/engine.Error"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     }
                 }
@@ -191,7 +675,15 @@ const docTemplate = `{
         },
         "/calculations/{id}/formulars": {
             "get": {
-                "description": "Get all formulars in a calculation's sequence",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get all formulars in a calculation's sequence, ordered by walking the sequence from its head, with whether each is pinned to a version or floating",
                 "consumes": [
                     "application/json"
                 ],
@@ -217,14 +709,52 @@ const docTemplate = `{
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/db.CalculationFormularModel"
+                                "$ref": "#/definitions/handlers.OrderedCalculationFormular"
                             }
                         }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Calculation not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "409": {
+                        "description": "Broken formular sequence",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.IntegrityReport"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
                     }
                 }
             },
             "post": {
-                "description": "Add a formular to a calculation's sequence",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Insert a formular into a calculation's sequence before nextId, after afterId, or at the end when neither is given. A pinned formular is evaluated as the version that was current when it was added; a floating formular follows every change.",
                 "consumes": [
                     "application/json"
                 ],
@@ -259,13 +789,63 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/db.CalculationFormularModel"
                         }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Calculation or formular not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "409": {
+                        "description": "Broken formular sequence, or the sequence changed while the formular was added",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid input or insert position",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.ValidationError"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
                     }
                 }
             }
         },
         "/calculations/{id}/formulars/reorder": {
             "put": {
-                "description": "Update the sequence of formulars in a calculation",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Atomically update the sequence of formulars in a calculation. The order must contain exactly the current formulars of the calculation; repeated formulars keep their relative order. Use the link endpoint to reorder specific occurrences.",
                 "consumes": [
                     "application/json"
                 ],
@@ -276,6 +856,7 @@ const docTemplate = `{
                     "calculations"
                 ],
                 "summary": "Reorder formulars in a calculation",
+                "deprecated": true,
                 "parameters": [
                     {
                         "type": "string",
@@ -297,13 +878,75 @@ const docTemplate = `{
                 "responses": {
                     "200": {
                         "description": "OK"
-                    }
-                }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Calculation not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "409": {
+                        "description": "Order does not match the current formulars",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.SequenceConflict"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.ValidationError"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
             }
         },
         "/calculations/{id}/formulars/{formularId}": {
             "delete": {
-                "description": "Remove a formular from a calculation's sequence",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Remove the first occurrence of a formular from a calculation's sequence and link its predecessor to its successor. Use the link endpoint to remove a specific occurrence.",
                 "consumes": [
                     "application/json"
                 ],
@@ -314,6 +957,7 @@ const docTemplate = `{
                     "calculations"
                 ],
                 "summary": "Remove a formular from a calculation",
+                "deprecated": true,
                 "parameters": [
                     {
                         "type": "string",
@@ -334,76 +978,38 @@ const docTemplate = `{
                     "204": {
                         "description": "No Content"
                     },
-                    "404": {
-                        "description": "CalculationFormular not found",
-                        "schema": {
-                            "type": "string"
-                        }
-                    }
-                }
-            }
-        },
-        "/formulars": {
-            "get": {
-                "description": "Get all formulars",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "formulars"
-                ],
-                "summary": "List formulars",
-                "responses": {
-                    "200": {
-                        "description": "OK",
+                    "401": {
+                        "description": "Authentication required",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/db.FormularModel"
-                            }
+                            "$ref": "#/definitions/handlers.APIError"
                         }
-                    }
-                }
-            },
-            "post": {
-                "description": "Create a new formular",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "formulars"
-                ],
-                "summary": "Create a formular",
-                "parameters": [
-                    {
-                        "description": "Formular to create",
-                        "name": "formular",
-                        "in": "body",
-                        "required": true,
+                    },
+                    "403": {
+                        "description": "Insufficient role",
                         "schema": {
-                            "$ref": "#/definitions/handlers.CreateFormularInput"
+                            "$ref": "#/definitions/handlers.APIError"
                         }
-                    }
-                ],
-                "responses": {
-                    "201": {
-                        "description": "Created",
+                    },
+                    "404": {
+                        "description": "Calculation or CalculationFormular not found",
                         "schema": {
-                            "$ref": "#/definitions/db.FormularModel"
+                            "$ref": "#/definitions/handlers.APIError"
                         }
                     }
                 }
             }
         },
-        "/formulars/{id}": {
+        "/calculations/{id}/integrity": {
             "get": {
-                "description": "Get formular by ID",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Report multiple heads, cycles, orphans and dangling next pointers in a calculation's formular sequence",
                 "consumes": [
                     "application/json"
                 ],
@@ -411,13 +1017,13 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "formulars"
+                    "calculations"
                 ],
-                "summary": "Get a formular",
+                "summary": "Check the formular sequence of a calculation",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Formular ID",
+                        "description": "Calculation ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -427,19 +1033,35 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/db.FormularModel"
+                            "$ref": "#/definitions/handlers.IntegrityReport"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
                         }
                     },
                     "404": {
-                        "description": "Formular not found",
+                        "description": "Calculation not found",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handlers.APIError"
                         }
                     }
                 }
-            },
+            }
+        },
+        "/calculations/{id}/links/reorder": {
             "put": {
-                "description": "Update a formular by ID",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Atomically update the sequence of a calculation by calculation formular IDs. The order must contain exactly the current links of the calculation.",
                 "consumes": [
                     "application/json"
                 ],
@@ -447,44 +1069,99 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "formulars"
+                    "calculations"
                 ],
-                "summary": "Update a formular",
+                "summary": "Reorder the links of a calculation",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Formular ID",
+                        "description": "Calculation ID",
                         "name": "id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "Formular updates",
-                        "name": "formular",
+                        "description": "New link order",
+                        "name": "order",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/handlers.UpdateFormularInput"
+                            "$ref": "#/definitions/handlers.ReorderLinksInput"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "OK"
+                    },
+                    "401": {
+                        "description": "Authentication required",
                         "schema": {
-                            "$ref": "#/definitions/db.FormularModel"
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
                         }
                     },
                     "404": {
-                        "description": "Formular not found",
+                        "description": "Calculation not found",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "409": {
+                        "description": "Order does not match the current links",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.SequenceConflict"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.ValidationError"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     }
                 }
-            },
+            }
+        },
+        "/calculations/{id}/links/{linkId}": {
             "delete": {
-                "description": "Delete a formular by ID",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Remove a single occurrence of a formular from a calculation's sequence by its link ID and link its predecessor to its successor",
                 "consumes": [
                     "application/json"
                 ],
@@ -492,34 +1169,61 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "formulars"
+                    "calculations"
                 ],
-                "summary": "Delete a formular",
+                "summary": "Remove a calculation formular from a calculation",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Formular ID",
+                        "description": "Calculation ID",
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "CalculationFormular ID",
+                        "name": "linkId",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "204": {
                         "description": "No Content"
                     },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
                     "404": {
-                        "description": "Formular not found",
+                        "description": "Calculation or CalculationFormular not found",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handlers.APIError"
                         }
                     }
                 }
             }
         },
-        "/formulars/{id}/nodes": {
-            "get": {
-                "description": "Get all nodes in a formular's sequence",
+        "/calculations/{id}/links/{linkId}/upgrade": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Pin a single occurrence of a formular in a calculation to the formular's latest version, recording the current state of the formular as a new version if it changed. A floating formular becomes pinned.",
                 "consumes": [
                     "application/json"
                 ],
@@ -527,32 +1231,64 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "formulars"
+                    "calculations"
                 ],
-                "summary": "List nodes in a formular",
+                "summary": "Pin a calculation formular to the latest version",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Formular ID",
+                        "description": "Calculation ID",
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "CalculationFormular ID",
+                        "name": "linkId",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/db.FormularNodeModel"
-                            }
+                            "$ref": "#/definitions/db.CalculationFormularModel"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Calculation or CalculationFormular not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
                         }
                     }
                 }
-            },
-            "post": {
-                "description": "Add a node to a formular's sequence",
+            }
+        },
+        "/calculations/{id}/permissions": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the principals that have a role on a calculation, owners first",
                 "consumes": [
                     "application/json"
                 ],
@@ -560,40 +1296,54 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "formulars"
+                    "calculations"
                 ],
-                "summary": "Add a node to a formular",
+                "summary": "List who has access to a calculation",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Formular ID",
+                        "description": "Calculation ID",
                         "name": "id",
                         "in": "path",
                         "required": true
-                    },
-                    {
-                        "description": "Node to add",
-                        "name": "node",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/handlers.AddNodeInput"
-                        }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/db.FormularNodeModel"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/db.PermissionModel"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Calculation not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
                         }
                     }
                 }
             }
         },
-        "/formulars/{id}/nodes/reorder": {
+        "/calculations/{id}/permissions/{principal}": {
             "put": {
-                "description": "Update the sequence of nodes in a formular",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Give a principal a role on a calculation, replacing the role it had. Viewers can read and evaluate the calculation, editors can also change it, and owners can also delete and share it. The last owner cannot be demoted.",
                 "consumes": [
                     "application/json"
                 ],
@@ -601,37 +1351,101 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "formulars"
+                    "calculations"
                 ],
-                "summary": "Reorder nodes in a formular",
+                "summary": "Share a calculation",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Formular ID",
+                        "description": "Calculation ID",
                         "name": "id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "New node order",
-                        "name": "order",
+                        "type": "string",
+                        "description": "Principal ID",
+                        "name": "principal",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Role to grant",
+                        "name": "permission",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/handlers.ReorderNodesInput"
+                            "$ref": "#/definitions/handlers.GrantPermissionInput"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK"
-                    }
-                }
-            }
-        },
-        "/formulars/{id}/nodes/{nodeId}": {
+                        "description": "Role changed",
+                        "schema": {
+                            "$ref": "#/definitions/db.PermissionModel"
+                        }
+                    },
+                    "201": {
+                        "description": "Role granted",
+                        "schema": {
+                            "$ref": "#/definitions/db.PermissionModel"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Calculation not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "409": {
+                        "description": "Calculation must keep at least one owner",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.ValidationError"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            },
             "delete": {
-                "description": "Remove a node from a formular's sequence",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Take a principal's role on a calculation away. The last owner cannot be removed.",
                 "consumes": [
                     "application/json"
                 ],
@@ -639,21 +1453,21 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "formulars"
+                    "calculations"
                 ],
-                "summary": "Remove a node from a formular",
+                "summary": "Stop sharing a calculation",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Formular ID",
+                        "description": "Calculation ID",
                         "name": "id",
                         "in": "path",
                         "required": true
                     },
                     {
                         "type": "string",
-                        "description": "Node ID",
-                        "name": "nodeId",
+                        "description": "Principal ID",
+                        "name": "principal",
                         "in": "path",
                         "required": true
                     }
@@ -662,18 +1476,44 @@ const docTemplate = `{
                     "204": {
                         "description": "No Content"
                     },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
                     "404": {
-                        "description": "FormularNode not found",
+                        "description": "Calculation or permission not found",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "409": {
+                        "description": "Calculation must keep at least one owner",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
                         }
                     }
                 }
             }
         },
-        "/nodes": {
-            "get": {
-                "description": "Get all nodes",
+        "/calculations/{id}/restore": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Restore a soft-deleted calculation by ID. Restoring a calculation that is not deleted has no effect.",
                 "consumes": [
                     "application/json"
                 ],
@@ -681,23 +1521,57 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "nodes"
+                    "calculations"
+                ],
+                "summary": "Restore a calculation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Calculation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "List nodes",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/db.NodeModel"
-                            }
+                            "$ref": "#/definitions/db.CalculationModel"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Calculation not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
                         }
                     }
                 }
-            },
+            }
+        },
+        "/calculations/{id}/run": {
             "post": {
-                "description": "Create a new node",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Bind the given inputs to the calculation's variables and compute the calculation. A soft-deleted formular, or a soft-deleted node of an unpinned formular, fails the evaluation instead of being skipped.",
                 "consumes": [
                     "application/json"
                 ],
@@ -705,33 +1579,72 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "nodes"
+                    "calculations"
                 ],
-                "summary": "Create a node",
+                "summary": "Run a calculation with inputs",
                 "parameters": [
                     {
-                        "description": "Node to create",
-                        "name": "node",
+                        "type": "string",
+                        "description": "Calculation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Variable inputs",
+                        "name": "inputs",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/handlers.CreateNodeInput"
+                            "$ref": "#/definitions/handlers.RunCalculationInput"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/db.NodeModel"
+                            "$ref": "#/definitions/engine.Result"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Calculation not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "409": {
+                        "description": "Broken formular or node sequence, or a deleted formular or node in it",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "422": {
+                        "description": "Missing or invalid inputs, or calculation cannot be evaluated",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
                         }
                     }
                 }
             }
         },
-        "/nodes/{id}": {
+        "/calculations/{id}/variables": {
             "get": {
-                "description": "Get node by ID",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get all named input variables of a calculation",
                 "consumes": [
                     "application/json"
                 ],
@@ -739,13 +1652,13 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "nodes"
+                    "calculations"
                 ],
-                "summary": "Get a node",
+                "summary": "List variables of a calculation",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Node ID",
+                        "description": "Calculation ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -755,19 +1668,36 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/db.NodeModel"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/db.VariableModel"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
                         }
                     },
                     "404": {
-                        "description": "Node not found",
+                        "description": "Calculation not found",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handlers.APIError"
                         }
                     }
                 }
             },
-            "put": {
-                "description": "Update a node by ID",
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Declare a named input variable that nodes can reference",
                 "consumes": [
                     "application/json"
                 ],
@@ -775,44 +1705,102 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "nodes"
+                    "calculations"
                 ],
-                "summary": "Update a node",
+                "summary": "Add a variable to a calculation",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Node ID",
+                        "description": "Calculation ID",
                         "name": "id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "Node updates",
-                        "name": "node",
+                        "description": "Variable to add",
+                        "name": "variable",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/handlers.UpdateNodeInput"
+                            "$ref": "#/definitions/handlers.AddVariableInput"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/db.NodeModel"
+                            "$ref": "#/definitions/db.VariableModel"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
                         }
                     },
                     "404": {
-                        "description": "Node not found",
+                        "description": "Calculation not found",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "409": {
+                        "description": "Variable already exists",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.UniqueConflict"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid input or variable definition",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.ValidationError"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     }
                 }
-            },
+            }
+        },
+        "/calculations/{id}/variables/{variableId}": {
             "delete": {
-                "description": "Delete a node by ID",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Delete a named input variable of a calculation",
                 "consumes": [
                     "application/json"
                 ],
@@ -820,123 +1808,3849 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "nodes"
+                    "calculations"
                 ],
-                "summary": "Delete a node",
+                "summary": "Remove a variable from a calculation",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Node ID",
+                        "description": "Calculation ID",
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Variable ID",
+                        "name": "variableId",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "204": {
                         "description": "No Content"
                     },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
                     "404": {
-                        "description": "Node not found",
+                        "description": "Calculation or variable not found",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handlers.APIError"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "db.CalculationFormularModel": {
-            "type": "object",
-            "properties": {
-                "calculation": {
-                    "$ref": "#/definitions/db.CalculationModel"
-                },
-                "calculationId": {
-                    "type": "string"
-                },
-                "createdAt": {
-                    "type": "string"
-                },
-                "formular": {
-                    "$ref": "#/definitions/db.FormularModel"
-                },
-                "formularId": {
-                    "type": "string"
-                },
-                "id": {
-                    "type": "string"
-                },
-                "next": {
-                    "$ref": "#/definitions/db.CalculationFormularModel"
-                },
-                "nextId": {
-                    "type": "string"
+        },
+        "/formulars": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a page of formulars, optionally filtered by name and timestamps. Pass nextCursor as cursor to fetch the following page.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "formulars"
+                ],
+                "summary": "List formulars",
+                "parameters": [
+                    {
+                        "maximum": 200,
+                        "minimum": 1,
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Maximum number of items to return",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor returned as nextCursor by the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only return formulars whose name contains this text",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only return formulars created at or after this RFC 3339 timestamp",
+                        "name": "createdAfter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only return formulars created before this RFC 3339 timestamp",
+                        "name": "createdBefore",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only return formulars updated at or after this RFC 3339 timestamp",
+                        "name": "updatedAfter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only return formulars updated before this RFC 3339 timestamp",
+                        "name": "updatedBefore",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "name",
+                            "-name",
+                            "createdAt",
+                            "-createdAt",
+                            "updatedAt",
+                            "-updatedAt"
+                        ],
+                        "type": "string",
+                        "default": "createdAt",
+                        "description": "Sort field, prefixed with - for descending order",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Include soft-deleted formulars",
+                        "name": "includeDeleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Page-db_FormularModel"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid query parameters",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.ValidationError"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create a new formular",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "formulars"
+                ],
+                "summary": "Create a formular",
+                "parameters": [
+                    {
+                        "description": "Formular to create",
+                        "name": "formular",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CreateFormularInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/db.FormularModel"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.ValidationError"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/formulars/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get formular by ID, optionally with its nodes in sequence order",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "formulars"
+                ],
+                "summary": "Get a formular",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Formular ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Return the formular even if it is soft-deleted",
+                        "name": "includeDeleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated relations to include, any prefix of nodes.node",
+                        "name": "expand",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/db.FormularModel"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Formular not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid query parameters",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.ValidationError"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Update a formular by ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "formulars"
+                ],
+                "summary": "Update a formular",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Formular ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Formular updates",
+                        "name": "formular",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.UpdateFormularInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/db.FormularModel"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Formular not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.ValidationError"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Soft delete a formular by ID so it can be restored. Soft-deleted formulars stay in the calculations that use them until they are purged, and those calculations fail to evaluate with a 409 until the formular is restored or removed. With permanent=true the formular is deleted immediately: in restrict mode a formular that has nodes or is used by a calculation is not deleted. Detach removes it from every calculation, relinking the surrounding formulars, which requires the editor role on each of those calculations, and deletes its node sequence; cascade also deletes the nodes no other formular uses that the caller owns. A restricted delete only lists the dependents in calculations the caller has a role on and counts the others.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "formulars"
+                ],
+                "summary": "Delete a formular",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Formular ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Delete the formular immediately instead of soft deleting it",
+                        "name": "permanent",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "restrict",
+                            "detach",
+                            "cascade"
+                        ],
+                        "type": "string",
+                        "default": "restrict",
+                        "description": "How a permanent delete handles related records, only with permanent=true",
+                        "name": "mode",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Insufficient role on the formular or a calculation using it",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Formular not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "409": {
+                        "description": "Formular has nodes or is used by calculations",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.DependentsConflict"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid query parameters",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.ValidationError"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/formulars/{id}/affected-calculations": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the calculations that use the formular without pinning a version, whose results would change if the formular were updated. Calculations that only use pinned versions of the formular are not affected, and only calculations the caller has a role on are returned.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "formulars"
+                ],
+                "summary": "List the calculations a formular change would affect",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Formular ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Include soft-deleted calculations",
+                        "name": "includeDeleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/db.CalculationModel"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Formular not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid query parameters",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.ValidationError"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/formulars/{id}/compile": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Parse an infix expression and create the nodes and node sequence that evaluate it. Numbers are written in plain decimal notation, exponent notation such as 1e5 is rejected. With replace, the old sequence is removed and its nodes that no other formular uses and the caller owns are deleted.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "formulars"
+                ],
+                "summary": "Compile an expression into a formular",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Formular ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Expression to compile",
+                        "name": "expression",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CompileFormularInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/db.FormularNodeModel"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Formular not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "409": {
+                        "description": "Formular already has nodes",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid input, or syntax error with line and column",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/engine.SyntaxError"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/formulars/{id}/integrity": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Report multiple heads, cycles, orphans and dangling next pointers in a formular's node sequence",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "formulars"
+                ],
+                "summary": "Check the node sequence of a formular",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Formular ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.IntegrityReport"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Formular not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    }
+                }
+            }
+        },
+        "/formulars/{id}/links/reorder": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Atomically update the sequence of a formular by formular node IDs. The order must contain exactly the current links of the formular.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "formulars"
+                ],
+                "summary": "Reorder the links of a formular",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Formular ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New link order",
+                        "name": "order",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ReorderLinksInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Formular not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "409": {
+                        "description": "Order does not match the current links",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.SequenceConflict"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.ValidationError"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/formulars/{id}/links/{linkId}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Remove a single occurrence of a node from a formular's sequence by its link ID and link its predecessor to its successor",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "formulars"
+                ],
+                "summary": "Remove a formular node from a formular",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Formular ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "FormularNode ID",
+                        "name": "linkId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Formular or FormularNode not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    }
+                }
+            }
+        },
+        "/formulars/{id}/nodes": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get all nodes in a formular's sequence, ordered by walking the sequence from its head",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "formulars"
+                ],
+                "summary": "List nodes in a formular",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Formular ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.OrderedFormularNode"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Formular not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "409": {
+                        "description": "Broken node sequence",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.IntegrityReport"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Insert a node into a formular's sequence before nextId, after afterId, or at the end when neither is given",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "formulars"
+                ],
+                "summary": "Add a node to a formular",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Formular ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Node to add",
+                        "name": "node",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.AddNodeInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/db.FormularNodeModel"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Formular or node not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "409": {
+                        "description": "Broken node sequence, or the sequence changed while the node was added",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid input or insert position",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.ValidationError"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/formulars/{id}/nodes/reorder": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Atomically update the sequence of nodes in a formular. The order must contain exactly the current nodes of the formular; repeated nodes keep their relative order. Use the link endpoint to reorder specific occurrences.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "formulars"
+                ],
+                "summary": "Reorder nodes in a formular",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Formular ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New node order",
+                        "name": "order",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ReorderNodesInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Formular not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "409": {
+                        "description": "Order does not match the current nodes",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.SequenceConflict"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.ValidationError"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/formulars/{id}/nodes/{nodeId}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Remove the first occurrence of a node from a formular's sequence and link its predecessor to its successor. Use the link endpoint to remove a specific occurrence.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "formulars"
+                ],
+                "summary": "Remove a node from a formular",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Formular ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Node ID",
+                        "name": "nodeId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Formular or FormularNode not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    }
+                }
+            }
+        },
+        "/formulars/{id}/permissions": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the principals that have a role on a formular, owners first",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "formulars"
+                ],
+                "summary": "List who has access to a formular",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Formular ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/db.PermissionModel"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Formular not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    }
+                }
+            }
+        },
+        "/formulars/{id}/permissions/{principal}": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Give a principal a role on a formular, replacing the role it had. Viewers can read and evaluate the formular, editors can also change it, and owners can also delete and share it. The last owner cannot be demoted.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "formulars"
+                ],
+                "summary": "Share a formular",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Formular ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Principal ID",
+                        "name": "principal",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Role to grant",
+                        "name": "permission",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.GrantPermissionInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Role changed",
+                        "schema": {
+                            "$ref": "#/definitions/db.PermissionModel"
+                        }
+                    },
+                    "201": {
+                        "description": "Role granted",
+                        "schema": {
+                            "$ref": "#/definitions/db.PermissionModel"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Formular not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "409": {
+                        "description": "Formular must keep at least one owner",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.ValidationError"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Take a principal's role on a formular away. The last owner cannot be removed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "formulars"
+                ],
+                "summary": "Stop sharing a formular",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Formular ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Principal ID",
+                        "name": "principal",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Formular or permission not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "409": {
+                        "description": "Formular must keep at least one owner",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    }
+                }
+            }
+        },
+        "/formulars/{id}/restore": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Restore a soft-deleted formular by ID. Restoring a formular that is not deleted has no effect.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "formulars"
+                ],
+                "summary": "Restore a formular",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Formular ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/db.FormularModel"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Formular not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    }
+                }
+            }
+        },
+        "/formulars/{id}/versions": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get every recorded version of a formular, newest first. A version is recorded whenever the formular's name or node sequence changes.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "formulars"
+                ],
+                "summary": "List the versions of a formular",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Formular ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.FormularVersion"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Formular not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    }
+                }
+            }
+        },
+        "/formulars/{id}/versions/diff": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the structural difference between two versions of a formular: the name change and the nodes that were added, removed or modified, matched by node ID along the longest common subsequence. A node that moved appears as removed and added.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "formulars"
+                ],
+                "summary": "Compare two versions of a formular",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Formular ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Version number to compare from",
+                        "name": "from",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Version number to compare to",
+                        "name": "to",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.FormularDiff"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Formular or FormularVersion not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid version numbers",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.ValidationError"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/formulars/{id}/versions/{version}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a single recorded version of a formular by its version number",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "formulars"
+                ],
+                "summary": "Get a version of a formular",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Formular ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Version number",
+                        "name": "version",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.FormularVersion"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Formular or FormularVersion not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid version number",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.ValidationError"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/formulars/{id}/versions/{version}/restore": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Replace the name and node sequence of a formular with those of a recorded version and record the result as a new version. Nodes that still exist unchanged are reused; nodes that were deleted or changed since are recreated from the snapshot, so other formulars using them are not affected.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "formulars"
+                ],
+                "summary": "Roll a formular back to a version",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Formular ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Version number to roll back to",
+                        "name": "version",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.FormularVersion"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Formular or FormularVersion not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid version number",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.ValidationError"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/nodes": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a page of nodes, optionally filtered by name and timestamps. Pass nextCursor as cursor to fetch the following page.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "nodes"
+                ],
+                "summary": "List nodes",
+                "parameters": [
+                    {
+                        "maximum": 200,
+                        "minimum": 1,
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Maximum number of items to return",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor returned as nextCursor by the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only return nodes whose name contains this text",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only return nodes created at or after this RFC 3339 timestamp",
+                        "name": "createdAfter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only return nodes created before this RFC 3339 timestamp",
+                        "name": "createdBefore",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only return nodes updated at or after this RFC 3339 timestamp",
+                        "name": "updatedAfter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only return nodes updated before this RFC 3339 timestamp",
+                        "name": "updatedBefore",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "name",
+                            "-name",
+                            "createdAt",
+                            "-createdAt",
+                            "updatedAt",
+                            "-updatedAt"
+                        ],
+                        "type": "string",
+                        "default": "createdAt",
+                        "description": "Sort field, prefixed with - for descending order",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Include soft-deleted nodes",
+                        "name": "includeDeleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.Page-db_NodeModel"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid query parameters",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.ValidationError"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create a new node",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "nodes"
+                ],
+                "summary": "Create a node",
+                "parameters": [
+                    {
+                        "description": "Node to create",
+                        "name": "node",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CreateNodeInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/db.NodeModel"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid input or nodeData",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.ValidationError"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/nodes/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get node by ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "nodes"
+                ],
+                "summary": "Get a node",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Node ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Return the node even if it is soft-deleted",
+                        "name": "includeDeleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/db.NodeModel"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Node not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid query parameters",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.ValidationError"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Update a node by ID. Every formular using the node records a new version with the change.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "nodes"
+                ],
+                "summary": "Update a node",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Node ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Node updates",
+                        "name": "node",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.UpdateNodeInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/db.NodeModel"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Node not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid input or nodeData",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.ValidationError"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Soft delete a node by ID so it can be restored. Soft-deleted nodes stay in the formulars that use them until they are purged, and calculations using those formulars unpinned fail to evaluate with a 409 until the node is restored or removed. With permanent=true the node is deleted immediately: in restrict mode a node that is used by a formular is not deleted; detach and cascade remove it from every formular, relink the surrounding nodes and record a new version of each formular, which requires the editor role on each of those formulars. A restricted delete only lists the dependents in formulars the caller has a role on and counts the others.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "nodes"
+                ],
+                "summary": "Delete a node",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Node ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Delete the node immediately instead of soft deleting it",
+                        "name": "permanent",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "restrict",
+                            "detach",
+                            "cascade"
+                        ],
+                        "type": "string",
+                        "default": "restrict",
+                        "description": "How a permanent delete handles related records, only with permanent=true",
+                        "name": "mode",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Insufficient role on the node or a formular using it",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Node not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "409": {
+                        "description": "Node is used by formulars",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.DependentsConflict"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid query parameters",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.ValidationError"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/nodes/{id}/permissions": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the principals that have a role on a node, owners first",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "nodes"
+                ],
+                "summary": "List who has access to a node",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Node ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/db.PermissionModel"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Node not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    }
+                }
+            }
+        },
+        "/nodes/{id}/permissions/{principal}": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Give a principal a role on a node, replacing the role it had. Viewers can read the node and use it in formulars, editors can also change it, and owners can also delete and share it. The last owner cannot be demoted.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "nodes"
+                ],
+                "summary": "Share a node",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Node ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Principal ID",
+                        "name": "principal",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Role to grant",
+                        "name": "permission",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.GrantPermissionInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Role changed",
+                        "schema": {
+                            "$ref": "#/definitions/db.PermissionModel"
+                        }
+                    },
+                    "201": {
+                        "description": "Role granted",
+                        "schema": {
+                            "$ref": "#/definitions/db.PermissionModel"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Node not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "409": {
+                        "description": "Node must keep at least one owner",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.ValidationError"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Take a principal's role on a node away. The last owner cannot be removed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "nodes"
+                ],
+                "summary": "Stop sharing a node",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Node ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Principal ID",
+                        "name": "principal",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Node or permission not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "409": {
+                        "description": "Node must keep at least one owner",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    }
+                }
+            }
+        },
+        "/nodes/{id}/restore": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Restore a soft-deleted node by ID. Restoring a node that is not deleted has no effect.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "nodes"
+                ],
+                "summary": "Restore a node",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Node ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/db.NodeModel"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Insufficient role",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Node not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    }
+                }
+            }
+        },
+        "/workspaces": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the workspaces the caller is a member of, which it can select with the X-Workspace-ID header. The default workspace is always included.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "workspaces"
+                ],
+                "summary": "List workspaces",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/db.WorkspaceModel"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create a new workspace with the caller as its only member and owner",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "workspaces"
+                ],
+                "summary": "Create a workspace",
+                "parameters": [
+                    {
+                        "description": "Workspace to create",
+                        "name": "workspace",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CreateWorkspaceInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/db.WorkspaceModel"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.ValidationError"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/workspaces/{id}/members": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the principals that can select a workspace",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "workspaces"
+                ],
+                "summary": "List the members of a workspace",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Workspace ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/db.WorkspaceMemberModel"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Workspace not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    }
+                }
+            }
+        },
+        "/workspaces/{id}/members/{principal}": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Let a principal select a workspace, which requires the caller to be an owner of it. Adding a principal that is already a member has no effect unless a role is given, which changes its role. The last owner cannot become a member.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "workspaces"
+                ],
+                "summary": "Add a member to a workspace",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Workspace ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Principal ID",
+                        "name": "principal",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "member",
+                            "owner"
+                        ],
+                        "type": "string",
+                        "description": "The role of the member, owners manage the members; defaults to member for new members",
+                        "name": "role",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Already a member",
+                        "schema": {
+                            "$ref": "#/definitions/db.WorkspaceMemberModel"
+                        }
+                    },
+                    "201": {
+                        "description": "Member added",
+                        "schema": {
+                            "$ref": "#/definitions/db.WorkspaceMemberModel"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller is not an owner of the workspace",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Workspace not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "409": {
+                        "description": "Workspace must keep at least one owner",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid role",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/handlers.APIError"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/handlers.ValidationError"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Stop a principal from selecting a workspace, which requires the caller to be an owner of it. Credentials bound to the workspace keep working. The last owner, and so the last member, cannot be removed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "workspaces"
+                ],
+                "summary": "Remove a member from a workspace",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Workspace ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Principal ID",
+                        "name": "principal",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "403": {
+                        "description": "Caller is not an owner of the workspace",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "404": {
+                        "description": "Workspace or member not found",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    },
+                    "409": {
+                        "description": "Workspace must keep at least one owner",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.APIError"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "db.CalculationFormularModel": {
+            "type": "object",
+            "properties": {
+                "calculation": {
+                    "$ref": "#/definitions/db.CalculationModel"
+                },
+                "calculationId": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "formular": {
+                    "$ref": "#/definitions/db.FormularModel"
+                },
+                "formularId": {
+                    "type": "string"
+                },
+                "formularVersion": {
+                    "$ref": "#/definitions/db.FormularVersionModel"
+                },
+                "formularVersionId": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "next": {
+                    "$ref": "#/definitions/db.CalculationFormularModel"
+                },
+                "nextId": {
+                    "type": "string"
+                },
+                "previous": {
+                    "$ref": "#/definitions/db.CalculationFormularModel"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "db.CalculationModel": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "deletedAt": {
+                    "type": "string"
+                },
+                "formulars": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/db.CalculationFormularModel"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "variables": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/db.VariableModel"
+                    }
+                },
+                "workspace": {
+                    "$ref": "#/definitions/db.WorkspaceModel"
+                },
+                "workspaceId": {
+                    "type": "string"
+                }
+            }
+        },
+        "db.FormularModel": {
+            "type": "object",
+            "properties": {
+                "calculationFormulars": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/db.CalculationFormularModel"
+                    }
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "deletedAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "nodes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/db.FormularNodeModel"
+                    }
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "versions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/db.FormularVersionModel"
+                    }
+                },
+                "workspace": {
+                    "$ref": "#/definitions/db.WorkspaceModel"
+                },
+                "workspaceId": {
+                    "type": "string"
+                }
+            }
+        },
+        "db.FormularNodeModel": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "formular": {
+                    "$ref": "#/definitions/db.FormularModel"
+                },
+                "formularId": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "next": {
+                    "$ref": "#/definitions/db.FormularNodeModel"
+                },
+                "nextId": {
+                    "type": "string"
+                },
+                "node": {
+                    "$ref": "#/definitions/db.NodeModel"
+                },
+                "nodeId": {
+                    "type": "string"
+                },
+                "previous": {
+                    "$ref": "#/definitions/db.FormularNodeModel"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "db.FormularVersionModel": {
+            "type": "object",
+            "properties": {
+                "calculationFormulars": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/db.CalculationFormularModel"
+                    }
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "formular": {
+                    "$ref": "#/definitions/db.FormularModel"
+                },
+                "formularId": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "nodes": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "db.NodeModel": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "deletedAt": {
+                    "type": "string"
+                },
+                "formularNodes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/db.FormularNodeModel"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "nodeData": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "workspace": {
+                    "$ref": "#/definitions/db.WorkspaceModel"
+                },
+                "workspaceId": {
+                    "type": "string"
+                }
+            }
+        },
+        "db.PermissionModel": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "principal": {
+                    "type": "string"
+                },
+                "resource": {
+                    "type": "string"
+                },
+                "resourceId": {
+                    "type": "string"
+                },
+                "role": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "db.VariableModel": {
+            "type": "object",
+            "properties": {
+                "calculation": {
+                    "$ref": "#/definitions/db.CalculationModel"
+                },
+                "calculationId": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "defaultValue": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "max": {
+                    "type": "string"
+                },
+                "min": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "db.WorkspaceMemberModel": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "principal": {
+                    "type": "string"
+                },
+                "role": {
+                    "type": "string"
+                },
+                "workspace": {
+                    "$ref": "#/definitions/db.WorkspaceModel"
+                },
+                "workspaceId": {
+                    "type": "string"
+                }
+            }
+        },
+        "db.WorkspaceModel": {
+            "type": "object",
+            "properties": {
+                "calculations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/db.CalculationModel"
+                    }
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "formulars": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/db.FormularModel"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "members": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/db.WorkspaceMemberModel"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "nodes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/db.NodeModel"
+                    }
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "engine.Error": {
+            "type": "object",
+            "properties": {
+                "formularId": {
+                    "description": "The formular that failed, if any",
+                    "type": "string",
+                    "example": "123e4567-e89b-12d3-a456-426614174000"
+                },
+                "message": {
+                    "description": "Why evaluation failed",
+                    "type": "string",
+                    "example": "division by zero"
+                },
+                "nodeId": {
+                    "description": "The node that failed, if any",
+                    "type": "string",
+                    "example": "123e4567-e89b-12d3-a456-426614174001"
+                }
+            }
+        },
+        "engine.FormularResult": {
+            "type": "object",
+            "properties": {
+                "formularId": {
+                    "description": "The ID of the evaluated formular",
+                    "type": "string",
+                    "example": "123e4567-e89b-12d3-a456-426614174000"
+                },
+                "name": {
+                    "description": "The name of the evaluated formular",
+                    "type": "string",
+                    "example": "My Formular"
+                },
+                "nodes": {
+                    "description": "The intermediate result of every node",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/engine.NodeResult"
+                    }
+                },
+                "value": {
+                    "description": "The result of the formular",
+                    "type": "string",
+                    "example": "42.5"
+                }
+            }
+        },
+        "engine.NodeResult": {
+            "type": "object",
+            "properties": {
+                "nodeId": {
+                    "description": "The ID of the evaluated node",
+                    "type": "string",
+                    "example": "123e4567-e89b-12d3-a456-426614174000"
+                },
+                "value": {
+                    "description": "The value on top of the stack after the node",
+                    "type": "string",
+                    "example": "42.5"
+                }
+            }
+        },
+        "engine.Result": {
+            "type": "object",
+            "properties": {
+                "formulars": {
+                    "description": "The result of every formular in order",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/engine.FormularResult"
+                    }
+                },
+                "value": {
+                    "description": "The result of the last formular",
+                    "type": "string",
+                    "example": "42.5"
+                }
+            }
+        },
+        "engine.SyntaxError": {
+            "type": "object",
+            "properties": {
+                "column": {
+                    "description": "The one-based column of the offending token",
+                    "type": "integer",
+                    "example": 7
+                },
+                "line": {
+                    "description": "The one-based line of the offending token",
+                    "type": "integer",
+                    "example": 1
+                },
+                "message": {
+                    "description": "What is wrong at that position",
+                    "type": "string",
+                    "example": "unexpected end of expression"
+                }
+            }
+        },
+        "handlers.APIError": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "A stable, machine-readable error code",
+                    "type": "string",
+                    "example": "not_found"
+                },
+                "details": {
+                    "description": "Optional structured information about the error",
+                    "type": "object"
+                },
+                "message": {
+                    "description": "A human-readable description of the error",
+                    "type": "string",
+                    "example": "Calculation not found"
+                },
+                "requestId": {
+                    "description": "The ID of the request, for correlating with server logs",
+                    "type": "string",
+                    "example": "host/abc-000001"
+                }
+            }
+        },
+        "handlers.AddFormularInput": {
+            "type": "object",
+            "required": [
+                "formularId"
+            ],
+            "properties": {
+                "afterId": {
+                    "description": "Optional ID of the calculation formular to insert after",
+                    "type": "string",
+                    "format": "uuid",
+                    "example": "123e4567-e89b-12d3-a456-426614174002"
+                },
+                "formularId": {
+                    "description": "The ID of the formular to add",
+                    "type": "string",
+                    "format": "uuid",
+                    "example": "123e4567-e89b-12d3-a456-426614174000"
+                },
+                "nextId": {
+                    "description": "Optional ID of the calculation formular to insert before",
+                    "type": "string",
+                    "format": "uuid",
+                    "example": "123e4567-e89b-12d3-a456-426614174001"
+                },
+                "pin": {
+                    "description": "Whether to pin the latest version of the formular instead of following its changes",
+                    "type": "boolean",
+                    "example": false
+                }
+            }
+        },
+        "handlers.AddNodeInput": {
+            "type": "object",
+            "required": [
+                "nodeId"
+            ],
+            "properties": {
+                "afterId": {
+                    "description": "Optional ID of the formular node to insert after",
+                    "type": "string",
+                    "format": "uuid",
+                    "example": "123e4567-e89b-12d3-a456-426614174002"
+                },
+                "nextId": {
+                    "description": "Optional ID of the formular node to insert before",
+                    "type": "string",
+                    "format": "uuid",
+                    "example": "123e4567-e89b-12d3-a456-426614174001"
+                },
+                "nodeId": {
+                    "description": "The ID of the node to add",
+                    "type": "string",
+                    "format": "uuid",
+                    "example": "123e4567-e89b-12d3-a456-426614174000"
+                }
+            }
+        },
+        "handlers.AddVariableInput": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "defaultValue": {
+                    "description": "Optional value used when no input is given",
+                    "type": "string",
+                    "maxLength": 64,
+                    "example": "1"
+                },
+                "max": {
+                    "description": "Optional inclusive upper bound",
+                    "type": "string",
+                    "maxLength": 64,
+                    "example": "1000"
+                },
+                "min": {
+                    "description": "Optional inclusive lower bound",
+                    "type": "string",
+                    "maxLength": 64,
+                    "example": "0"
+                },
+                "name": {
+                    "description": "The name used to reference the variable in nodes",
+                    "type": "string",
+                    "maxLength": 64,
+                    "example": "qty"
+                },
+                "type": {
+                    "description": "The type of the variable: number, integer or boolean (default number)",
+                    "type": "string",
+                    "enum": [
+                        "number",
+                        "integer",
+                        "boolean"
+                    ],
+                    "example": "integer"
+                }
+            }
+        },
+        "handlers.AuditEvent": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "description": "What the call did",
+                    "type": "string",
+                    "example": "update"
+                },
+                "actor": {
+                    "description": "Who made the call",
+                    "type": "string",
+                    "example": "anonymous"
+                },
+                "after": {
+                    "description": "The state after the call, null when the resource was deleted",
+                    "type": "object"
+                },
+                "before": {
+                    "description": "The state before the call, null when the resource was created",
+                    "type": "object"
+                },
+                "createdAt": {
+                    "description": "When the call was made",
+                    "type": "string",
+                    "example": "2024-01-01T00:00:00Z"
+                },
+                "id": {
+                    "description": "The ID of the event",
+                    "type": "string",
+                    "example": "123e4567-e89b-12d3-a456-426614174000"
+                },
+                "requestId": {
+                    "description": "The ID of the request, for correlating with server logs",
+                    "type": "string",
+                    "example": "host/abc-000001"
+                },
+                "resource": {
+                    "description": "The kind of the changed resource",
+                    "type": "string",
+                    "enum": [
+                        "calculation",
+                        "formular",
+                        "node",
+                        "workspace"
+                    ],
+                    "example": "calculation"
+                },
+                "resourceId": {
+                    "description": "The ID of the changed resource",
+                    "type": "string",
+                    "example": "123e4567-e89b-12d3-a456-426614174001"
+                }
+            }
+        },
+        "handlers.CompileFormularInput": {
+            "type": "object",
+            "required": [
+                "expression"
+            ],
+            "properties": {
+                "expression": {
+                    "description": "The infix expression to compile",
+                    "type": "string",
+                    "maxLength": 10000,
+                    "example": "(price * qty) - discount"
+                },
+                "replace": {
+                    "description": "Whether to replace the existing node sequence of the formular",
+                    "type": "boolean",
+                    "example": false
+                }
+            }
+        },
+        "handlers.CreateCalculationInput": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "name": {
+                    "description": "The name of the calculation",
+                    "type": "string",
+                    "maxLength": 255,
+                    "example": "My Calculation"
+                }
+            }
+        },
+        "handlers.CreateFormularInput": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "name": {
+                    "description": "The name of the formular",
+                    "type": "string",
+                    "maxLength": 255,
+                    "example": "My Formular"
+                }
+            }
+        },
+        "handlers.CreateNodeInput": {
+            "type": "object",
+            "required": [
+                "name",
+                "nodeData"
+            ],
+            "properties": {
+                "name": {
+                    "description": "The name of the node",
+                    "type": "string",
+                    "maxLength": 255,
+                    "example": "My Node"
+                },
+                "nodeData": {
+                    "description": "The node expression as a versioned nodeData JSON document",
+                    "type": "string",
+                    "maxLength": 10000,
+                    "example": "{\"version\":1,\"type\":\"constant\",\"value\":\"42\"}"
+                }
+            }
+        },
+        "handlers.CreateWorkspaceInput": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "name": {
+                    "description": "The name of the workspace",
+                    "type": "string",
+                    "maxLength": 255,
+                    "example": "Finance"
+                }
+            }
+        },
+        "handlers.Dependent": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "description": "The ID of the referencing record",
+                    "type": "string",
+                    "example": "123e4567-e89b-12d3-a456-426614174000"
+                },
+                "parentId": {
+                    "description": "The formular or calculation the referencing record belongs to",
+                    "type": "string",
+                    "example": "123e4567-e89b-12d3-a456-426614174001"
+                },
+                "type": {
+                    "description": "The kind of the referencing record",
+                    "type": "string",
+                    "enum": [
+                        "formularNode",
+                        "calculationFormular",
+                        "variable"
+                    ],
+                    "example": "formularNode"
+                }
+            }
+        },
+        "handlers.DependentsConflict": {
+            "type": "object",
+            "properties": {
+                "dependents": {
+                    "description": "The records that reference the record being deleted, in formulars and calculations the caller has a role on",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.Dependent"
+                    }
+                },
+                "hidden": {
+                    "description": "The number of further referencing records, in formulars and calculations the caller has no role on",
+                    "type": "integer",
+                    "example": 0
+                }
+            }
+        },
+        "handlers.FieldProblem": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "description": "The JSON path of the field",
+                    "type": "string",
+                    "example": "name"
+                },
+                "message": {
+                    "description": "What is wrong with the field",
+                    "type": "string",
+                    "example": "is required"
+                }
+            }
+        },
+        "handlers.FormularDiff": {
+            "type": "object",
+            "properties": {
+                "from": {
+                    "description": "The version compared from",
+                    "type": "integer",
+                    "example": 1
+                },
+                "name": {
+                    "description": "The name change, null when the name is unchanged",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/handlers.NameChange"
+                        }
+                    ]
                 },
-                "previous": {
-                    "$ref": "#/definitions/db.CalculationFormularModel"
+                "nodes": {
+                    "description": "The node changes in sequence order",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.NodeChange"
+                    }
                 },
-                "updatedAt": {
-                    "type": "string"
+                "to": {
+                    "description": "The version compared to",
+                    "type": "integer",
+                    "example": 3
                 }
             }
         },
-        "db.CalculationModel": {
+        "handlers.FormularVersion": {
             "type": "object",
             "properties": {
                 "createdAt": {
-                    "type": "string"
+                    "description": "When the version was recorded",
+                    "type": "string",
+                    "example": "2024-01-01T00:00:00Z"
                 },
-                "formulars": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/db.CalculationFormularModel"
-                    }
+                "formularId": {
+                    "description": "The ID of the formular",
+                    "type": "string",
+                    "example": "123e4567-e89b-12d3-a456-426614174001"
                 },
                 "id": {
-                    "type": "string"
+                    "description": "The ID of the version",
+                    "type": "string",
+                    "example": "123e4567-e89b-12d3-a456-426614174000"
                 },
                 "name": {
-                    "type": "string"
+                    "description": "The name of the formular",
+                    "type": "string",
+                    "example": "My Formular"
                 },
-                "updatedAt": {
-                    "type": "string"
+                "nodes": {
+                    "description": "The nodes in sequence order",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.SnapshotNode"
+                    }
+                },
+                "version": {
+                    "description": "The version number, starting at 1",
+                    "type": "integer",
+                    "example": 3
                 }
             }
         },
-        "db.FormularModel": {
+        "handlers.GrantPermissionInput": {
             "type": "object",
+            "required": [
+                "role"
+            ],
             "properties": {
-                "calculationFormulars": {
+                "role": {
+                    "description": "The role to grant: viewer, editor or owner",
+                    "type": "string",
+                    "enum": [
+                        "viewer",
+                        "editor",
+                        "owner"
+                    ],
+                    "example": "editor"
+                }
+            }
+        },
+        "handlers.IntegrityReport": {
+            "type": "object",
+            "properties": {
+                "cycles": {
+                    "description": "Members that point back to each other in a loop",
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/db.CalculationFormularModel"
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        }
                     }
                 },
-                "createdAt": {
-                    "type": "string"
+                "dangling": {
+                    "description": "Members whose next pointer leaves the sequence",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "123e4567-e89b-12d3-a456-426614174002"
+                    ]
                 },
-                "id": {
-                    "type": "string"
+                "heads": {
+                    "description": "Members that no other member points to",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "123e4567-e89b-12d3-a456-426614174000"
+                    ]
                 },
-                "name": {
-                    "type": "string"
+                "healthy": {
+                    "description": "Whether the sequence forms a single well-formed chain",
+                    "type": "boolean",
+                    "example": false
                 },
-                "nodes": {
+                "length": {
+                    "description": "The number of members in the sequence",
+                    "type": "integer",
+                    "example": 3
+                },
+                "orphans": {
+                    "description": "Members not reachable from the head of the longest chain",
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/db.FormularNodeModel"
-                    }
+                        "type": "string"
+                    },
+                    "example": [
+                        "123e4567-e89b-12d3-a456-426614174001"
+                    ]
+                }
+            }
+        },
+        "handlers.NameChange": {
+            "type": "object",
+            "properties": {
+                "from": {
+                    "description": "The name in the older version",
+                    "type": "string",
+                    "example": "Old Formular"
                 },
-                "updatedAt": {
-                    "type": "string"
+                "to": {
+                    "description": "The name in the newer version",
+                    "type": "string",
+                    "example": "New Formular"
                 }
             }
         },
-        "db.FormularNodeModel": {
+        "handlers.NodeChange": {
             "type": "object",
             "properties": {
+                "from": {
+                    "description": "The node in the from version, null for added nodes",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/handlers.SnapshotNode"
+                        }
+                    ]
+                },
+                "fromPosition": {
+                    "description": "The position in the from version, null for added nodes",
+                    "type": "integer",
+                    "example": 0
+                },
+                "op": {
+                    "description": "The kind of change",
+                    "type": "string",
+                    "enum": [
+                        "added",
+                        "removed",
+                        "modified"
+                    ],
+                    "example": "modified"
+                },
+                "to": {
+                    "description": "The node in the to version, null for removed nodes",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/handlers.SnapshotNode"
+                        }
+                    ]
+                },
+                "toPosition": {
+                    "description": "The position in the to version, null for removed nodes",
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "handlers.OrderedCalculationFormular": {
+            "type": "object",
+            "properties": {
+                "calculation": {
+                    "$ref": "#/definitions/db.CalculationModel"
+                },
+                "calculationId": {
+                    "type": "string"
+                },
                 "createdAt": {
                     "type": "string"
                 },
@@ -946,125 +5660,198 @@ const docTemplate = `{
                 "formularId": {
                     "type": "string"
                 },
+                "formularVersion": {
+                    "$ref": "#/definitions/db.FormularVersionModel"
+                },
+                "formularVersionId": {
+                    "type": "string"
+                },
                 "id": {
                     "type": "string"
                 },
+                "latestVersion": {
+                    "description": "The latest version number of the formular, null when none was recorded",
+                    "type": "integer",
+                    "example": 3
+                },
                 "next": {
-                    "$ref": "#/definitions/db.FormularNodeModel"
+                    "$ref": "#/definitions/db.CalculationFormularModel"
                 },
                 "nextId": {
                     "type": "string"
                 },
-                "node": {
-                    "$ref": "#/definitions/db.NodeModel"
+                "pinned": {
+                    "description": "Whether the formular is pinned to a version rather than floating",
+                    "type": "boolean",
+                    "example": true
                 },
-                "nodeId": {
-                    "type": "string"
+                "pinnedVersion": {
+                    "description": "The pinned version number, null for floating formulars",
+                    "type": "integer",
+                    "example": 2
+                },
+                "position": {
+                    "description": "The zero-based position in the sequence",
+                    "type": "integer",
+                    "example": 0
                 },
                 "previous": {
-                    "$ref": "#/definitions/db.FormularNodeModel"
+                    "$ref": "#/definitions/db.CalculationFormularModel"
                 },
                 "updatedAt": {
                     "type": "string"
                 }
             }
         },
-        "db.NodeModel": {
+        "handlers.OrderedFormularNode": {
             "type": "object",
             "properties": {
                 "createdAt": {
                     "type": "string"
                 },
-                "formularNodes": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/db.FormularNodeModel"
-                    }
+                "formular": {
+                    "$ref": "#/definitions/db.FormularModel"
+                },
+                "formularId": {
+                    "type": "string"
                 },
                 "id": {
                     "type": "string"
                 },
-                "name": {
+                "next": {
+                    "$ref": "#/definitions/db.FormularNodeModel"
+                },
+                "nextId": {
                     "type": "string"
                 },
-                "nodeData": {
+                "node": {
+                    "$ref": "#/definitions/db.NodeModel"
+                },
+                "nodeId": {
                     "type": "string"
                 },
+                "position": {
+                    "description": "The zero-based position in the sequence",
+                    "type": "integer",
+                    "example": 0
+                },
+                "previous": {
+                    "$ref": "#/definitions/db.FormularNodeModel"
+                },
                 "updatedAt": {
                     "type": "string"
                 }
             }
         },
-        "handlers.AddFormularInput": {
+        "handlers.Page-db_CalculationModel": {
             "type": "object",
             "properties": {
-                "formularId": {
-                    "description": "The ID of the formular to add",
+                "items": {
+                    "description": "The items on this page",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/db.CalculationModel"
+                    }
+                },
+                "nextCursor": {
+                    "description": "The cursor of the next page, null on the last page",
                     "type": "string",
                     "example": "123e4567-e89b-12d3-a456-426614174000"
                 },
-                "nextId": {
-                    "description": "Optional ID of the next formular in sequence",
-                    "type": "string",
-                    "example": "123e4567-e89b-12d3-a456-426614174001"
+                "total": {
+                    "description": "The number of items matching the filters across all pages",
+                    "type": "integer",
+                    "example": 42
                 }
             }
         },
-        "handlers.AddNodeInput": {
+        "handlers.Page-db_FormularModel": {
             "type": "object",
             "properties": {
-                "nextId": {
-                    "description": "Optional ID of the next node in sequence",
-                    "type": "string",
-                    "example": "123e4567-e89b-12d3-a456-426614174001"
+                "items": {
+                    "description": "The items on this page",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/db.FormularModel"
+                    }
                 },
-                "nodeId": {
-                    "description": "The ID of the node to add",
+                "nextCursor": {
+                    "description": "The cursor of the next page, null on the last page",
                     "type": "string",
                     "example": "123e4567-e89b-12d3-a456-426614174000"
+                },
+                "total": {
+                    "description": "The number of items matching the filters across all pages",
+                    "type": "integer",
+                    "example": 42
                 }
             }
         },
-        "handlers.CreateCalculationInput": {
+        "handlers.Page-db_NodeModel": {
             "type": "object",
             "properties": {
-                "name": {
-                    "description": "The name of the calculation",
+                "items": {
+                    "description": "The items on this page",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/db.NodeModel"
+                    }
+                },
+                "nextCursor": {
+                    "description": "The cursor of the next page, null on the last page",
                     "type": "string",
-                    "example": "My Calculation"
+                    "example": "123e4567-e89b-12d3-a456-426614174000"
+                },
+                "total": {
+                    "description": "The number of items matching the filters across all pages",
+                    "type": "integer",
+                    "example": 42
                 }
             }
         },
-        "handlers.CreateFormularInput": {
+        "handlers.Page-handlers_AuditEvent": {
             "type": "object",
             "properties": {
-                "name": {
-                    "description": "The name of the formular",
+                "items": {
+                    "description": "The items on this page",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.AuditEvent"
+                    }
+                },
+                "nextCursor": {
+                    "description": "The cursor of the next page, null on the last page",
                     "type": "string",
-                    "example": "My Formular"
+                    "example": "123e4567-e89b-12d3-a456-426614174000"
+                },
+                "total": {
+                    "description": "The number of items matching the filters across all pages",
+                    "type": "integer",
+                    "example": 42
                 }
             }
         },
-        "handlers.CreateNodeInput": {
+        "handlers.ReorderFormularsInput": {
             "type": "object",
             "properties": {
-                "name": {
-                    "description": "The name of the node",
-                    "type": "string",
-                    "example": "My Node"
-                },
-                "nodeData": {
-                    "description": "The data associated with the node",
-                    "type": "string",
-                    "example": "raw data"
+                "formularOrder": {
+                    "description": "The ordered list of formular IDs",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "['123e4567-e89b-12d3-a456-426614174000'",
+                        " '123e4567-e89b-12d3-a456-426614174001']"
+                    ]
                 }
             }
         },
-        "handlers.ReorderFormularsInput": {
+        "handlers.ReorderLinksInput": {
             "type": "object",
             "properties": {
-                "formularOrder": {
-                    "description": "The ordered list of formular IDs",
+                "linkOrder": {
+                    "description": "The ordered list of link IDs",
                     "type": "array",
                     "items": {
                         "type": "string"
@@ -1092,12 +5879,81 @@ const docTemplate = `{
                 }
             }
         },
+        "handlers.RunCalculationInput": {
+            "type": "object",
+            "properties": {
+                "inputs": {
+                    "description": "The value of each variable by name",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    },
+                    "example": {
+                        "price": "19.99",
+                        "qty": "3"
+                    }
+                }
+            }
+        },
+        "handlers.SequenceConflict": {
+            "type": "object",
+            "properties": {
+                "currentOrder": {
+                    "description": "The current order of member IDs",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "123e4567-e89b-12d3-a456-426614174000"
+                    ]
+                }
+            }
+        },
+        "handlers.SnapshotNode": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "description": "The name of the node",
+                    "type": "string",
+                    "example": "Multiply"
+                },
+                "nodeData": {
+                    "description": "The node expression as a versioned nodeData JSON document",
+                    "type": "string",
+                    "example": "{\"version\":1,\"type\":\"operator\",\"operator\":\"*\"}"
+                },
+                "nodeId": {
+                    "description": "The ID of the node",
+                    "type": "string",
+                    "example": "123e4567-e89b-12d3-a456-426614174000"
+                }
+            }
+        },
+        "handlers.UniqueConflict": {
+            "type": "object",
+            "properties": {
+                "fields": {
+                    "description": "The fields of the violated unique constraint",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "calculationId",
+                        "name"
+                    ]
+                }
+            }
+        },
         "handlers.UpdateCalculationInput": {
             "type": "object",
             "properties": {
                 "name": {
                     "description": "The new name of the calculation",
                     "type": "string",
+                    "maxLength": 255,
+                    "minLength": 1,
                     "example": "Updated Calculation"
                 }
             }
@@ -1108,6 +5964,8 @@ const docTemplate = `{
                 "name": {
                     "description": "The new name of the formular",
                     "type": "string",
+                    "maxLength": 255,
+                    "minLength": 1,
                     "example": "Updated Formular"
                 }
             }
@@ -1118,22 +5976,51 @@ const docTemplate = `{
                 "name": {
                     "description": "The new name of the node",
                     "type": "string",
+                    "maxLength": 255,
+                    "minLength": 1,
                     "example": "Updated Node"
                 },
                 "nodeData": {
-                    "description": "The new data for the node",
+                    "description": "The new node expression as a versioned nodeData JSON document",
                     "type": "string",
-                    "example": "updated data"
+                    "maxLength": 10000,
+                    "example": "{\"version\":1,\"type\":\"operator\",\"operator\":\"+\"}"
+                }
+            }
+        },
+        "handlers.ValidationError": {
+            "type": "object",
+            "properties": {
+                "fields": {
+                    "description": "The invalid fields in declaration order",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.FieldProblem"
+                    }
                 }
             }
         }
+    },
+    "securityDefinitions": {
+        "ApiKeyAuth": {
+            "description": "A static API key created with cmd/apikey",
+            "type": "apiKey",
+            "name": "X-API-Key",
+            "in": "header"
+        },
+        "BearerAuth": {
+            "description": "A JWT signed by a key of the configured JWKS, as \"Bearer \u003ctoken\u003e\"",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
+        }
     }
 }`
 
 // SwaggerInfo holds exported Swagger Info so clients can modify it
 var SwaggerInfo = &swag.Spec{
 	Version:          "1.0",
-	Host:             "localhost:8081",
+	Host:             "",
 	BasePath:         "/api",
 	Schemes:          []string{},
 	Title:            "Calculation API",
@@ -0,0 +1,160 @@
+// Package engine evaluates calculations using exact decimal arithmetic.
+package engine
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// DivisionPrecision is the number of decimal places kept when a division does not terminate
+const DivisionPrecision int32 = 16
+
+// Node is a single step of a formular
+type Node struct {
+	ID   string
+	Data string
+}
+
+// Formular is a sequence of nodes in evaluation order
+type Formular struct {
+	ID    string
+	Name  string
+	Nodes []Node
+}
+
+// NodeResult is the value on top of the stack after evaluating a node
+type NodeResult struct {
+	NodeID string          `json:"nodeId" example:"123e4567-e89b-12d3-a456-426614174000"` // The ID of the evaluated node
+	Value  decimal.Decimal `json:"value" swaggertype:"string" example:"42.5"`             // The value on top of the stack after the node
+}
+
+// FormularResult is the value produced by a single formular
+type FormularResult struct {
+	FormularID string          `json:"formularId" example:"123e4567-e89b-12d3-a456-426614174000"` // The ID of the evaluated formular
+	Name       string          `json:"name" example:"My Formular"`                                // The name of the evaluated formular
+	Value      decimal.Decimal `json:"value" swaggertype:"string" example:"42.5"`                 // The result of the formular
+	Nodes      []NodeResult    `json:"nodes"`                                                     // The intermediate result of every node
+}
+
+// Result is the outcome of evaluating a calculation
+type Result struct {
+	Value     decimal.Decimal  `json:"value" swaggertype:"string" example:"42.5"` // The result of the last formular
+	Formulars []FormularResult `json:"formulars"`                                 // The result of every formular in order
+}
+
+// Error describes why a formular could not be evaluated
+type Error struct {
+	FormularID string `json:"formularId,omitempty" example:"123e4567-e89b-12d3-a456-426614174000"` // The formular that failed, if any
+	NodeID     string `json:"nodeId,omitempty" example:"123e4567-e89b-12d3-a456-426614174001"`     // The node that failed, if any
+	Message    string `json:"message" example:"division by zero"`                                  // Why evaluation failed
+}
+
+func (e *Error) Error() string {
+	if e.FormularID == "" {
+		return e.Message
+	}
+	if e.NodeID == "" {
+		return fmt.Sprintf("formular %s: %s", e.FormularID, e.Message)
+	}
+	return fmt.Sprintf("formular %s, node %s: %s", e.FormularID, e.NodeID, e.Message)
+}
+
+// Evaluate computes every formular in order and returns the value of the last one.
+// Each formular is evaluated as a postfix expression over its nodes: constants,
+// variables and formular references push a value onto a stack, while operators
+// and functions replace their operands with the result. Variables are resolved
+// from bindings, usually produced by Bind.
+func Evaluate(formulars []Formular, bindings map[string]decimal.Decimal) (*Result, error) {
+	if len(formulars) == 0 {
+		return nil, &Error{Message: "calculation has no formulars"}
+	}
+
+	result := &Result{Formulars: make([]FormularResult, 0, len(formulars))}
+	values := make(map[string]decimal.Decimal, len(formulars))
+	for _, formular := range formulars {
+		formularResult, err := evaluateFormular(formular, bindings, values)
+		if err != nil {
+			return nil, err
+		}
+		result.Formulars = append(result.Formulars, *formularResult)
+		result.Value = formularResult.Value
+		values[formular.ID] = formularResult.Value
+	}
+
+	return result, nil
+}
+
+// evaluateFormular evaluates a single formular given the results of the formulars before it
+func evaluateFormular(formular Formular, bindings, values map[string]decimal.Decimal) (*FormularResult, error) {
+	if len(formular.Nodes) == 0 {
+		return nil, &Error{FormularID: formular.ID, Message: "formular has no nodes"}
+	}
+
+	result := &FormularResult{
+		FormularID: formular.ID,
+		Name:       formular.Name,
+		Nodes:      make([]NodeResult, 0, len(formular.Nodes)),
+	}
+
+	var stack []decimal.Decimal
+	for _, node := range formular.Nodes {
+		expr, err := ParseNodeData(node.Data)
+		if err != nil {
+			return nil, &Error{FormularID: formular.ID, NodeID: node.ID, Message: err.Error()}
+		}
+
+		stack, err = apply(stack, expr, bindings, values)
+		if err != nil {
+			return nil, &Error{FormularID: formular.ID, NodeID: node.ID, Message: err.Error()}
+		}
+		result.Nodes = append(result.Nodes, NodeResult{NodeID: node.ID, Value: stack[len(stack)-1]})
+	}
+
+	if len(stack) != 1 {
+		return nil, &Error{FormularID: formular.ID, Message: fmt.Sprintf("formular leaves %d values on the stack, expected 1", len(stack))}
+	}
+
+	result.Value = stack[0]
+	return result, nil
+}
+
+// apply evaluates a single node against the stack and returns the new stack
+func apply(stack []decimal.Decimal, expr Expr, bindings, values map[string]decimal.Decimal) ([]decimal.Decimal, error) {
+	switch e := expr.(type) {
+	case Constant:
+		return append(stack, e.Value), nil
+	case Operator:
+		if len(stack) < 2 {
+			return nil, fmt.Errorf("operator %q needs 2 operands, found %d", e.Symbol, len(stack))
+		}
+		value, err := operators[e.Symbol](stack[len(stack)-2], stack[len(stack)-1])
+		if err != nil {
+			return nil, err
+		}
+		return append(stack[:len(stack)-2], value), nil
+	case FunctionCall:
+		if len(stack) < e.Args {
+			return nil, fmt.Errorf("function %q needs %d arguments, found %d", e.Name, e.Args, len(stack))
+		}
+		args := stack[len(stack)-e.Args:]
+		value, err := functions[e.Name].call(args)
+		if err != nil {
+			return nil, err
+		}
+		return append(stack[:len(stack)-e.Args], value), nil
+	case VariableRef:
+		value, ok := bindings[e.Name]
+		if !ok {
+			return nil, fmt.Errorf("variable %q is not bound", e.Name)
+		}
+		return append(stack, value), nil
+	case FormularRef:
+		value, ok := values[e.FormularID]
+		if !ok {
+			return nil, fmt.Errorf("formular %s is not evaluated before this node", e.FormularID)
+		}
+		return append(stack, value), nil
+	}
+	return nil, fmt.Errorf("unsupported node %T", expr)
+}
@@ -0,0 +1,128 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+// compiled compiles an expression into a formular whose nodes are stored as nodeData documents
+func compiled(t *testing.T, id, expression string) Formular {
+	t.Helper()
+
+	nodes, err := Compile(expression)
+	if err != nil {
+		t.Fatalf("Compile(%q) = %v", expression, err)
+	}
+
+	formular := Formular{ID: id, Name: expression}
+	for i, node := range nodes {
+		data, err := MarshalNodeData(node.Expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		formular.Nodes = append(formular.Nodes, Node{ID: fmt.Sprintf("%s-%d", id, i), Data: data})
+	}
+	return formular
+}
+
+func TestEvaluate(t *testing.T) {
+	bindings := map[string]decimal.Decimal{
+		"price": decimal.RequireFromString("19.99"),
+		"qty":   decimal.NewFromInt(3),
+		"zero":  decimal.Zero,
+	}
+
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{"0.1 + 0.2", "0.3"},
+		{"price * qty - 5", "54.97"},
+		{"-price", "-19.99"},
+		{"10 / 4", "2.5"},
+		{"1 / 3", "0.3333333333333333"},
+		{"2 / 3", "0.6666666666666667"},
+		{"-7 % 3", "-1"},
+		{"7.5 % 2", "1.5"},
+		{"abs(-2) + floor(1.9) + ceil(1.1)", "5"},
+		{"min(3, qty, 1) + max(price, 20)", "21"},
+		{"round(2.5)", "3"},
+		{"round(-2.5)", "-3"},
+		{"round(1.23456, 0)", "1"},
+		{"round(1.23456, 2)", "1.23"},
+		{"round(1.23456, 64)", "1.23456"},
+		{"round(1 / 3, 64)", "0.3333333333333333"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			result, err := Evaluate([]Formular{compiled(t, "f1", test.expression)}, bindings)
+			if err != nil {
+				t.Fatalf("Evaluate(%q) = %v", test.expression, err)
+			}
+			if want := decimal.RequireFromString(test.want); !result.Value.Equal(want) {
+				t.Fatalf("Evaluate(%q) = %s, want %s", test.expression, result.Value, want)
+			}
+		})
+	}
+}
+
+func TestEvaluateFails(t *testing.T) {
+	bindings := map[string]decimal.Decimal{
+		"zero": decimal.Zero,
+	}
+
+	tests := []struct {
+		expression string
+		message    string // A part of the expected message
+	}{
+		{"1 / 0", "division by zero"},
+		{"1 / zero", "division by zero"},
+		{"1 / (2 - 2)", "division by zero"},
+		{"1 % 0", "modulo by zero"},
+		{"1 % zero", "modulo by zero"},
+		{"round(1, -1)", "round: places must be between 0 and 64, got -1"},
+		{"round(1, 65)", "round: places must be between 0 and 64, got 65"},
+		{"round(1, 4294967296)", "round: places must be between 0 and 64, got 4294967296"},
+		{"round(1, 1.5)", "round: places must be an integer, got 1.5"},
+		{"qty * 2", `variable "qty" is not bound`},
+		{"@{f0} + 1", "formular f0 is not evaluated before this node"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			_, err := Evaluate([]Formular{compiled(t, "f1", test.expression)}, bindings)
+
+			evalErr, ok := err.(*Error)
+			if !ok {
+				t.Fatalf("Evaluate(%q) = %v, want an *Error", test.expression, err)
+			}
+			if evalErr.FormularID != "f1" || evalErr.NodeID == "" || !strings.Contains(evalErr.Message, test.message) {
+				t.Fatalf("Evaluate(%q) = %v, want a node of f1 failing with %q", test.expression, err, test.message)
+			}
+		})
+	}
+}
+
+func TestEvaluateReferencesEarlierFormulars(t *testing.T) {
+	formulars := []Formular{
+		compiled(t, "f1", "2 * 3"),
+		compiled(t, "f2", "@{f1} + 1"),
+		compiled(t, "f3", "@{f1} * @{f2}"),
+	}
+
+	result, err := Evaluate(formulars, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !result.Value.Equal(decimal.NewFromInt(42)) || len(result.Formulars) != 3 {
+		t.Fatalf("result = %s with %d formulars, want 42 with 3", result.Value, len(result.Formulars))
+	}
+	if nodes := result.Formulars[2].Nodes; len(nodes) != 3 || !nodes[1].Value.Equal(decimal.NewFromInt(7)) {
+		t.Fatalf("node results of f3 = %+v, want 6, 7 and 42", nodes)
+	}
+}
@@ -0,0 +1,112 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/shopspring/decimal"
+)
+
+// operators maps operator symbols to their implementation
+var operators = map[string]func(left, right decimal.Decimal) (decimal.Decimal, error){
+	"+": func(left, right decimal.Decimal) (decimal.Decimal, error) {
+		return left.Add(right), nil
+	},
+	"-": func(left, right decimal.Decimal) (decimal.Decimal, error) {
+		return left.Sub(right), nil
+	},
+	"*": func(left, right decimal.Decimal) (decimal.Decimal, error) {
+		return left.Mul(right), nil
+	},
+	"/": func(left, right decimal.Decimal) (decimal.Decimal, error) {
+		if right.IsZero() {
+			return decimal.Decimal{}, fmt.Errorf("division by zero")
+		}
+		return left.DivRound(right, DivisionPrecision), nil
+	},
+	"%": func(left, right decimal.Decimal) (decimal.Decimal, error) {
+		if right.IsZero() {
+			return decimal.Decimal{}, fmt.Errorf("modulo by zero")
+		}
+		return left.Mod(right), nil
+	},
+}
+
+// function describes a callable function and the number of arguments it accepts
+type function struct {
+	minArgs int
+	maxArgs int // -1 for variadic functions
+	call    func(args []decimal.Decimal) (decimal.Decimal, error)
+}
+
+func (f function) checkArity(args int) error {
+	if args < f.minArgs || (f.maxArgs >= 0 && args > f.maxArgs) {
+		switch {
+		case f.maxArgs < 0:
+			return fmt.Errorf("expects at least %d arguments, got %d", f.minArgs, args)
+		case f.minArgs == f.maxArgs:
+			return fmt.Errorf("expects %d arguments, got %d", f.minArgs, args)
+		default:
+			return fmt.Errorf("expects %d to %d arguments, got %d", f.minArgs, f.maxArgs, args)
+		}
+	}
+	return nil
+}
+
+// maxRoundPlaces bounds the places of round, which would otherwise wrap around when converted or
+// allocate huge numbers
+const maxRoundPlaces = 64
+
+// functions maps function names to their implementation
+var functions = map[string]function{
+	"abs": {minArgs: 1, maxArgs: 1, call: func(args []decimal.Decimal) (decimal.Decimal, error) {
+		return args[0].Abs(), nil
+	}},
+	"neg": {minArgs: 1, maxArgs: 1, call: func(args []decimal.Decimal) (decimal.Decimal, error) {
+		return args[0].Neg(), nil
+	}},
+	"floor": {minArgs: 1, maxArgs: 1, call: func(args []decimal.Decimal) (decimal.Decimal, error) {
+		return args[0].Floor(), nil
+	}},
+	"ceil": {minArgs: 1, maxArgs: 1, call: func(args []decimal.Decimal) (decimal.Decimal, error) {
+		return args[0].Ceil(), nil
+	}},
+	"round": {minArgs: 1, maxArgs: 2, call: func(args []decimal.Decimal) (decimal.Decimal, error) {
+		if len(args) == 1 {
+			return args[0].Round(0), nil
+		}
+		if !args[1].IsInteger() {
+			return decimal.Decimal{}, fmt.Errorf("round: places must be an integer, got %s", args[1])
+		}
+		if args[1].LessThan(decimal.Zero) || args[1].GreaterThan(decimal.NewFromInt(maxRoundPlaces)) {
+			return decimal.Decimal{}, fmt.Errorf("round: places must be between 0 and %d, got %s", maxRoundPlaces, args[1])
+		}
+		return args[0].Round(int32(args[1].IntPart())), nil
+	}},
+	"min": {minArgs: 1, maxArgs: -1, call: func(args []decimal.Decimal) (decimal.Decimal, error) {
+		return decimal.Min(args[0], args[1:]...), nil
+	}},
+	"max": {minArgs: 1, maxArgs: -1, call: func(args []decimal.Decimal) (decimal.Decimal, error) {
+		return decimal.Max(args[0], args[1:]...), nil
+	}},
+}
+
+// operatorSymbols returns the supported operator symbols in a stable order
+func operatorSymbols() []string {
+	symbols := make([]string, 0, len(operators))
+	for symbol := range operators {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// functionNames returns the supported function names in a stable order
+func functionNames() []string {
+	names := make([]string, 0, len(functions))
+	for name := range functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,266 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// NodeDataVersion is the version of the nodeData schema understood by this package
+const NodeDataVersion = 1
+
+// Node types supported by the nodeData schema
+const (
+	TypeConstant = "constant"
+	TypeOperator = "operator"
+	TypeFunction = "function"
+	TypeVariable = "variable"
+	TypeFormular = "formular"
+)
+
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Expr is the parsed form of a node's nodeData
+type Expr interface {
+	expr()
+}
+
+// Constant pushes a fixed value
+type Constant struct {
+	Value decimal.Decimal
+}
+
+// Operator replaces the top two values with the result of a binary operation
+type Operator struct {
+	Symbol string
+}
+
+// FunctionCall replaces the top Args values with the result of a function
+type FunctionCall struct {
+	Name string
+	Args int
+}
+
+// VariableRef pushes the value bound to a named input variable
+type VariableRef struct {
+	Name string
+}
+
+// FormularRef pushes the result of a formular evaluated earlier in the calculation
+type FormularRef struct {
+	FormularID string
+}
+
+func (Constant) expr()     {}
+func (Operator) expr()     {}
+func (FunctionCall) expr() {}
+func (VariableRef) expr()  {}
+func (FormularRef) expr()  {}
+
+// FieldError describes the nodeData field that failed to parse
+type FieldError struct {
+	Field   string `json:"field,omitempty" example:"operator"` // The offending nodeData field, empty for the whole document
+	Message string `json:"message" example:"is required"`      // What is wrong with the field
+}
+
+func (e *FieldError) Error() string {
+	if e.Field == "" {
+		return "nodeData: " + e.Message
+	}
+	return "nodeData." + e.Field + ": " + e.Message
+}
+
+// nodeData is the wire format of Node.nodeData
+type nodeData struct {
+	Version    *int            `json:"version"`
+	Type       string          `json:"type"`
+	Value      json.RawMessage `json:"value"`
+	Operator   string          `json:"operator"`
+	Function   string          `json:"function"`
+	Args       *int            `json:"args"`
+	Variable   string          `json:"variable"`
+	FormularID string          `json:"formularId"`
+}
+
+// ParseNodeData parses and validates a nodeData JSON document.
+// Errors are returned as *FieldError naming the offending field.
+func ParseNodeData(data string) (Expr, error) {
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var raw nodeData
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, decodeError(err)
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return nil, &FieldError{Message: "unexpected data after JSON object"}
+	}
+
+	if raw.Version == nil {
+		return nil, &FieldError{Field: "version", Message: "is required"}
+	}
+	if *raw.Version != NodeDataVersion {
+		return nil, &FieldError{Field: "version", Message: fmt.Sprintf("unsupported version %d, expected %d", *raw.Version, NodeDataVersion)}
+	}
+
+	var allowed []string
+	var expr Expr
+
+	switch raw.Type {
+	case TypeConstant:
+		allowed = []string{"value"}
+		if len(raw.Value) == 0 {
+			return nil, &FieldError{Field: "value", Message: "is required"}
+		}
+		var value decimal.Decimal
+		if err := value.UnmarshalJSON(raw.Value); err != nil || bytes.Equal(raw.Value, []byte("null")) {
+			return nil, &FieldError{Field: "value", Message: "must be a decimal number"}
+		}
+		expr = Constant{Value: value}
+	case TypeOperator:
+		allowed = []string{"operator"}
+		if raw.Operator == "" {
+			return nil, &FieldError{Field: "operator", Message: "is required"}
+		}
+		if _, ok := operators[raw.Operator]; !ok {
+			return nil, &FieldError{Field: "operator", Message: fmt.Sprintf("unknown operator %q, expected one of %s", raw.Operator, strings.Join(operatorSymbols(), ", "))}
+		}
+		expr = Operator{Symbol: raw.Operator}
+	case TypeFunction:
+		allowed = []string{"function", "args"}
+		if raw.Function == "" {
+			return nil, &FieldError{Field: "function", Message: "is required"}
+		}
+		fn, ok := functions[raw.Function]
+		if !ok {
+			return nil, &FieldError{Field: "function", Message: fmt.Sprintf("unknown function %q, expected one of %s", raw.Function, strings.Join(functionNames(), ", "))}
+		}
+		if raw.Args == nil {
+			return nil, &FieldError{Field: "args", Message: "is required"}
+		}
+		if err := fn.checkArity(*raw.Args); err != nil {
+			return nil, &FieldError{Field: "args", Message: err.Error()}
+		}
+		expr = FunctionCall{Name: raw.Function, Args: *raw.Args}
+	case TypeVariable:
+		allowed = []string{"variable"}
+		if raw.Variable == "" {
+			return nil, &FieldError{Field: "variable", Message: "is required"}
+		}
+		if !identifierPattern.MatchString(raw.Variable) {
+			return nil, &FieldError{Field: "variable", Message: "must start with a letter or underscore and contain only letters, digits and underscores"}
+		}
+		expr = VariableRef{Name: raw.Variable}
+	case TypeFormular:
+		allowed = []string{"formularId"}
+		if raw.FormularID == "" {
+			return nil, &FieldError{Field: "formularId", Message: "is required"}
+		}
+		expr = FormularRef{FormularID: raw.FormularID}
+	case "":
+		return nil, &FieldError{Field: "type", Message: "is required"}
+	default:
+		return nil, &FieldError{Field: "type", Message: fmt.Sprintf("unknown type %q, expected one of %s", raw.Type, strings.Join([]string{TypeConstant, TypeOperator, TypeFunction, TypeVariable, TypeFormular}, ", "))}
+	}
+
+	if field := unexpectedField(raw, allowed); field != "" {
+		return nil, &FieldError{Field: field, Message: fmt.Sprintf("is not allowed for type %q", raw.Type)}
+	}
+
+	return expr, nil
+}
+
+// ParseLegacyNodeData parses the free-text nodeData written before the versioned schema existed,
+// which is one of the operators +, -, * and / or a decimal number. It reports false for anything
+// else, including versioned documents.
+func ParseLegacyNodeData(data string) (Expr, bool) {
+	switch data {
+	case "+", "-", "*", "/":
+		return Operator{Symbol: data}, true
+	}
+
+	value, err := decimal.NewFromString(data)
+	if err != nil {
+		return nil, false
+	}
+	return Constant{Value: value}, true
+}
+
+// MarshalNodeData encodes an expression as a nodeData JSON document of the current version
+func MarshalNodeData(expr Expr) (string, error) {
+	raw := map[string]any{"version": NodeDataVersion}
+
+	switch e := expr.(type) {
+	case Constant:
+		raw["type"], raw["value"] = TypeConstant, e.Value.String()
+	case Operator:
+		raw["type"], raw["operator"] = TypeOperator, e.Symbol
+	case FunctionCall:
+		raw["type"], raw["function"], raw["args"] = TypeFunction, e.Name, e.Args
+	case VariableRef:
+		raw["type"], raw["variable"] = TypeVariable, e.Name
+	case FormularRef:
+		raw["type"], raw["formularId"] = TypeFormular, e.FormularID
+	default:
+		return "", fmt.Errorf("unsupported node %T", expr)
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// unexpectedField returns the first populated field that does not belong to the node type
+func unexpectedField(raw nodeData, allowed []string) string {
+	present := map[string]bool{
+		"value":      len(raw.Value) > 0,
+		"operator":   raw.Operator != "",
+		"function":   raw.Function != "",
+		"args":       raw.Args != nil,
+		"variable":   raw.Variable != "",
+		"formularId": raw.FormularID != "",
+	}
+	for _, field := range allowed {
+		delete(present, field)
+	}
+	for _, field := range []string{"value", "operator", "function", "args", "variable", "formularId"} {
+		if present[field] {
+			return field
+		}
+	}
+	return ""
+}
+
+// decodeError converts a JSON decoding error into a field error
+func decodeError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		if typeErr.Field == "" {
+			return &FieldError{Message: "must be a JSON object"}
+		}
+		return &FieldError{Field: typeErr.Field, Message: fmt.Sprintf("must be of type %s", typeErr.Type)}
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return &FieldError{Message: fmt.Sprintf("invalid JSON at offset %d: %s", syntaxErr.Offset, syntaxErr.Error())}
+	}
+
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return &FieldError{Field: strings.Trim(field, `"`), Message: "is not a known field"}
+	}
+
+	if errors.Is(err, io.EOF) {
+		return &FieldError{Message: "must be a JSON object"}
+	}
+
+	return &FieldError{Message: err.Error()}
+}
@@ -0,0 +1,314 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/shopspring/decimal"
+)
+
+// CompiledNode is a node produced by Compile, in evaluation order
+type CompiledNode struct {
+	Name string // The source text of the node, used as the node name
+	Expr Expr
+}
+
+// SyntaxError describes where an expression failed to parse
+type SyntaxError struct {
+	Line    int    `json:"line" example:"1"`                               // The one-based line of the offending token
+	Column  int    `json:"column" example:"7"`                             // The one-based column of the offending token
+	Message string `json:"message" example:"unexpected end of expression"` // What is wrong at that position
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenIdent
+	tokenFormular
+	tokenOperator
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+type token struct {
+	kind   tokenKind
+	text   string
+	line   int
+	column int
+}
+
+// Compile parses an infix expression such as "(price * qty) - discount" and
+// returns its nodes in postfix order, ready to be linked into a formular.
+//
+// Numbers become constants, identifiers become variable references, name(...)
+// calls a function and @{id} references the result of another formular.
+// Numbers are written in plain decimal notation: exponent notation such as 1e5
+// is rejected, write 100000 instead.
+// Multiplicative operators bind tighter than additive ones and a leading minus
+// negates its operand.
+func Compile(expression string) ([]CompiledNode, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokenEOF {
+		return nil, p.errorf(p.peek(), "expression is empty")
+	}
+	if err := p.parseExpression(); err != nil {
+		return nil, err
+	}
+	if next := p.peek(); next.kind != tokenEOF {
+		return nil, p.errorf(next, "unexpected %q", next.text)
+	}
+
+	return p.output, nil
+}
+
+// tokenize splits an expression into tokens, tracking the line and column of each
+func tokenize(expression string) ([]token, error) {
+	var tokens []token
+	runes := []rune(expression)
+	line, column := 1, 1
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		start := token{line: line, column: column}
+
+		advance := func(n int) string {
+			text := string(runes[i : i+n])
+			for _, c := range runes[i : i+n] {
+				if c == '\n' {
+					line++
+					column = 1
+				} else {
+					column++
+				}
+			}
+			i += n
+			return text
+		}
+
+		switch {
+		case unicode.IsSpace(r):
+			advance(1)
+			continue
+		case isDigit(r) || r == '.':
+			n := 0
+			for i+n < len(runes) && (isDigit(runes[i+n]) || runes[i+n] == '.') {
+				n++
+			}
+			// A number running into letters is most likely exponent notation such as 1e5
+			if i+n < len(runes) && isLetter(runes[i+n]) {
+				for i+n < len(runes) && (isLetter(runes[i+n]) || isDigit(runes[i+n]) || runes[i+n] == '.') {
+					n++
+				}
+				return nil, &SyntaxError{Line: start.line, Column: start.column, Message: fmt.Sprintf("invalid number %q, exponent notation is not supported", string(runes[i:i+n]))}
+			}
+			start.kind, start.text = tokenNumber, advance(n)
+			if _, err := decimal.NewFromString(start.text); err != nil {
+				return nil, &SyntaxError{Line: start.line, Column: start.column, Message: fmt.Sprintf("invalid number %q", start.text)}
+			}
+		case isLetter(r):
+			n := 0
+			for i+n < len(runes) && (isLetter(runes[i+n]) || isDigit(runes[i+n])) {
+				n++
+			}
+			start.kind, start.text = tokenIdent, advance(n)
+		case r == '@':
+			if i+1 >= len(runes) || runes[i+1] != '{' {
+				return nil, &SyntaxError{Line: start.line, Column: start.column, Message: "expected '{' after '@'"}
+			}
+			n := 2
+			for i+n < len(runes) && runes[i+n] != '}' {
+				n++
+			}
+			if i+n >= len(runes) {
+				return nil, &SyntaxError{Line: start.line, Column: start.column, Message: "unterminated formular reference, expected '}'"}
+			}
+			start.kind, start.text = tokenFormular, advance(n+1)
+			if strings.TrimSpace(start.text[2:len(start.text)-1]) == "" {
+				return nil, &SyntaxError{Line: start.line, Column: start.column, Message: "formular reference needs an ID"}
+			}
+		case strings.ContainsRune("+-*/%", r):
+			start.kind, start.text = tokenOperator, advance(1)
+		case r == '(':
+			start.kind, start.text = tokenLParen, advance(1)
+		case r == ')':
+			start.kind, start.text = tokenRParen, advance(1)
+		case r == ',':
+			start.kind, start.text = tokenComma, advance(1)
+		default:
+			return nil, &SyntaxError{Line: start.line, Column: start.column, Message: fmt.Sprintf("unexpected character %q", r)}
+		}
+
+		tokens = append(tokens, start)
+	}
+
+	return append(tokens, token{kind: tokenEOF, text: "end of expression", line: line, column: column}), nil
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func isLetter(r rune) bool {
+	return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+// maxDepth bounds how deeply expressions may nest, through parentheses, calls and unary
+// operators, so that deeply nested input cannot exhaust the stack of the recursive parser
+const maxDepth = 100
+
+// parser is a recursive descent parser emitting nodes in postfix order
+type parser struct {
+	tokens []token
+	pos    int
+	depth  int
+	output []CompiledNode
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) emit(name string, expr Expr) {
+	p.output = append(p.output, CompiledNode{Name: name, Expr: expr})
+}
+
+func (p *parser) errorf(t token, format string, args ...any) error {
+	return &SyntaxError{Line: t.line, Column: t.column, Message: fmt.Sprintf(format, args...)}
+}
+
+// parseExpression parses additive expressions: term (('+' | '-') term)*
+func (p *parser) parseExpression() error {
+	if err := p.parseTerm(); err != nil {
+		return err
+	}
+	for t := p.peek(); t.kind == tokenOperator && (t.text == "+" || t.text == "-"); t = p.peek() {
+		p.next()
+		if err := p.parseTerm(); err != nil {
+			return err
+		}
+		p.emit(t.text, Operator{Symbol: t.text})
+	}
+	return nil
+}
+
+// parseTerm parses multiplicative expressions: unary (('*' | '/' | '%') unary)*
+func (p *parser) parseTerm() error {
+	if err := p.parseUnary(); err != nil {
+		return err
+	}
+	for t := p.peek(); t.kind == tokenOperator && (t.text == "*" || t.text == "/" || t.text == "%"); t = p.peek() {
+		p.next()
+		if err := p.parseUnary(); err != nil {
+			return err
+		}
+		p.emit(t.text, Operator{Symbol: t.text})
+	}
+	return nil
+}
+
+// parseUnary parses an optionally negated primary expression. Every level of nesting passes
+// through it, so it enforces maxDepth.
+func (p *parser) parseUnary() error {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxDepth {
+		return p.errorf(p.peek(), "expression nests deeper than %d levels", maxDepth)
+	}
+
+	if t := p.peek(); t.kind == tokenOperator && (t.text == "-" || t.text == "+") {
+		p.next()
+		if err := p.parseUnary(); err != nil {
+			return err
+		}
+		if t.text == "-" {
+			p.emit("neg", FunctionCall{Name: "neg", Args: 1})
+		}
+		return nil
+	}
+	return p.parsePrimary()
+}
+
+// parsePrimary parses numbers, variables, function calls, formular references and parentheses
+func (p *parser) parsePrimary() error {
+	t := p.next()
+	switch t.kind {
+	case tokenNumber:
+		p.emit(t.text, Constant{Value: decimal.RequireFromString(t.text)})
+		return nil
+	case tokenFormular:
+		id := strings.TrimSpace(t.text[2 : len(t.text)-1])
+		p.emit(t.text, FormularRef{FormularID: id})
+		return nil
+	case tokenIdent:
+		if p.peek().kind == tokenLParen {
+			return p.parseCall(t)
+		}
+		p.emit(t.text, VariableRef{Name: t.text})
+		return nil
+	case tokenLParen:
+		if err := p.parseExpression(); err != nil {
+			return err
+		}
+		if closing := p.next(); closing.kind != tokenRParen {
+			return p.errorf(closing, "expected ')' to close '(' at line %d, column %d, found %q", t.line, t.column, closing.text)
+		}
+		return nil
+	case tokenEOF:
+		return p.errorf(t, "unexpected end of expression")
+	}
+	return p.errorf(t, "unexpected %q", t.text)
+}
+
+// parseCall parses the argument list of a function call
+func (p *parser) parseCall(name token) error {
+	fn, ok := functions[name.text]
+	if !ok {
+		return p.errorf(name, "unknown function %q, expected one of %s", name.text, strings.Join(functionNames(), ", "))
+	}
+
+	p.next() // consume '('
+	args := 0
+	if p.peek().kind != tokenRParen {
+		for {
+			if err := p.parseExpression(); err != nil {
+				return err
+			}
+			args++
+			if p.peek().kind != tokenComma {
+				break
+			}
+			p.next()
+		}
+	}
+	if closing := p.next(); closing.kind != tokenRParen {
+		return p.errorf(closing, "expected ')' to close call to %s, found %q", name.text, closing.text)
+	}
+
+	if err := fn.checkArity(args); err != nil {
+		return p.errorf(name, "%s %s", name.text, err)
+	}
+
+	p.emit(name.text, FunctionCall{Name: name.text, Args: args})
+	return nil
+}
@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		expression string
+		postfix    string // The names of the compiled nodes, space separated
+	}{
+		{"42", "42"},
+		{"1.5 + x", "1.5 x +"},
+		{"(price * qty) - discount", "price qty * discount -"},
+		{"a + b * c", "a b c * +"},
+		{"a - b - c", "a b - c -"},
+		{"a % b / c", "a b % c /"},
+		{"-x", "x neg"},
+		{"+x", "x"},
+		{"--x", "x neg neg"},
+		{"round(x, 2)", "x 2 round"},
+		{"max(1, 2, 3)", "1 2 3 max"},
+		{"@{f1} * 2", "@{f1} 2 *"},
+		{strings.Repeat("(", maxDepth-1) + "1" + strings.Repeat(")", maxDepth-1), "1"},
+		{strings.Repeat("+", maxDepth-1) + "1", "1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			nodes, err := Compile(test.expression)
+			if err != nil {
+				t.Fatalf("Compile(%q) = %v", test.expression, err)
+			}
+			names := make([]string, 0, len(nodes))
+			for _, node := range nodes {
+				names = append(names, node.Name)
+			}
+			if got := strings.Join(names, " "); got != test.postfix {
+				t.Fatalf("Compile(%q) = %q, want %q", test.expression, got, test.postfix)
+			}
+		})
+	}
+}
+
+func TestCompileRejectsInvalidExpressions(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		line       int
+		column     int
+		message    string // A part of the expected message
+	}{
+		{"empty", "  ", 1, 3, "expression is empty"},
+		{"trailing operator", "1 +", 1, 4, "unexpected end of expression"},
+		{"unclosed parenthesis", "(1 + 2", 1, 7, "expected ')'"},
+		{"extra parenthesis", "1 + 2)", 1, 6, `unexpected ")"`},
+		{"unknown function", "sqrt(4)", 1, 1, `unknown function "sqrt"`},
+		{"wrong arity", "abs(1, 2)", 1, 1, "abs expects 1 arguments, got 2"},
+		{"invalid number", "1.2.3", 1, 1, `invalid number "1.2.3"`},
+		{"exponent notation", "1e5", 1, 1, `invalid number "1e5", exponent notation is not supported`},
+		{"exponent notation with sign", "2 * 1.5E-3", 1, 5, `invalid number "1.5E", exponent notation is not supported`},
+		{"unknown character", "1 $ 2", 1, 3, `unexpected character '$'`},
+		{"empty formular reference", "@{ }", 1, 1, "formular reference needs an ID"},
+		{"unterminated formular reference", "@{f1", 1, 1, "unterminated formular reference"},
+		{"second line", "1 +\n* 2", 2, 1, `unexpected "*"`},
+		{"nested parentheses", strings.Repeat("(", maxDepth) + "1" + strings.Repeat(")", maxDepth), 1, maxDepth + 1, "nests deeper than 100 levels"},
+		{"nested negations", strings.Repeat("-", maxDepth) + "1", 1, maxDepth + 1, "nests deeper than 100 levels"},
+		{"nested calls", strings.Repeat("abs(", maxDepth) + "1" + strings.Repeat(")", maxDepth), 1, 4*maxDepth + 1, "nests deeper than 100 levels"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Compile(test.expression)
+
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("Compile(%q) = %v, want a *SyntaxError", test.expression, err)
+			}
+			if syntaxErr.Line != test.line || syntaxErr.Column != test.column || !strings.Contains(syntaxErr.Message, test.message) {
+				t.Fatalf("Compile(%q) = %v, want line %d, column %d: %s", test.expression, err, test.line, test.column, test.message)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// Variable types
+const (
+	VariableNumber  = "number"
+	VariableInteger = "integer"
+	VariableBoolean = "boolean"
+)
+
+// Variable is a named input of a calculation
+type Variable struct {
+	Name    string
+	Type    string
+	Default *decimal.Decimal
+	Min     *decimal.Decimal
+	Max     *decimal.Decimal
+}
+
+// NewVariable validates a variable definition whose bounds and default are given as decimal strings
+func NewVariable(name, typ string, defaultValue, min, max *string) (Variable, error) {
+	v := Variable{Name: name, Type: typ}
+
+	if !identifierPattern.MatchString(name) {
+		return v, fmt.Errorf("name must start with a letter or underscore and contain only letters, digits and underscores")
+	}
+
+	switch typ {
+	case VariableNumber, VariableInteger, VariableBoolean:
+	default:
+		return v, fmt.Errorf("unknown type %q, expected one of %s", typ, strings.Join([]string{VariableNumber, VariableInteger, VariableBoolean}, ", "))
+	}
+
+	fields := []struct {
+		name  string
+		value *string
+		dest  **decimal.Decimal
+	}{
+		{"min", min, &v.Min},
+		{"max", max, &v.Max},
+		{"defaultValue", defaultValue, &v.Default},
+	}
+	for _, field := range fields {
+		if field.value == nil {
+			continue
+		}
+		value, err := decimal.NewFromString(*field.value)
+		if err != nil {
+			return v, fmt.Errorf("%s must be a decimal number", field.name)
+		}
+		*field.dest = &value
+	}
+
+	if typ == VariableBoolean && (v.Min != nil || v.Max != nil) {
+		return v, fmt.Errorf("min and max are not allowed for boolean variables")
+	}
+	if v.Min != nil && v.Max != nil && v.Min.GreaterThan(*v.Max) {
+		return v, fmt.Errorf("min must not be greater than max")
+	}
+	if v.Default != nil {
+		if err := v.check(*v.Default); err != nil {
+			return v, fmt.Errorf("defaultValue %s", err)
+		}
+	}
+
+	return v, nil
+}
+
+// check verifies that a value is valid for the variable's type and range
+func (v Variable) check(value decimal.Decimal) error {
+	switch v.Type {
+	case VariableInteger:
+		if !value.IsInteger() {
+			return fmt.Errorf("must be an integer")
+		}
+	case VariableBoolean:
+		if !value.Equal(decimal.Zero) && !value.Equal(decimal.NewFromInt(1)) {
+			return fmt.Errorf("must be a boolean")
+		}
+	}
+	if v.Min != nil && value.LessThan(*v.Min) {
+		return fmt.Errorf("must be at least %s", v.Min)
+	}
+	if v.Max != nil && value.GreaterThan(*v.Max) {
+		return fmt.Errorf("must be at most %s", v.Max)
+	}
+	return nil
+}
+
+// parse converts a JSON input value into a decimal according to the variable's type
+func (v Variable) parse(raw json.RawMessage) (decimal.Decimal, error) {
+	if v.Type == VariableBoolean {
+		var b bool
+		if err := json.Unmarshal(raw, &b); err != nil {
+			return decimal.Decimal{}, fmt.Errorf("must be a boolean")
+		}
+		if b {
+			return decimal.NewFromInt(1), nil
+		}
+		return decimal.Zero, nil
+	}
+
+	var value decimal.Decimal
+	if err := value.UnmarshalJSON(raw); err != nil || string(raw) == "null" {
+		return decimal.Decimal{}, fmt.Errorf("must be a decimal number")
+	}
+	return value, nil
+}
+
+// InputProblem describes a single missing or invalid input
+type InputProblem struct {
+	Name    string `json:"name" example:"qty"`            // The name of the input
+	Message string `json:"message" example:"is required"` // What is wrong with the input
+}
+
+// InputError lists every input that could not be bound
+type InputError struct {
+	Problems []InputProblem `json:"problems"` // Every missing, invalid or unknown input
+}
+
+func (e *InputError) Error() string {
+	messages := make([]string, 0, len(e.Problems))
+	for _, problem := range e.Problems {
+		messages = append(messages, problem.Name+" "+problem.Message)
+	}
+	return "invalid inputs: " + strings.Join(messages, "; ")
+}
+
+// Bind resolves the value of every variable from the given inputs, falling back to defaults.
+// All missing, unknown and out-of-range inputs are reported together as an *InputError.
+func Bind(variables []Variable, inputs map[string]json.RawMessage) (map[string]decimal.Decimal, error) {
+	bindings := make(map[string]decimal.Decimal, len(variables))
+	declared := make(map[string]bool, len(variables))
+	var problems []InputProblem
+
+	for _, v := range variables {
+		declared[v.Name] = true
+
+		raw, ok := inputs[v.Name]
+		if !ok {
+			if v.Default == nil {
+				problems = append(problems, InputProblem{Name: v.Name, Message: "is required"})
+				continue
+			}
+			bindings[v.Name] = *v.Default
+			continue
+		}
+
+		value, err := v.parse(raw)
+		if err == nil {
+			err = v.check(value)
+		}
+		if err != nil {
+			problems = append(problems, InputProblem{Name: v.Name, Message: err.Error()})
+			continue
+		}
+		bindings[v.Name] = value
+	}
+
+	var unknown []string
+	for name := range inputs {
+		if !declared[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		problems = append(problems, InputProblem{Name: name, Message: "is not a variable of this calculation"})
+	}
+
+	if len(problems) > 0 {
+		return nil, &InputError{Problems: problems}
+	}
+	return bindings, nil
+}
@@ -0,0 +1,142 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestBind(t *testing.T) {
+	variable := func(name, typ string, defaultValue, min, max *string) Variable {
+		t.Helper()
+		v, err := NewVariable(name, typ, defaultValue, min, max)
+		if err != nil {
+			t.Fatalf("NewVariable(%q) = %v", name, err)
+		}
+		return v
+	}
+	text := func(s string) *string { return &s }
+
+	variables := []Variable{
+		variable("price", VariableNumber, nil, text("0"), nil),
+		variable("qty", VariableInteger, text("1"), text("1"), text("100")),
+		variable("member", VariableBoolean, text("0"), nil, nil),
+	}
+
+	tests := []struct {
+		name     string
+		inputs   string
+		want     map[string]string // The bound values, nil when binding fails
+		problems []InputProblem
+	}{
+		{
+			name:   "defaults",
+			inputs: `{"price": "19.99"}`,
+			want:   map[string]string{"price": "19.99", "qty": "1", "member": "0"},
+		},
+		{
+			name:   "every input",
+			inputs: `{"price": 5, "qty": 3, "member": true}`,
+			want:   map[string]string{"price": "5", "qty": "3", "member": "1"},
+		},
+		{
+			name:     "missing input without default",
+			inputs:   `{}`,
+			problems: []InputProblem{{Name: "price", Message: "is required"}},
+		},
+		{
+			name:   "invalid inputs",
+			inputs: `{"price": "cheap", "qty": 1.5, "member": 1}`,
+			problems: []InputProblem{
+				{Name: "price", Message: "must be a decimal number"},
+				{Name: "qty", Message: "must be an integer"},
+				{Name: "member", Message: "must be a boolean"},
+			},
+		},
+		{
+			name:   "out of range",
+			inputs: `{"price": -1, "qty": 101}`,
+			problems: []InputProblem{
+				{Name: "price", Message: "must be at least 0"},
+				{Name: "qty", Message: "must be at most 100"},
+			},
+		},
+		{
+			name:     "null input",
+			inputs:   `{"price": null}`,
+			problems: []InputProblem{{Name: "price", Message: "must be a decimal number"}},
+		},
+		{
+			name:   "unknown inputs",
+			inputs: `{"price": 1, "tax": 2, "discount": 3}`,
+			problems: []InputProblem{
+				{Name: "discount", Message: "is not a variable of this calculation"},
+				{Name: "tax", Message: "is not a variable of this calculation"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var inputs map[string]json.RawMessage
+			if err := json.Unmarshal([]byte(test.inputs), &inputs); err != nil {
+				t.Fatal(err)
+			}
+
+			bindings, err := Bind(variables, inputs)
+
+			if test.problems != nil {
+				var inputErr *InputError
+				if !errors.As(err, &inputErr) || !reflect.DeepEqual(inputErr.Problems, test.problems) {
+					t.Fatalf("Bind(%s) = %v, want problems %+v", test.inputs, err, test.problems)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Bind(%s) = %v", test.inputs, err)
+			}
+			if len(bindings) != len(test.want) {
+				t.Fatalf("Bind(%s) = %v, want %v", test.inputs, bindings, test.want)
+			}
+			for name, want := range test.want {
+				if value, ok := bindings[name]; !ok || !value.Equal(decimal.RequireFromString(want)) {
+					t.Fatalf("Bind(%s) binds %s to %s, want %s", test.inputs, name, value, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewVariableRejectsInvalidDefinitions(t *testing.T) {
+	text := func(s string) *string { return &s }
+
+	tests := []struct {
+		name         string
+		variable     string
+		typ          string
+		defaultValue *string
+		min          *string
+		max          *string
+		message      string
+	}{
+		{"invalid name", "1qty", VariableNumber, nil, nil, nil, "name must start with a letter or underscore and contain only letters, digits and underscores"},
+		{"unknown type", "qty", "text", nil, nil, nil, `unknown type "text", expected one of number, integer, boolean`},
+		{"invalid bound", "qty", VariableNumber, nil, text("low"), nil, "min must be a decimal number"},
+		{"inverted bounds", "qty", VariableNumber, nil, text("2"), text("1"), "min must not be greater than max"},
+		{"bounded boolean", "member", VariableBoolean, nil, text("0"), nil, "min and max are not allowed for boolean variables"},
+		{"default out of range", "qty", VariableInteger, text("0"), text("1"), nil, "defaultValue must be at least 1"},
+		{"fractional integer default", "qty", VariableInteger, text("1.5"), nil, nil, "defaultValue must be an integer"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewVariable(test.variable, test.typ, test.defaultValue, test.min, test.max)
+			if err == nil || err.Error() != test.message {
+				t.Fatalf("NewVariable() = %v, want %q", err, test.message)
+			}
+		})
+	}
+}
@@ -1,19 +1,27 @@
 package handlers
 
 import (
+	"backend/config"
+	"backend/metrics"
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
-	"os"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
 
-type AIHandler struct{}
+type AIHandler struct {
+	config config.AI
+	client *http.Client
+}
 
-func NewAIHandler() *AIHandler {
-	return &AIHandler{}
+func NewAIHandler(config config.AI, timeout time.Duration) *AIHandler {
+	return &AIHandler{config: config, client: &http.Client{Timeout: timeout}}
 }
 
 func (h *AIHandler) Routes() chi.Router {
@@ -23,8 +31,8 @@ func (h *AIHandler) Routes() chi.Router {
 }
 
 type AIRequest struct {
-	Prompt string `json:"prompt"`
-	Model  string `json:"model"`
+	Prompt string `json:"prompt" validate:"required,max=32000"`
+	Model  string `json:"model" validate:"max=255"`
 }
 
 type AIResponse struct {
@@ -51,19 +59,18 @@ type OpenRouterResponse struct {
 
 func (h *AIHandler) HandlePrompt(w http.ResponseWriter, r *http.Request) {
 	var req AIRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !decodeInput(w, r, &req) {
 		return
 	}
 
-	apiKey := os.Getenv("OPENROUTER_API_KEY")
-	if apiKey == "" {
-		http.Error(w, "API key not configured", http.StatusInternalServerError)
+	if !h.config.Configured() {
+		RecordError(r.Context(), errors.New("ai provider: OPENROUTER_API_KEY is not set"))
+		writeError(w, r, http.StatusInternalServerError, CodeInternal, "API key not configured", nil)
 		return
 	}
 
 	if req.Model == "" {
-		req.Model = "meta-llama/llama-3-8b-instruct:free"
+		req.Model = h.config.Model
 	}
 
 	openRouterReq := OpenRouterRequest{
@@ -78,48 +85,65 @@ func (h *AIHandler) HandlePrompt(w http.ResponseWriter, r *http.Request) {
 
 	jsonData, err := json.Marshal(openRouterReq)
 	if err != nil {
-		http.Error(w, "Failed to create request: "+err.Error(), http.StatusInternalServerError)
+		writeInternalError(w, r, err)
 		return
 	}
 
-	request, err := http.NewRequest("POST", "https://openrouter.ai/api/v1/chat/completions", bytes.NewBuffer(jsonData))
+	request, err := http.NewRequestWithContext(r.Context(), "POST", strings.TrimSuffix(h.config.BaseURL, "/")+"/chat/completions", bytes.NewBuffer(jsonData))
 	if err != nil {
-		http.Error(w, "Failed to create request: "+err.Error(), http.StatusInternalServerError)
+		writeInternalError(w, r, err)
 		return
 	}
 
 	request.Header.Set("Content-Type", "application/json")
-	request.Header.Set("Authorization", "Bearer "+apiKey)
-	request.Header.Set("HTTP-Referer", os.Getenv("APP_URL"))
-	request.Header.Set("X-Title", os.Getenv("APP_NAME"))
+	request.Header.Set("Authorization", "Bearer "+h.config.APIKey)
+	request.Header.Set("HTTP-Referer", h.config.AppURL)
+	request.Header.Set("X-Title", h.config.AppName)
 
-	client := &http.Client{}
-	resp, err := client.Do(request)
+	start := time.Now()
+	resp, err := h.client.Do(request)
 	if err != nil {
-		http.Error(w, "Failed to send request: "+err.Error(), http.StatusInternalServerError)
+		metrics.AIRequestDuration.Since(start)
+		metrics.AIErrors.Inc("transport")
+		RecordError(r.Context(), fmt.Errorf("ai provider: %w", err))
+		writeError(w, r, http.StatusInternalServerError, CodeUpstream, "Failed to send request: "+err.Error(), nil)
 		return
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
+	metrics.AIRequestDuration.Since(start)
 	if err != nil {
-		http.Error(w, "Failed to read response: "+err.Error(), http.StatusInternalServerError)
+		metrics.AIErrors.Inc("read")
+		RecordError(r.Context(), fmt.Errorf("ai provider: read response: %w", err))
+		writeError(w, r, http.StatusInternalServerError, CodeUpstream, "Failed to read response: "+err.Error(), nil)
 		return
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		http.Error(w, "OpenRouter API error: "+string(body), resp.StatusCode)
+		metrics.AIErrors.Inc("status")
+		RecordError(r.Context(), fmt.Errorf("ai provider: status %d: %s", resp.StatusCode, body))
+		// Pass the upstream error body through as details when it is JSON
+		var details any = string(body)
+		if json.Valid(body) {
+			details = json.RawMessage(body)
+		}
+		writeError(w, r, resp.StatusCode, CodeUpstream, "OpenRouter API error", details)
 		return
 	}
 
 	var openRouterResp OpenRouterResponse
 	if err := json.Unmarshal(body, &openRouterResp); err != nil {
-		http.Error(w, "Failed to parse response: "+err.Error(), http.StatusInternalServerError)
+		metrics.AIErrors.Inc("decode")
+		RecordError(r.Context(), fmt.Errorf("ai provider: parse response: %w", err))
+		writeError(w, r, http.StatusInternalServerError, CodeUpstream, "Failed to parse response: "+err.Error(), nil)
 		return
 	}
 
 	if len(openRouterResp.Choices) == 0 {
-		http.Error(w, "No response from OpenRouter", http.StatusInternalServerError)
+		metrics.AIErrors.Inc("empty")
+		RecordError(r.Context(), errors.New("ai provider: no choices in response"))
+		writeError(w, r, http.StatusInternalServerError, CodeUpstream, "No response from OpenRouter", nil)
 		return
 	}
 
@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"backend/prisma/db"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// Audited resources
+const (
+	ResourceCalculation = "calculation"
+	ResourceFormular    = "formular"
+	ResourceNode        = "node"
+	ResourceWorkspace   = "workspace"
+)
+
+// Audited actions
+const (
+	ActionCreate           = "create"
+	ActionUpdate           = "update"
+	ActionDelete           = "delete"
+	ActionRestore          = "restore"
+	ActionAddNode          = "add_node"
+	ActionRemoveNode       = "remove_node"
+	ActionReorderNodes     = "reorder_nodes"
+	ActionCompile          = "compile"
+	ActionRollback         = "rollback"
+	ActionAddFormular      = "add_formular"
+	ActionRemoveFormular   = "remove_formular"
+	ActionReorderFormulars = "reorder_formulars"
+	ActionUpgradeFormular  = "upgrade_formular"
+	ActionAddVariable      = "add_variable"
+	ActionRemoveVariable   = "remove_variable"
+	ActionGrant            = "grant"
+	ActionRevoke           = "revoke"
+	ActionAddMember        = "add_member"
+	ActionRemoveMember     = "remove_member"
+)
+
+// AuditEvent is a recorded mutating API call
+type AuditEvent struct {
+	ID         string          `json:"id" example:"123e4567-e89b-12d3-a456-426614174000"`                          // The ID of the event
+	Actor      string          `json:"actor" example:"anonymous"`                                                  // Who made the call
+	RequestID  string          `json:"requestId" example:"host/abc-000001"`                                        // The ID of the request, for correlating with server logs
+	Action     string          `json:"action" example:"update"`                                                    // What the call did
+	Resource   string          `json:"resource" example:"calculation" enums:"calculation,formular,node,workspace"` // The kind of the changed resource
+	ResourceID string          `json:"resourceId" example:"123e4567-e89b-12d3-a456-426614174001"`                  // The ID of the changed resource
+	Before     json.RawMessage `json:"before" swaggertype:"object"`                                                // The state before the call, null when the resource was created
+	After      json.RawMessage `json:"after" swaggertype:"object"`                                                 // The state after the call, null when the resource was deleted
+	CreatedAt  time.Time       `json:"createdAt" example:"2024-01-01T00:00:00Z"`                                   // When the call was made
+}
+
+// auditLink is a member of a formular's or calculation's sequence as recorded in the audit log
+type auditLink struct {
+	ID                string  `json:"id"`
+	NodeID            string  `json:"nodeId,omitempty"`
+	FormularID        string  `json:"formularId,omitempty"`
+	FormularVersionID *string `json:"formularVersionId,omitempty"`
+}
+
+// formularLinks records the node sequence of a formular, in the given order
+func formularLinks(formularNodes []db.FormularNodeModel) []auditLink {
+	links := make([]auditLink, 0, len(formularNodes))
+	for _, formularNode := range formularNodes {
+		links = append(links, auditLink{ID: formularNode.ID, NodeID: formularNode.NodeID})
+	}
+	return links
+}
+
+// calculationLinks records the formular sequence of a calculation, in the given order
+func calculationLinks(calculationFormulars []db.CalculationFormularModel) []auditLink {
+	links := make([]auditLink, 0, len(calculationFormulars))
+	for _, calculationFormular := range calculationFormulars {
+		links = append(links, auditLink{
+			ID:                calculationFormular.ID,
+			FormularID:        calculationFormular.FormularID,
+			FormularVersionID: calculationFormular.InnerCalculationFormular.FormularVersionID,
+		})
+	}
+	return links
+}
+
+// actor returns the ID of the principal that made a request, or "anonymous" for a request that
+// was not authenticated
+func actor(r *http.Request) string {
+	if principal := principalID(r); principal != "" {
+		return principal
+	}
+	return "anonymous"
+}
+
+// audit returns the transaction that records a mutation of a resource by the request's actor in
+// the request's workspace, to be executed together with the mutation itself. before and after are
+// stored as JSON and may be nil when the resource did not exist before or after the call.
+func audit(client *db.PrismaClient, r *http.Request, action, resource, resourceID string, before, after any) (db.PrismaTransaction, error) {
+	return auditEvent(client, workspaceID(r), actor(r), chimiddleware.GetReqID(r.Context()), action, resource, resourceID, before, after)
+}
+
+// auditEvent returns the transaction that records a mutation made by actor in a workspace, outside
+// of a request when requestID is empty. It fails when before or after cannot be marshaled.
+func auditEvent(client *db.PrismaClient, workspaceID, actor, requestID, action, resource, resourceID string, before, after any) (db.PrismaTransaction, error) {
+	beforeJSON, err := auditJSON(before)
+	if err != nil {
+		return nil, fmt.Errorf("audit %s %s: before: %w", resource, resourceID, err)
+	}
+	afterJSON, err := auditJSON(after)
+	if err != nil {
+		return nil, fmt.Errorf("audit %s %s: after: %w", resource, resourceID, err)
+	}
+
+	return client.AuditEvent.CreateOne(
+		db.AuditEvent.Actor.Set(actor),
+		db.AuditEvent.RequestID.Set(requestID),
+		db.AuditEvent.Action.Set(action),
+		db.AuditEvent.Resource.Set(resource),
+		db.AuditEvent.ResourceID.Set(resourceID),
+		db.AuditEvent.WorkspaceID.Set(workspaceID),
+		db.AuditEvent.Before.SetIfPresent(beforeJSON),
+		db.AuditEvent.After.SetIfPresent(afterJSON),
+	).Tx(), nil
+}
+
+// auditJSON marshals a resource state, returning nil for a nil state
+func auditJSON(state any) (*string, error) {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+	if string(data) == "null" {
+		return nil, nil
+	}
+	encoded := string(data)
+	return &encoded, nil
+}
+
+// AuditHandler handles HTTP requests for the audit log
+type AuditHandler struct {
+	db *db.PrismaClient
+}
+
+// NewAuditHandler creates a new audit handler
+func NewAuditHandler(db *db.PrismaClient) *AuditHandler {
+	return &AuditHandler{db: db}
+}
+
+// Routes returns the router for audit endpoints
+func (h *AuditHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", h.List)
+
+	return r
+}
+
+// List godoc
+// @Summary List audit events
+// @Description Get a page of the audit events of the request's workspace, newest first, limited to the workspace itself and the calculations, formulars and nodes the caller has a role on, optionally filtered by actor, request, action, resource and time. Pass nextCursor as cursor to fetch the following page.
+// @Tags audit
+// @Accept json
+// @Produce json
+// @Param limit query int false "Maximum number of items to return" minimum(1) maximum(200) default(50)
+// @Param cursor query string false "Cursor returned as nextCursor by the previous page"
+// @Param actor query string false "Only return events of this actor"
+// @Param requestId query string false "Only return events of this request"
+// @Param action query string false "Only return events with this action"
+// @Param resource query string false "Only return events for this kind of resource" Enums(calculation, formular, node, workspace)
+// @Param resourceId query string false "Only return events for the resource with this ID"
+// @Param createdAfter query string false "Only return events recorded at or after this RFC 3339 timestamp"
+// @Param createdBefore query string false "Only return events recorded before this RFC 3339 timestamp"
+// @Success 200 {object} Page[AuditEvent]
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 422 {object} APIError{details=ValidationError} "Invalid query parameters"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /audit [get]
+func (h *AuditHandler) List(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+
+	limit, cursor, problems := parsePage(r)
+	createdAfter, afterProblems := queryTime(r, "createdAfter")
+	createdBefore, beforeProblems := queryTime(r, "createdBefore")
+	if problems = append(append(problems, afterProblems...), beforeProblems...); len(problems) > 0 {
+		writeValidationError(w, r, problems)
+		return
+	}
+
+	optional := func(name string) *string {
+		if value := values.Get(name); value != "" {
+			return &value
+		}
+		return nil
+	}
+
+	// Events carry the state of their resource, so only list those of resources the caller has a
+	// role on, and those of the workspace, which every member may see. Permanently deleted resources
+	// have no roles left, so their events are only kept for operators reading the database.
+	filter := filterQuery{table: "AuditEvent"}
+	filter.where(`("resource" = ? OR "resourceId" IN (SELECT "resourceId" FROM "Permission" WHERE "principal" = ? AND "resource" = "AuditEvent"."resource"))`, ResourceWorkspace, principalID(r))
+	filter.where(`"workspaceId" = ?`, workspaceID(r))
+	for _, field := range []string{"actor", "requestId", "action", "resource", "resourceId"} {
+		whereIfPresent(&filter, `"`+field+`" = ?`, optional(field))
+	}
+	whereIfPresent(&filter, `"createdAt" >= ?`, createdAfter)
+	whereIfPresent(&filter, `"createdAt" < ?`, createdBefore)
+
+	// Fetch one extra event to know whether there is a next page
+	ids, err := filter.ids(r.Context(), h.db, "createdAt", true, cursor, limit+1)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	events, err := findInOrder(ids, func(ids []string) ([]db.AuditEventModel, error) {
+		return h.db.AuditEvent.FindMany(db.AuditEvent.ID.In(ids)).Exec(r.Context())
+	}, func(event db.AuditEventModel) string {
+		return event.ID
+	})
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	total, err := filter.count(r.Context(), h.db)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	items := make([]AuditEvent, 0, len(events))
+	for _, event := range events {
+		items = append(items, newAuditEvent(event))
+	}
+
+	json.NewEncoder(w).Encode(newPage(items, total, limit, func(event AuditEvent) string {
+		return event.ID
+	}))
+}
+
+// newAuditEvent converts a stored event, whose states are JSON strings, into its response
+func newAuditEvent(event db.AuditEventModel) AuditEvent {
+	response := AuditEvent{
+		ID:         event.ID,
+		Actor:      event.Actor,
+		RequestID:  event.RequestID,
+		Action:     event.Action,
+		Resource:   event.Resource,
+		ResourceID: event.ResourceID,
+		Before:     json.RawMessage("null"),
+		After:      json.RawMessage("null"),
+		CreatedAt:  event.CreatedAt,
+	}
+	if before, ok := event.Before(); ok {
+		response.Before = json.RawMessage(before)
+	}
+	if after, ok := event.After(); ok {
+		response.After = json.RawMessage(after)
+	}
+	return response
+}
@@ -2,9 +2,15 @@
 package handlers
 
 import (
+	"backend/engine"
 	"backend/prisma/db"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -28,6 +34,7 @@ func (h *CalculationHandler) Routes() chi.Router {
 	r.Get("/{id}", h.Get)
 	r.Put("/{id}", h.Update)
 	r.Delete("/{id}", h.Delete)
+	r.Post("/{id}/restore", h.Restore)
 
 	// Formular relationship endpoints
 	r.Post("/{id}/formulars", h.AddFormular)
@@ -35,54 +42,161 @@ func (h *CalculationHandler) Routes() chi.Router {
 	r.Get("/{id}/formulars", h.ListFormulars)
 	r.Put("/{id}/formulars/reorder", h.ReorderFormulars)
 
+	// Link endpoints address a single occurrence of a formular in the sequence
+	r.Delete("/{id}/links/{linkId}", h.RemoveLink)
+	r.Put("/{id}/links/reorder", h.ReorderLinks)
+	r.Post("/{id}/links/{linkId}/upgrade", h.UpgradeLink)
+	r.Get("/{id}/integrity", h.Integrity)
+
+	// Variable endpoints
+	r.Get("/{id}/variables", h.ListVariables)
+	r.Post("/{id}/variables", h.AddVariable)
+	r.Delete("/{id}/variables/{variableId}", h.RemoveVariable)
+
+	// Evaluation endpoints
+	r.Post("/{id}/evaluate", h.Evaluate)
+	r.Post("/{id}/run", h.Run)
+
+	// Sharing endpoints
+	r.Get("/{id}/permissions", h.ListPermissions)
+	r.Put("/{id}/permissions/{principal}", h.GrantPermission)
+	r.Delete("/{id}/permissions/{principal}", h.RevokePermission)
+
 	return r
 }
 
 // List godoc
 // @Summary List calculations
-// @Description Get all calculations
+// @Description Get a page of calculations, optionally filtered by name and timestamps. Pass nextCursor as cursor to fetch the following page.
 // @Tags calculations
 // @Accept json
 // @Produce json
-// @Success 200 {array} db.CalculationModel
+// @Param limit query int false "Maximum number of items to return" minimum(1) maximum(200) default(50)
+// @Param cursor query string false "Cursor returned as nextCursor by the previous page"
+// @Param name query string false "Only return calculations whose name contains this text"
+// @Param createdAfter query string false "Only return calculations created at or after this RFC 3339 timestamp"
+// @Param createdBefore query string false "Only return calculations created before this RFC 3339 timestamp"
+// @Param updatedAfter query string false "Only return calculations updated at or after this RFC 3339 timestamp"
+// @Param updatedBefore query string false "Only return calculations updated before this RFC 3339 timestamp"
+// @Param sort query string false "Sort field, prefixed with - for descending order" Enums(name, -name, createdAt, -createdAt, updatedAt, -updatedAt) default(createdAt)
+// @Param includeDeleted query bool false "Include soft-deleted calculations" default(false)
+// @Success 200 {object} Page[db.CalculationModel]
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 422 {object} APIError{details=ValidationError} "Invalid query parameters"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /calculations [get]
 func (h *CalculationHandler) List(w http.ResponseWriter, r *http.Request) {
-	calculations, err := h.db.Calculation.FindMany().Exec(r.Context())
+	query, problems := parseListQuery(r)
+	if len(problems) > 0 {
+		writeValidationError(w, r, problems)
+		return
+	}
+
+	// Only list the calculations the caller has a role on
+	ids, total, err := listPage(r, h.db, "Calculation", ResourceCalculation, query)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	id := func(m db.CalculationModel) string {
+		return m.ID
+	}
+	calculations, err := findInOrder(ids, func(ids []string) ([]db.CalculationModel, error) {
+		return h.db.Calculation.FindMany(db.Calculation.ID.In(ids)).Exec(r.Context())
+	}, id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeInternalError(w, r, err)
 		return
 	}
 
-	json.NewEncoder(w).Encode(calculations)
+	json.NewEncoder(w).Encode(newPage(calculations, total, query.limit, id))
 }
 
 // Get godoc
 // @Summary Get a calculation
-// @Description Get calculation by ID
+// @Description Get calculation by ID, optionally with its related records. Expanded sequences are returned in sequence order.
 // @Tags calculations
 // @Accept json
 // @Produce json
 // @Param id path string true "Calculation ID"
+// @Param includeDeleted query bool false "Return the calculation even if it is soft-deleted" default(false)
+// @Param expand query string false "Comma separated relations to include, any prefix of formulars.formular.nodes.node or variables"
 // @Success 200 {object} db.CalculationModel
-// @Failure 404 {string} string "Calculation not found"
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 404 {object} APIError "Calculation not found"
+// @Failure 422 {object} APIError{details=ValidationError} "Invalid query parameters"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /calculations/{id} [get]
 func (h *CalculationHandler) Get(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
+
+	expand, problems := parseExpand(r, "formulars.formular.nodes.node", "variables")
+	includeDeleted, flagProblems := queryFlag(r, "includeDeleted")
+	if problems = append(problems, flagProblems...); len(problems) > 0 {
+		writeValidationError(w, r, problems)
+		return
+	}
+
+	if !authorize(w, r, h.db, ResourceCalculation, id, RoleViewer) {
+		return
+	}
+
+	var with []db.CalculationRelationWith
+	if depth := expand["formulars.formular.nodes.node"]; depth > 0 {
+		formulars := db.Calculation.Formulars.Fetch()
+		if depth > 1 {
+			formular := db.CalculationFormular.Formular.Fetch()
+			if depth > 2 {
+				formular = formular.With(formularNodesWith(depth - 2))
+			}
+			formulars = formulars.With(formular)
+		}
+		with = append(with, formulars)
+	}
+	if expand["variables"] > 0 {
+		with = append(with, db.Calculation.Variables.Fetch().OrderBy(
+			db.Variable.Name.Order(db.SortOrderAsc),
+		))
+	}
+
 	calculation, err := h.db.Calculation.FindUnique(
 		db.Calculation.ID.Equals(id),
+	).With(
+		with...,
 	).Exec(r.Context())
 
 	if err != nil {
-		http.Error(w, "Calculation not found", http.StatusNotFound)
+		writeDBError(w, r, err, "Calculation")
 		return
 	}
 
+	if _, deleted := calculation.DeletedAt(); deleted && !includeDeleted {
+		writeError(w, r, http.StatusNotFound, CodeNotFound, "Calculation not found", nil)
+		return
+	}
+
+	// Relations are fetched in storage order, so walk the sequences to return them in order
+	if calculationFormulars := calculation.RelationsCalculation.Formulars; calculationFormulars != nil {
+		calculationFormulars = currentOrder(calculationFormulars, func(cf db.CalculationFormularModel) (string, *string) {
+			return cf.ID, cf.InnerCalculationFormular.NextID
+		})
+		for _, calculationFormular := range calculationFormulars {
+			if formular := calculationFormular.RelationsCalculationFormular.Formular; formular != nil {
+				orderFormularNodes(formular)
+			}
+		}
+		calculation.RelationsCalculation.Formulars = calculationFormulars
+	}
+
 	json.NewEncoder(w).Encode(calculation)
 }
 
 // CreateCalculationInput represents the input for creating a calculation
 type CreateCalculationInput struct {
-	Name string `json:"name" example:"My Calculation"` // The name of the calculation
+	Name string `json:"name" validate:"required,max=255" example:"My Calculation"` // The name of the calculation
 }
 
 // Create godoc
@@ -93,30 +207,56 @@ type CreateCalculationInput struct {
 // @Produce json
 // @Param calculation body CreateCalculationInput true "Calculation to create"
 // @Success 201 {object} db.CalculationModel
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 422 {object} APIError{details=ValidationError} "Invalid input"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /calculations [post]
 func (h *CalculationHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var input CreateCalculationInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeInput(w, r, &input) {
 		return
 	}
 
-	calculation, err := h.db.Calculation.CreateOne(
-		db.Calculation.Name.Set(input.Name),
-	).Exec(r.Context())
+	now := time.Now()
+	calculation := db.CalculationModel{InnerCalculation: db.InnerCalculation{
+		ID:          newID(),
+		Name:        input.Name,
+		WorkspaceID: workspaceID(r),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}}
+
+	create := h.db.Calculation.CreateOne(
+		db.Calculation.Name.Set(calculation.Name),
+		db.Calculation.Workspace.Link(db.Workspace.ID.Equals(calculation.WorkspaceID)),
+		db.Calculation.ID.Set(calculation.ID),
+		db.Calculation.CreatedAt.Set(now),
+		db.Calculation.UpdatedAt.Set(now),
+	).Tx()
 
+	auditTx, err := audit(h.db, r, ActionCreate, ResourceCalculation, calculation.ID, nil, calculation)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeInternalError(w, r, err)
+		return
+	}
+
+	if err := h.db.Prisma.Transaction(
+		create,
+		grantOwner(h.db, r, ResourceCalculation, calculation.ID),
+		auditTx,
+	).Exec(r.Context()); err != nil {
+		writeInternalError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(calculation)
+	json.NewEncoder(w).Encode(create.Result())
 }
 
 // UpdateCalculationInput represents the input for updating a calculation
 type UpdateCalculationInput struct {
-	Name *string `json:"name,omitempty" example:"Updated Calculation"` // The new name of the calculation
+	Name *string `json:"name,omitempty" validate:"notblank,min=1,max=255" example:"Updated Calculation"` // The new name of the calculation
 }
 
 // Update godoc
@@ -128,108 +268,396 @@ type UpdateCalculationInput struct {
 // @Param id path string true "Calculation ID"
 // @Param calculation body UpdateCalculationInput true "Calculation updates"
 // @Success 200 {object} db.CalculationModel
-// @Failure 404 {string} string "Calculation not found"
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 403 {object} APIError "Insufficient role"
+// @Failure 404 {object} APIError "Calculation not found"
+// @Failure 422 {object} APIError{details=ValidationError} "Invalid input"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /calculations/{id} [put]
 func (h *CalculationHandler) Update(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
 	var input UpdateCalculationInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeInput(w, r, &input) {
+		return
+	}
+
+	if !authorize(w, r, h.db, ResourceCalculation, id, RoleEditor) {
+		return
+	}
+
+	// Soft-deleted records must be restored before they can be changed
+	before, err := h.db.Calculation.FindFirst(
+		db.Calculation.ID.Equals(id),
+		db.Calculation.DeletedAt.IsNull(),
+	).Exec(r.Context())
+
+	if err != nil {
+		writeDBError(w, r, err, "Calculation")
 		return
 	}
 
-	params := []db.CalculationSetParam{}
+	after := *before
+	after.UpdatedAt = time.Now()
+	params := []db.CalculationSetParam{db.Calculation.UpdatedAt.Set(after.UpdatedAt)}
 
 	if input.Name != nil {
+		after.Name = *input.Name
 		params = append(params, db.Calculation.Name.Set(*input.Name))
 	}
 
-	calculation, err := h.db.Calculation.FindUnique(
+	update := h.db.Calculation.FindUnique(
 		db.Calculation.ID.Equals(id),
 	).Update(
 		params...,
-	).Exec(r.Context())
+	).Tx()
 
+	auditTx, err := audit(h.db, r, ActionUpdate, ResourceCalculation, id, before, after)
 	if err != nil {
-		http.Error(w, "Calculation not found", http.StatusNotFound)
+		writeInternalError(w, r, err)
 		return
 	}
 
-	json.NewEncoder(w).Encode(calculation)
+	if err := h.db.Prisma.Transaction(
+		update,
+		auditTx,
+	).Exec(r.Context()); err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(update.Result())
 }
 
 // Delete godoc
 // @Summary Delete a calculation
-// @Description Delete a calculation by ID
+// @Description Soft delete a calculation by ID so it can be restored. With permanent=true the calculation is deleted immediately: in restrict mode a calculation that has formulars or variables is not deleted. Detach deletes its formular sequence and variables; cascade also deletes the formulars no other calculation uses and their unused nodes, as far as the caller owns them.
 // @Tags calculations
 // @Accept json
 // @Produce json
 // @Param id path string true "Calculation ID"
+// @Param permanent query bool false "Delete the calculation immediately instead of soft deleting it" default(false)
+// @Param mode query string false "How a permanent delete handles related records, only with permanent=true" Enums(restrict, detach, cascade) default(restrict)
 // @Success 204 "No Content"
-// @Failure 404 {string} string "Calculation not found"
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 403 {object} APIError "Insufficient role"
+// @Failure 404 {object} APIError "Calculation not found"
+// @Failure 409 {object} APIError{details=DependentsConflict} "Calculation has formulars or variables"
+// @Failure 422 {object} APIError{details=ValidationError} "Invalid query parameters"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /calculations/{id} [delete]
 func (h *CalculationHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
-	_, err := h.db.Calculation.FindUnique(
+	permanent, mode, problems := parseDeleteQuery(r)
+	if len(problems) > 0 {
+		writeValidationError(w, r, problems)
+		return
+	}
+
+	if !authorize(w, r, h.db, ResourceCalculation, id, RoleOwner) {
+		return
+	}
+
+	calculation, err := h.db.Calculation.FindUnique(
 		db.Calculation.ID.Equals(id),
-	).Delete().Exec(r.Context())
+	).Exec(r.Context())
+
+	if err != nil {
+		writeDBError(w, r, err, "Calculation")
+		return
+	}
+
+	if !permanent {
+		if _, deleted := calculation.DeletedAt(); !deleted {
+			now := time.Now()
+			after := *calculation
+			after.UpdatedAt = now
+			after.InnerCalculation.DeletedAt = &now
+
+			auditTx, err := audit(h.db, r, ActionDelete, ResourceCalculation, id, calculation, after)
+			if err != nil {
+				writeInternalError(w, r, err)
+				return
+			}
+
+			err = h.db.Prisma.Transaction(
+				h.db.Calculation.FindUnique(
+					db.Calculation.ID.Equals(id),
+				).Update(
+					db.Calculation.DeletedAt.Set(now),
+					db.Calculation.UpdatedAt.Set(now),
+				).Tx(),
+				auditTx,
+			).Exec(r.Context())
+
+			if err != nil {
+				writeInternalError(w, r, err)
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	txs, dependents, err := calculationDeletion(r.Context(), h.db, id, mode)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	if !permitDeletion(w, r, h.db, ResourceCalculation, id, mode, dependents) {
+		return
+	}
 
+	auditTx, err := audit(h.db, r, ActionDelete, ResourceCalculation, id, calculation, nil)
 	if err != nil {
-		http.Error(w, "Calculation not found", http.StatusNotFound)
+		writeInternalError(w, r, err)
+		return
+	}
+	txs = append(txs, auditTx)
+	if err := h.db.Prisma.Transaction(txs...).Exec(r.Context()); err != nil {
+		writeInternalError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// Restore godoc
+// @Summary Restore a calculation
+// @Description Restore a soft-deleted calculation by ID. Restoring a calculation that is not deleted has no effect.
+// @Tags calculations
+// @Accept json
+// @Produce json
+// @Param id path string true "Calculation ID"
+// @Success 200 {object} db.CalculationModel
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 403 {object} APIError "Insufficient role"
+// @Failure 404 {object} APIError "Calculation not found"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /calculations/{id}/restore [post]
+func (h *CalculationHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if !authorize(w, r, h.db, ResourceCalculation, id, RoleOwner) {
+		return
+	}
+
+	calculation, err := h.db.Calculation.FindUnique(
+		db.Calculation.ID.Equals(id),
+	).Exec(r.Context())
+
+	if err != nil {
+		writeDBError(w, r, err, "Calculation")
+		return
+	}
+
+	if _, deleted := calculation.DeletedAt(); !deleted {
+		json.NewEncoder(w).Encode(calculation)
+		return
+	}
+
+	after := *calculation
+	after.UpdatedAt = time.Now()
+	after.InnerCalculation.DeletedAt = nil
+
+	restore := h.db.Calculation.FindUnique(
+		db.Calculation.ID.Equals(id),
+	).Update(
+		db.Calculation.DeletedAt.SetOptional(nil),
+		db.Calculation.UpdatedAt.Set(after.UpdatedAt),
+	).Tx()
+
+	auditTx, err := audit(h.db, r, ActionRestore, ResourceCalculation, id, calculation, after)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	if err := h.db.Prisma.Transaction(
+		restore,
+		auditTx,
+	).Exec(r.Context()); err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(restore.Result())
+}
+
 // AddFormularInput represents the input for adding a formular to a calculation
 type AddFormularInput struct {
-	FormularID string  `json:"formularId" example:"123e4567-e89b-12d3-a456-426614174000"`       // The ID of the formular to add
-	NextID     *string `json:"nextId,omitempty" example:"123e4567-e89b-12d3-a456-426614174001"` // Optional ID of the next formular in sequence
+	FormularID string  `json:"formularId" validate:"required,uuid" format:"uuid" example:"123e4567-e89b-12d3-a456-426614174000"` // The ID of the formular to add
+	NextID     *string `json:"nextId,omitempty" validate:"uuid" format:"uuid" example:"123e4567-e89b-12d3-a456-426614174001"`    // Optional ID of the calculation formular to insert before
+	AfterID    *string `json:"afterId,omitempty" validate:"uuid" format:"uuid" example:"123e4567-e89b-12d3-a456-426614174002"`   // Optional ID of the calculation formular to insert after
+	Pin        bool    `json:"pin,omitempty" example:"false"`                                                                    // Whether to pin the latest version of the formular instead of following its changes
 }
 
 // AddFormular godoc
 // @Summary Add a formular to a calculation
-// @Description Add a formular to a calculation's sequence
+// @Description Insert a formular into a calculation's sequence before nextId, after afterId, or at the end when neither is given. A pinned formular is evaluated as the version that was current when it was added; a floating formular follows every change.
 // @Tags calculations
 // @Accept json
 // @Produce json
 // @Param id path string true "Calculation ID"
 // @Param formular body AddFormularInput true "Formular to add"
 // @Success 201 {object} db.CalculationFormularModel
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 403 {object} APIError "Insufficient role"
+// @Failure 404 {object} APIError "Calculation or formular not found"
+// @Failure 409 {object} APIError "Broken formular sequence, or the sequence changed while the formular was added"
+// @Failure 422 {object} APIError{details=ValidationError} "Invalid input or insert position"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /calculations/{id}/formulars [post]
 func (h *CalculationHandler) AddFormular(w http.ResponseWriter, r *http.Request) {
 	calculationID := chi.URLParam(r, "id")
 
 	var input AddFormularInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeInput(w, r, &input) {
 		return
 	}
 
-	calculationFormular, err := h.db.CalculationFormular.CreateOne(
-		db.CalculationFormular.Calculation.Link(db.Calculation.ID.Equals(calculationID)),
-		db.CalculationFormular.Formular.Link(db.Formular.ID.Equals(input.FormularID)),
+	// The formular is only referenced, so seeing it is enough
+	if !authorize(w, r, h.db, ResourceCalculation, calculationID, RoleEditor) ||
+		!authorize(w, r, h.db, ResourceFormular, input.FormularID, RoleViewer) {
+		return
+	}
+
+	_, err := h.db.Formular.FindFirst(
+		db.Formular.ID.Equals(input.FormularID),
+		db.Formular.DeletedAt.IsNull(),
 	).Exec(r.Context())
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeDBError(w, r, err, "Formular")
 		return
 	}
 
-	if input.NextID != nil {
-		_, err = h.db.CalculationFormular.FindUnique(
-			db.CalculationFormular.ID.Equals(calculationFormular.ID),
-		).Update(
-			db.CalculationFormular.NextID.Set(*input.NextID),
-		).Exec(r.Context())
+	calculationFormulars, err := h.db.CalculationFormular.FindMany(
+		db.CalculationFormular.CalculationID.Equals(calculationID),
+	).Exec(r.Context())
+
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	calculationFormulars, err = orderChain(calculationFormulars, func(cf db.CalculationFormularModel) (string, *string) {
+		return cf.ID, cf.InnerCalculationFormular.NextID
+	})
+	if err != nil {
+		writeError(w, r, http.StatusConflict, CodeBrokenSequence, err.Error(), nil)
+		return
+	}
+
+	prev, next, err := insertPosition(calculationFormulars, func(cf db.CalculationFormularModel) string {
+		return cf.ID
+	}, input.NextID, input.AfterID)
+	if err != nil {
+		writeError(w, r, http.StatusUnprocessableEntity, CodeInvalid, err.Error(), nil)
+		return
+	}
 
+	calculationFormularID := newID()
+	params := []db.CalculationFormularSetParam{db.CalculationFormular.ID.Set(calculationFormularID)}
+	if next != nil {
+		params = append(params, db.CalculationFormular.Next.Link(db.CalculationFormular.ID.Equals(next.ID)))
+	}
+	var formularVersionID *string
+	if input.Pin {
+		version, err := currentVersion(r.Context(), h.db, input.FormularID)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalError(w, r, err)
 			return
 		}
+		formularVersionID = &version.ID
+		params = append(params, db.CalculationFormular.FormularVersion.Link(db.FormularVersion.ID.Equals(version.ID)))
+	}
+
+	// The sequence was read outside of the transaction, so every pointer change is conditional on
+	// the state that was read. Detach the predecessor first so the new row can take over its next
+	// pointer; the new row's unique next pointer fails when another row took the successor since.
+	var txs []db.PrismaTransaction
+	if prev != nil && next != nil {
+		txs = append(txs, h.db.CalculationFormular.FindMany(
+			db.CalculationFormular.ID.Equals(prev.ID),
+			db.CalculationFormular.NextID.Equals(next.ID),
+		).Update(
+			db.CalculationFormular.NextID.SetOptional(nil),
+		).Tx())
+	}
+
+	txs = append(txs, h.db.CalculationFormular.CreateOne(
+		db.CalculationFormular.Calculation.Link(db.Calculation.ID.Equals(calculationID)),
+		db.CalculationFormular.Formular.Link(db.Formular.ID.Equals(input.FormularID)),
+		params...,
+	).Tx())
+
+	if prev != nil {
+		// Link the predecessor only if nothing was inserted after it since, and fail the
+		// transaction when it was not linked
+		txs = append(txs, h.db.CalculationFormular.FindMany(
+			db.CalculationFormular.ID.Equals(prev.ID),
+			db.CalculationFormular.NextID.IsNull(),
+		).Update(
+			db.CalculationFormular.NextID.Set(calculationFormularID),
+		).Tx(), h.db.CalculationFormular.FindUnique(
+			db.CalculationFormular.NextID.Equals(calculationFormularID),
+		).Update(
+			db.CalculationFormular.UpdatedAt.Set(time.Now()),
+		).Tx())
+	} else if next == nil {
+		// A member another request added to the empty sequence since goes first, so the sequence
+		// stays a single chain
+		txs = append(txs, h.db.CalculationFormular.FindMany(
+			db.CalculationFormular.CalculationID.Equals(calculationID),
+			db.CalculationFormular.ID.Not(calculationFormularID),
+			db.CalculationFormular.NextID.IsNull(),
+		).Update(
+			db.CalculationFormular.NextID.Set(calculationFormularID),
+		).Tx())
+	}
+
+	position := len(calculationFormulars)
+	if next != nil {
+		position = slices.IndexFunc(calculationFormulars, func(link db.CalculationFormularModel) bool {
+			return link.ID == next.ID
+		})
+	}
+	before := calculationLinks(calculationFormulars)
+	after := slices.Insert(slices.Clone(before), position, auditLink{
+		ID:                calculationFormularID,
+		FormularID:        input.FormularID,
+		FormularVersionID: formularVersionID,
+	})
+	auditTx, err := audit(h.db, r, ActionAddFormular, ResourceCalculation, calculationID, before, after)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	txs = append(txs, auditTx)
+
+	if err := h.db.Prisma.Transaction(txs...).Exec(r.Context()); err != nil {
+		if isTxConflict(err) {
+			writeError(w, r, http.StatusConflict, CodeSequenceConflict, "The calculation changed while the formular was added, retry the request", nil)
+			return
+		}
+		writeInternalError(w, r, err)
+		return
+	}
+
+	calculationFormular, err := h.db.CalculationFormular.FindUnique(
+		db.CalculationFormular.ID.Equals(calculationFormularID),
+	).Exec(r.Context())
+
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
@@ -238,145 +666,896 @@ func (h *CalculationHandler) AddFormular(w http.ResponseWriter, r *http.Request)
 
 // RemoveFormular godoc
 // @Summary Remove a formular from a calculation
-// @Description Remove a formular from a calculation's sequence
+// @Description Remove the first occurrence of a formular from a calculation's sequence and link its predecessor to its successor. Use the link endpoint to remove a specific occurrence.
 // @Tags calculations
 // @Accept json
 // @Produce json
 // @Param id path string true "Calculation ID"
 // @Param formularId path string true "Formular ID"
 // @Success 204 "No Content"
-// @Failure 404 {string} string "CalculationFormular not found"
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 403 {object} APIError "Insufficient role"
+// @Failure 404 {object} APIError "Calculation or CalculationFormular not found"
+// @Deprecated
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /calculations/{id}/formulars/{formularId} [delete]
 func (h *CalculationHandler) RemoveFormular(w http.ResponseWriter, r *http.Request) {
 	calculationID := chi.URLParam(r, "id")
 	formularID := chi.URLParam(r, "formularId")
 
-	// Find the calculation formular first
+	if !authorize(w, r, h.db, ResourceCalculation, calculationID, RoleEditor) {
+		return
+	}
+
+	calculationFormulars, err := h.db.CalculationFormular.FindMany(
+		db.CalculationFormular.CalculationID.Equals(calculationID),
+	).Exec(r.Context())
+
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	calculationFormulars = currentOrder(calculationFormulars, func(cf db.CalculationFormularModel) (string, *string) {
+		return cf.ID, cf.InnerCalculationFormular.NextID
+	})
+
+	// Remove the occurrence closest to the head
+	for _, calculationFormular := range calculationFormulars {
+		if calculationFormular.FormularID == formularID {
+			h.unlinkCalculationFormular(w, r, &calculationFormular)
+			return
+		}
+	}
+
+	writeError(w, r, http.StatusNotFound, CodeNotFound, "CalculationFormular not found", nil)
+}
+
+// RemoveLink godoc
+// @Summary Remove a calculation formular from a calculation
+// @Description Remove a single occurrence of a formular from a calculation's sequence by its link ID and link its predecessor to its successor
+// @Tags calculations
+// @Accept json
+// @Produce json
+// @Param id path string true "Calculation ID"
+// @Param linkId path string true "CalculationFormular ID"
+// @Success 204 "No Content"
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 403 {object} APIError "Insufficient role"
+// @Failure 404 {object} APIError "Calculation or CalculationFormular not found"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /calculations/{id}/links/{linkId} [delete]
+func (h *CalculationHandler) RemoveLink(w http.ResponseWriter, r *http.Request) {
+	calculationID := chi.URLParam(r, "id")
+	linkID := chi.URLParam(r, "linkId")
+
+	if !authorize(w, r, h.db, ResourceCalculation, calculationID, RoleEditor) {
+		return
+	}
+
 	calculationFormular, err := h.db.CalculationFormular.FindFirst(
+		db.CalculationFormular.ID.Equals(linkID),
 		db.CalculationFormular.CalculationID.Equals(calculationID),
-		db.CalculationFormular.FormularID.Equals(formularID),
 	).Exec(r.Context())
 
 	if err != nil {
-		http.Error(w, "CalculationFormular not found", http.StatusNotFound)
+		writeDBError(w, r, err, "CalculationFormular")
 		return
 	}
 
-	// Then delete it
-	_, err = h.db.CalculationFormular.FindUnique(
+	h.unlinkCalculationFormular(w, r, calculationFormular)
+}
+
+// UpgradeLink godoc
+// @Summary Pin a calculation formular to the latest version
+// @Description Pin a single occurrence of a formular in a calculation to the formular's latest version, recording the current state of the formular as a new version if it changed. A floating formular becomes pinned.
+// @Tags calculations
+// @Accept json
+// @Produce json
+// @Param id path string true "Calculation ID"
+// @Param linkId path string true "CalculationFormular ID"
+// @Success 200 {object} db.CalculationFormularModel
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 403 {object} APIError "Insufficient role"
+// @Failure 404 {object} APIError "Calculation or CalculationFormular not found"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /calculations/{id}/links/{linkId}/upgrade [post]
+func (h *CalculationHandler) UpgradeLink(w http.ResponseWriter, r *http.Request) {
+	calculationID := chi.URLParam(r, "id")
+	linkID := chi.URLParam(r, "linkId")
+
+	if !authorize(w, r, h.db, ResourceCalculation, calculationID, RoleEditor) {
+		return
+	}
+
+	calculationFormular, err := h.db.CalculationFormular.FindFirst(
+		db.CalculationFormular.ID.Equals(linkID),
+		db.CalculationFormular.CalculationID.Equals(calculationID),
+	).Exec(r.Context())
+
+	if err != nil {
+		writeDBError(w, r, err, "CalculationFormular")
+		return
+	}
+
+	version, err := currentVersion(r.Context(), h.db, calculationFormular.FormularID)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	after := *calculationFormular
+	after.UpdatedAt = time.Now()
+	after.InnerCalculationFormular.FormularVersionID = &version.ID
+
+	upgrade := h.db.CalculationFormular.FindUnique(
+		db.CalculationFormular.ID.Equals(linkID),
+	).With(
+		db.CalculationFormular.FormularVersion.Fetch(),
+	).Update(
+		db.CalculationFormular.FormularVersion.Link(db.FormularVersion.ID.Equals(version.ID)),
+		db.CalculationFormular.UpdatedAt.Set(after.UpdatedAt),
+	).Tx()
+
+	auditTx, err := audit(h.db, r, ActionUpgradeFormular, ResourceCalculation, calculationID, calculationFormular, after)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	if err := h.db.Prisma.Transaction(
+		upgrade,
+		auditTx,
+	).Exec(r.Context()); err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(upgrade.Result())
+}
+
+// unlinkCalculationFormular deletes a calculation formular and relinks its predecessor to its successor in one transaction
+func (h *CalculationHandler) unlinkCalculationFormular(w http.ResponseWriter, r *http.Request, calculationFormular *db.CalculationFormularModel) {
+	prev, err := h.db.CalculationFormular.FindFirst(
+		db.CalculationFormular.NextID.Equals(calculationFormular.ID),
+	).Exec(r.Context())
+
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	var txs []db.PrismaTransaction
+	if prev != nil {
+		txs = append(txs, h.db.CalculationFormular.FindUnique(
+			db.CalculationFormular.ID.Equals(prev.ID),
+		).Update(
+			db.CalculationFormular.NextID.SetOptional(nil),
+		).Tx())
+	}
+
+	txs = append(txs, h.db.CalculationFormular.FindUnique(
 		db.CalculationFormular.ID.Equals(calculationFormular.ID),
-	).Delete().Exec(r.Context())
+	).Delete().Tx())
+
+	if next, ok := calculationFormular.NextID(); ok && prev != nil {
+		txs = append(txs, h.db.CalculationFormular.FindUnique(
+			db.CalculationFormular.ID.Equals(prev.ID),
+		).Update(
+			db.CalculationFormular.NextID.Set(next),
+		).Tx())
+	}
+
+	siblings, err := h.db.CalculationFormular.FindMany(
+		db.CalculationFormular.CalculationID.Equals(calculationFormular.CalculationID),
+	).Exec(r.Context())
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeInternalError(w, r, err)
+		return
+	}
+
+	siblings = currentOrder(siblings, func(link db.CalculationFormularModel) (string, *string) {
+		return link.ID, link.InnerCalculationFormular.NextID
+	})
+	remaining := slices.DeleteFunc(slices.Clone(siblings), func(link db.CalculationFormularModel) bool {
+		return link.ID == calculationFormular.ID
+	})
+	auditTx, err := audit(h.db, r, ActionRemoveFormular, ResourceCalculation, calculationFormular.CalculationID, calculationLinks(siblings), calculationLinks(remaining))
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	txs = append(txs, auditTx)
+
+	if err := h.db.Prisma.Transaction(txs...).Exec(r.Context()); err != nil {
+		writeInternalError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// OrderedCalculationFormular is a calculation formular with its position in the calculation's sequence
+type OrderedCalculationFormular struct {
+	db.CalculationFormularModel
+	Position      int  `json:"position" example:"0"`      // The zero-based position in the sequence
+	Pinned        bool `json:"pinned" example:"true"`     // Whether the formular is pinned to a version rather than floating
+	PinnedVersion *int `json:"pinnedVersion" example:"2"` // The pinned version number, null for floating formulars
+	LatestVersion *int `json:"latestVersion" example:"3"` // The latest version number of the formular, null when none was recorded
+}
+
 // ListFormulars godoc
 // @Summary List formulars in a calculation
-// @Description Get all formulars in a calculation's sequence
+// @Description Get all formulars in a calculation's sequence, ordered by walking the sequence from its head, with whether each is pinned to a version or floating
 // @Tags calculations
 // @Accept json
 // @Produce json
 // @Param id path string true "Calculation ID"
-// @Success 200 {array} db.CalculationFormularModel
+// @Success 200 {array} OrderedCalculationFormular
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 404 {object} APIError "Calculation not found"
+// @Failure 409 {object} APIError{details=IntegrityReport} "Broken formular sequence"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /calculations/{id}/formulars [get]
 func (h *CalculationHandler) ListFormulars(w http.ResponseWriter, r *http.Request) {
 	calculationID := chi.URLParam(r, "id")
 
-	formulars, err := h.db.CalculationFormular.FindMany(
+	if !authorize(w, r, h.db, ResourceCalculation, calculationID, RoleViewer) {
+		return
+	}
+
+	calculationFormulars, err := h.db.CalculationFormular.FindMany(
 		db.CalculationFormular.CalculationID.Equals(calculationID),
 	).With(
 		db.CalculationFormular.Formular.Fetch(),
+		db.CalculationFormular.FormularVersion.Fetch(),
+	).Exec(r.Context())
+
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	formularIDs := make([]string, 0, len(calculationFormulars))
+	for _, calculationFormular := range calculationFormulars {
+		formularIDs = append(formularIDs, calculationFormular.FormularID)
+	}
+
+	versions, err := h.db.FormularVersion.FindMany(
+		db.FormularVersion.FormularID.In(formularIDs),
+	).OrderBy(
+		db.FormularVersion.Version.Order(db.SortOrderDesc),
 	).Exec(r.Context())
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeInternalError(w, r, err)
+		return
+	}
+
+	latest := make(map[string]int)
+	for _, version := range versions {
+		if _, ok := latest[version.FormularID]; !ok {
+			latest[version.FormularID] = version.Version
+		}
+	}
+
+	link := func(cf db.CalculationFormularModel) (string, *string) {
+		return cf.ID, cf.InnerCalculationFormular.NextID
+	}
+
+	ordered, err := orderChain(calculationFormulars, link)
+	if err != nil {
+		writeError(w, r, http.StatusConflict, CodeBrokenSequence, "Broken formular sequence", checkChain(calculationFormulars, link))
 		return
 	}
 
-	json.NewEncoder(w).Encode(formulars)
+	response := make([]OrderedCalculationFormular, 0, len(ordered))
+	for i, calculationFormular := range ordered {
+		item := OrderedCalculationFormular{
+			CalculationFormularModel: calculationFormular,
+			Position:                 i,
+		}
+		if version, ok := calculationFormular.FormularVersion(); ok {
+			item.Pinned = true
+			item.PinnedVersion = &version.Version
+		}
+		if version, ok := latest[calculationFormular.FormularID]; ok {
+			item.LatestVersion = &version
+		}
+		response = append(response, item)
+	}
+
+	json.NewEncoder(w).Encode(response)
 }
 
 // ReorderFormularsInput represents the input for reordering formulars in a calculation
 type ReorderFormularsInput struct {
-	FormularOrder []string `json:"formularOrder" example:"['123e4567-e89b-12d3-a456-426614174000', '123e4567-e89b-12d3-a456-426614174001']"` // The ordered list of formular IDs
+	FormularOrder []string `json:"formularOrder" validate:"dive,uuid" example:"['123e4567-e89b-12d3-a456-426614174000', '123e4567-e89b-12d3-a456-426614174001']"` // The ordered list of formular IDs
 }
 
 // ReorderFormulars godoc
 // @Summary Reorder formulars in a calculation
-// @Description Update the sequence of formulars in a calculation
+// @Description Atomically update the sequence of formulars in a calculation. The order must contain exactly the current formulars of the calculation; repeated formulars keep their relative order. Use the link endpoint to reorder specific occurrences.
 // @Tags calculations
 // @Accept json
 // @Produce json
 // @Param id path string true "Calculation ID"
 // @Param order body ReorderFormularsInput true "New formular order"
 // @Success 200 "OK"
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 403 {object} APIError "Insufficient role"
+// @Failure 404 {object} APIError "Calculation not found"
+// @Failure 409 {object} APIError{details=SequenceConflict} "Order does not match the current formulars"
+// @Failure 422 {object} APIError{details=ValidationError} "Invalid input"
+// @Deprecated
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /calculations/{id}/formulars/reorder [put]
 func (h *CalculationHandler) ReorderFormulars(w http.ResponseWriter, r *http.Request) {
+	var input ReorderFormularsInput
+	if !decodeInput(w, r, &input) {
+		return
+	}
+
+	h.reorder(w, r, func(cf db.CalculationFormularModel) string {
+		return cf.FormularID
+	}, input.FormularOrder)
+}
+
+// ReorderLinks godoc
+// @Summary Reorder the links of a calculation
+// @Description Atomically update the sequence of a calculation by calculation formular IDs. The order must contain exactly the current links of the calculation.
+// @Tags calculations
+// @Accept json
+// @Produce json
+// @Param id path string true "Calculation ID"
+// @Param order body ReorderLinksInput true "New link order"
+// @Success 200 "OK"
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 403 {object} APIError "Insufficient role"
+// @Failure 404 {object} APIError "Calculation not found"
+// @Failure 409 {object} APIError{details=SequenceConflict} "Order does not match the current links"
+// @Failure 422 {object} APIError{details=ValidationError} "Invalid input"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /calculations/{id}/links/reorder [put]
+func (h *CalculationHandler) ReorderLinks(w http.ResponseWriter, r *http.Request) {
+	var input ReorderLinksInput
+	if !decodeInput(w, r, &input) {
+		return
+	}
+
+	h.reorder(w, r, func(cf db.CalculationFormularModel) string {
+		return cf.ID
+	}, input.LinkOrder)
+}
+
+// reorder relinks the sequence of the calculation in the URL so that its members follow order,
+// where member returns the ID a calculation formular is addressed by
+func (h *CalculationHandler) reorder(w http.ResponseWriter, r *http.Request, member func(db.CalculationFormularModel) string, order []string) {
 	calculationID := chi.URLParam(r, "id")
 
-	var input ReorderFormularsInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !authorize(w, r, h.db, ResourceCalculation, calculationID, RoleEditor) {
 		return
 	}
 
-	// Update the next pointers for each formular in the order
-	for i := 0; i < len(input.FormularOrder)-1; i++ {
-		currentFormularID := input.FormularOrder[i]
-		nextFormularID := input.FormularOrder[i+1]
+	calculationFormulars, err := h.db.CalculationFormular.FindMany(
+		db.CalculationFormular.CalculationID.Equals(calculationID),
+	).Exec(r.Context())
 
-		// Find the calculation formular first
-		calculationFormular, err := h.db.CalculationFormular.FindFirst(
-			db.CalculationFormular.CalculationID.Equals(calculationID),
-			db.CalculationFormular.FormularID.Equals(currentFormularID),
-		).Exec(r.Context())
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
 
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+	calculationFormulars = currentOrder(calculationFormulars, func(cf db.CalculationFormularModel) (string, *string) {
+		return cf.ID, cf.InnerCalculationFormular.NextID
+	})
+
+	ordered, ok := matchOrder(calculationFormulars, member, order)
+
+	if !ok {
+		current := make([]string, 0, len(calculationFormulars))
+		for _, calculationFormular := range calculationFormulars {
+			current = append(current, member(calculationFormular))
 		}
 
-		// Then update it
-		_, err = h.db.CalculationFormular.FindUnique(
-			db.CalculationFormular.ID.Equals(calculationFormular.ID),
+		writeError(w, r, http.StatusConflict, CodeSequenceConflict, "Order must contain exactly the current members of the calculation", SequenceConflict{
+			CurrentOrder: current,
+		})
+		return
+	}
+
+	if len(ordered) == 0 {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// Clear every next pointer first so relinking never violates the unique constraint
+	txs := []db.PrismaTransaction{
+		h.db.CalculationFormular.FindMany(
+			db.CalculationFormular.CalculationID.Equals(calculationID),
 		).Update(
-			db.CalculationFormular.NextID.Set(nextFormularID),
-		).Exec(r.Context())
+			db.CalculationFormular.NextID.SetOptional(nil),
+		).Tx(),
+	}
+
+	for i := 0; i < len(ordered)-1; i++ {
+		txs = append(txs, h.db.CalculationFormular.FindUnique(
+			db.CalculationFormular.ID.Equals(ordered[i].ID),
+		).Update(
+			db.CalculationFormular.NextID.Set(ordered[i+1].ID),
+		).Tx())
+	}
+
+	auditTx, err := audit(h.db, r, ActionReorderFormulars, ResourceCalculation, calculationID, calculationLinks(calculationFormulars), calculationLinks(ordered))
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	txs = append(txs, auditTx)
+
+	if err := h.db.Prisma.Transaction(txs...).Exec(r.Context()); err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// ListVariables godoc
+// @Summary List variables of a calculation
+// @Description Get all named input variables of a calculation
+// @Tags calculations
+// @Accept json
+// @Produce json
+// @Param id path string true "Calculation ID"
+// @Success 200 {array} db.VariableModel
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 404 {object} APIError "Calculation not found"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /calculations/{id}/variables [get]
+func (h *CalculationHandler) ListVariables(w http.ResponseWriter, r *http.Request) {
+	calculationID := chi.URLParam(r, "id")
+
+	if !authorize(w, r, h.db, ResourceCalculation, calculationID, RoleViewer) {
+		return
+	}
+
+	variables, err := h.db.Variable.FindMany(
+		db.Variable.CalculationID.Equals(calculationID),
+	).OrderBy(
+		db.Variable.Name.Order(db.SortOrderAsc),
+	).Exec(r.Context())
+
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(variables)
+}
+
+// AddVariableInput represents the input for adding a variable to a calculation
+type AddVariableInput struct {
+	Name         string  `json:"name" validate:"required,max=64" example:"qty"`                                      // The name used to reference the variable in nodes
+	Type         string  `json:"type,omitempty" validate:"omitempty,oneof=number integer boolean" example:"integer"` // The type of the variable: number, integer or boolean (default number)
+	DefaultValue *string `json:"defaultValue,omitempty" validate:"max=64" example:"1"`                               // Optional value used when no input is given
+	Min          *string `json:"min,omitempty" validate:"max=64" example:"0"`                                        // Optional inclusive lower bound
+	Max          *string `json:"max,omitempty" validate:"max=64" example:"1000"`                                     // Optional inclusive upper bound
+}
+
+// AddVariable godoc
+// @Summary Add a variable to a calculation
+// @Description Declare a named input variable that nodes can reference
+// @Tags calculations
+// @Accept json
+// @Produce json
+// @Param id path string true "Calculation ID"
+// @Param variable body AddVariableInput true "Variable to add"
+// @Success 201 {object} db.VariableModel
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 403 {object} APIError "Insufficient role"
+// @Failure 404 {object} APIError "Calculation not found"
+// @Failure 409 {object} APIError{details=UniqueConflict} "Variable already exists"
+// @Failure 422 {object} APIError{details=ValidationError} "Invalid input or variable definition"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /calculations/{id}/variables [post]
+func (h *CalculationHandler) AddVariable(w http.ResponseWriter, r *http.Request) {
+	calculationID := chi.URLParam(r, "id")
+
+	var input AddVariableInput
+	if !decodeInput(w, r, &input) {
+		return
+	}
+
+	if !authorize(w, r, h.db, ResourceCalculation, calculationID, RoleEditor) {
+		return
+	}
+
+	if input.Type == "" {
+		input.Type = engine.VariableNumber
+	}
+
+	if _, err := engine.NewVariable(input.Name, input.Type, input.DefaultValue, input.Min, input.Max); err != nil {
+		writeError(w, r, http.StatusUnprocessableEntity, CodeInvalid, err.Error(), nil)
+		return
+	}
+
+	// Errors of a transaction do not identify unique conflicts, so look for the name up front
+	_, err := h.db.Variable.FindUnique(
+		db.Variable.CalculationIDName(
+			db.Variable.CalculationID.Equals(calculationID),
+			db.Variable.Name.Equals(input.Name),
+		),
+	).Exec(r.Context())
+
+	if err == nil {
+		writeError(w, r, http.StatusConflict, CodeConflict, "Variable already exists", UniqueConflict{Fields: []string{"calculationId", "name"}})
+		return
+	}
+	if !errors.Is(err, db.ErrNotFound) {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	now := time.Now()
+	variable := db.VariableModel{InnerVariable: db.InnerVariable{
+		ID:            newID(),
+		CalculationID: calculationID,
+		Name:          input.Name,
+		Type:          input.Type,
+		DefaultValue:  input.DefaultValue,
+		Min:           input.Min,
+		Max:           input.Max,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}}
+
+	create := h.db.Variable.CreateOne(
+		db.Variable.Calculation.Link(db.Calculation.ID.Equals(calculationID)),
+		db.Variable.Name.Set(variable.Name),
+		db.Variable.ID.Set(variable.ID),
+		db.Variable.Type.Set(variable.Type),
+		db.Variable.DefaultValue.SetIfPresent(variable.InnerVariable.DefaultValue),
+		db.Variable.Min.SetIfPresent(variable.InnerVariable.Min),
+		db.Variable.Max.SetIfPresent(variable.InnerVariable.Max),
+		db.Variable.CreatedAt.Set(now),
+		db.Variable.UpdatedAt.Set(now),
+	).Tx()
+
+	auditTx, err := audit(h.db, r, ActionAddVariable, ResourceCalculation, calculationID, nil, variable)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	if err := h.db.Prisma.Transaction(
+		create,
+		auditTx,
+	).Exec(r.Context()); err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(create.Result())
+}
+
+// RemoveVariable godoc
+// @Summary Remove a variable from a calculation
+// @Description Delete a named input variable of a calculation
+// @Tags calculations
+// @Accept json
+// @Produce json
+// @Param id path string true "Calculation ID"
+// @Param variableId path string true "Variable ID"
+// @Success 204 "No Content"
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 403 {object} APIError "Insufficient role"
+// @Failure 404 {object} APIError "Calculation or variable not found"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /calculations/{id}/variables/{variableId} [delete]
+func (h *CalculationHandler) RemoveVariable(w http.ResponseWriter, r *http.Request) {
+	calculationID := chi.URLParam(r, "id")
+	variableID := chi.URLParam(r, "variableId")
+
+	if !authorize(w, r, h.db, ResourceCalculation, calculationID, RoleEditor) {
+		return
+	}
+
+	// Find the variable first to make sure it belongs to the calculation
+	variable, err := h.db.Variable.FindFirst(
+		db.Variable.ID.Equals(variableID),
+		db.Variable.CalculationID.Equals(calculationID),
+	).Exec(r.Context())
+
+	if err != nil {
+		writeDBError(w, r, err, "Variable")
+		return
+	}
+
+	// Then delete it
+	auditTx, err := audit(h.db, r, ActionRemoveVariable, ResourceCalculation, calculationID, variable, nil)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	err = h.db.Prisma.Transaction(
+		h.db.Variable.FindUnique(
+			db.Variable.ID.Equals(variable.ID),
+		).Delete().Tx(),
+		auditTx,
+	).Exec(r.Context())
+
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Evaluate godoc
+// @Summary Evaluate a calculation
+// @Description Compute a calculation by evaluating its formulars and their nodes in sequence order, using the default value of every variable. A soft-deleted formular, or a soft-deleted node of an unpinned formular, fails the evaluation instead of being skipped.
+// @Tags calculations
+// @Accept json
+// @Produce json
+// @Param id path string true "Calculation ID"
+// @Success 200 {object} engine.Result
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 404 {object} APIError "Calculation not found"
+// @Failure 409 {object} APIError "Broken formular or node sequence, or a deleted formular or node in it"
+// @Failure 422 {object} APIError{details=engine.Error} "Calculation cannot be evaluated"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /calculations/{id}/evaluate [post]
+func (h *CalculationHandler) Evaluate(w http.ResponseWriter, r *http.Request) {
+	h.run(w, r, nil)
+}
+
+// RunCalculationInput represents the input for running a calculation
+type RunCalculationInput struct {
+	Inputs map[string]json.RawMessage `json:"inputs" swaggertype:"object,string" example:"qty:3,price:19.99"` // The value of each variable by name
+}
+
+// Run godoc
+// @Summary Run a calculation with inputs
+// @Description Bind the given inputs to the calculation's variables and compute the calculation. A soft-deleted formular, or a soft-deleted node of an unpinned formular, fails the evaluation instead of being skipped.
+// @Tags calculations
+// @Accept json
+// @Produce json
+// @Param id path string true "Calculation ID"
+// @Param inputs body RunCalculationInput true "Variable inputs"
+// @Success 200 {object} engine.Result
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 404 {object} APIError "Calculation not found"
+// @Failure 409 {object} APIError "Broken formular or node sequence, or a deleted formular or node in it"
+// @Failure 422 {object} APIError "Missing or invalid inputs, or calculation cannot be evaluated"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /calculations/{id}/run [post]
+func (h *CalculationHandler) Run(w http.ResponseWriter, r *http.Request) {
+	var input RunCalculationInput
+	if !decodeInput(w, r, &input) {
+		return
+	}
+
+	h.run(w, r, input.Inputs)
+}
+
+// run binds inputs to the variables of the calculation in the URL and evaluates it
+func (h *CalculationHandler) run(w http.ResponseWriter, r *http.Request, inputs map[string]json.RawMessage) {
+	id := chi.URLParam(r, "id")
+
+	if !authorize(w, r, h.db, ResourceCalculation, id, RoleViewer) {
+		return
+	}
+
+	_, err := h.db.Calculation.FindFirst(
+		db.Calculation.ID.Equals(id),
+		db.Calculation.DeletedAt.IsNull(),
+	).Exec(r.Context())
+
+	if err != nil {
+		writeDBError(w, r, err, "Calculation")
+		return
+	}
+
+	variables, err := h.loadVariables(r.Context(), id)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	bindings, err := engine.Bind(variables, inputs)
+	if err != nil {
+		writeError(w, r, http.StatusUnprocessableEntity, CodeInvalid, err.Error(), err)
+		return
+	}
+
+	formulars, err := h.loadFormulars(r.Context(), id)
+	if errors.Is(err, errBrokenChain) {
+		writeError(w, r, http.StatusConflict, CodeBrokenSequence, err.Error(), nil)
+		return
+	}
+	if errors.Is(err, errDeletedMember) {
+		writeError(w, r, http.StatusConflict, CodeConflict, err.Error(), nil)
+		return
+	}
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	start := time.Now()
+	result, err := engine.Evaluate(formulars, bindings)
+	observeEngine("evaluate", start, err)
+	if err != nil {
+		writeError(w, r, http.StatusUnprocessableEntity, CodeInvalid, err.Error(), err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(result)
+}
+
+// loadVariables fetches the variables of a calculation as engine variables
+func (h *CalculationHandler) loadVariables(ctx context.Context, calculationID string) ([]engine.Variable, error) {
+	rows, err := h.db.Variable.FindMany(
+		db.Variable.CalculationID.Equals(calculationID),
+	).OrderBy(
+		db.Variable.Name.Order(db.SortOrderAsc),
+	).Exec(ctx)
 
+	if err != nil {
+		return nil, err
+	}
+
+	variables := make([]engine.Variable, 0, len(rows))
+	for _, row := range rows {
+		variable, err := engine.NewVariable(row.Name, row.Type, row.InnerVariable.DefaultValue, row.InnerVariable.Min, row.InnerVariable.Max)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			return nil, fmt.Errorf("variable %s: %w", row.Name, err)
 		}
+		variables = append(variables, variable)
 	}
 
-	// Clear the next pointer for the last formular
-	// Find the last calculation formular first
-	lastFormular, err := h.db.CalculationFormular.FindFirst(
+	return variables, nil
+}
+
+// loadFormulars fetches the formulars of a calculation with their nodes, both in sequence order.
+// Pinned formulars are loaded from their version snapshot. A soft-deleted formular, or a
+// soft-deleted node of a live formular, fails with errDeletedMember rather than being skipped, as
+// leaving it out would silently change the result and the purger would remove it later anyway.
+func (h *CalculationHandler) loadFormulars(ctx context.Context, calculationID string) ([]engine.Formular, error) {
+	calculationFormulars, err := h.db.CalculationFormular.FindMany(
 		db.CalculationFormular.CalculationID.Equals(calculationID),
-		db.CalculationFormular.FormularID.Equals(input.FormularOrder[len(input.FormularOrder)-1]),
+	).With(
+		db.CalculationFormular.Formular.Fetch().With(
+			db.Formular.Nodes.Fetch().With(
+				db.FormularNode.Node.Fetch(),
+			),
+		),
+		db.CalculationFormular.FormularVersion.Fetch(),
+	).Exec(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	calculationFormulars, err = orderChain(calculationFormulars, func(cf db.CalculationFormularModel) (string, *string) {
+		return cf.ID, cf.InnerCalculationFormular.NextID
+	})
+	if err != nil {
+		return nil, fmt.Errorf("calculation %s: %w", calculationID, err)
+	}
+
+	formulars := make([]engine.Formular, 0, len(calculationFormulars))
+	for _, calculationFormular := range calculationFormulars {
+		formular := calculationFormular.Formular()
+		if _, deleted := formular.DeletedAt(); deleted {
+			return nil, fmt.Errorf("formular %s %w", formular.ID, errDeletedMember)
+		}
+
+		if version, ok := calculationFormular.FormularVersion(); ok {
+			snapshot, err := newFormularVersion(version)
+			if err != nil {
+				return nil, fmt.Errorf("formular %s version %d: %w", formular.ID, version.Version, err)
+			}
+
+			nodes := make([]engine.Node, 0, len(snapshot.Nodes))
+			for _, node := range snapshot.Nodes {
+				nodes = append(nodes, engine.Node{ID: node.NodeID, Data: node.NodeData})
+			}
+
+			formulars = append(formulars, engine.Formular{
+				ID:    formular.ID,
+				Name:  snapshot.Name,
+				Nodes: nodes,
+			})
+			continue
+		}
+
+		formularNodes, err := orderChain(formular.Nodes(), func(fn db.FormularNodeModel) (string, *string) {
+			return fn.ID, fn.InnerFormularNode.NextID
+		})
+		if err != nil {
+			return nil, fmt.Errorf("formular %s: %w", formular.ID, err)
+		}
+
+		nodes := make([]engine.Node, 0, len(formularNodes))
+		for _, formularNode := range formularNodes {
+			node := formularNode.Node()
+			if _, deleted := node.DeletedAt(); deleted {
+				return nil, fmt.Errorf("node %s of formular %s %w", node.ID, formular.ID, errDeletedMember)
+			}
+			nodes = append(nodes, engine.Node{
+				ID:   node.ID,
+				Data: node.NodeData,
+			})
+		}
+
+		formulars = append(formulars, engine.Formular{
+			ID:    formular.ID,
+			Name:  formular.Name,
+			Nodes: nodes,
+		})
+	}
+
+	return formulars, nil
+}
+
+// Integrity godoc
+// @Summary Check the formular sequence of a calculation
+// @Description Report multiple heads, cycles, orphans and dangling next pointers in a calculation's formular sequence
+// @Tags calculations
+// @Accept json
+// @Produce json
+// @Param id path string true "Calculation ID"
+// @Success 200 {object} IntegrityReport
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 404 {object} APIError "Calculation not found"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /calculations/{id}/integrity [get]
+func (h *CalculationHandler) Integrity(w http.ResponseWriter, r *http.Request) {
+	calculationID := chi.URLParam(r, "id")
+
+	if !authorize(w, r, h.db, ResourceCalculation, calculationID, RoleViewer) {
+		return
+	}
+
+	_, err := h.db.Calculation.FindUnique(
+		db.Calculation.ID.Equals(calculationID),
 	).Exec(r.Context())
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeDBError(w, r, err, "Calculation")
 		return
 	}
 
-	// Then update it
-	_, err = h.db.CalculationFormular.FindUnique(
-		db.CalculationFormular.ID.Equals(lastFormular.ID),
-	).Update(
-		db.CalculationFormular.NextID.SetOptional(nil),
+	calculationFormulars, err := h.db.CalculationFormular.FindMany(
+		db.CalculationFormular.CalculationID.Equals(calculationID),
 	).Exec(r.Context())
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeInternalError(w, r, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	report := checkChain(calculationFormulars, func(cf db.CalculationFormularModel) (string, *string) {
+		return cf.ID, cf.InnerCalculationFormular.NextID
+	})
+
+	json.NewEncoder(w).Encode(report)
 }
@@ -0,0 +1,48 @@
+package handlers_test
+
+import (
+	"backend/handlers"
+	"backend/prisma/db"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvaluateRejectsDeletedFormular(t *testing.T) {
+	client, m, ensure := db.NewMock()
+	expectLookup(client, m, handlers.ResourceCalculation, calculation)
+	m.Calculation.Expect(client.Calculation.FindFirst(
+		db.Calculation.ID.Equals(calculation),
+		db.Calculation.DeletedAt.IsNull(),
+	)).Returns(db.CalculationModel{InnerCalculation: db.InnerCalculation{ID: calculation, Name: "Mine", WorkspaceID: workspaceA}})
+	m.Variable.Expect(client.Variable.FindMany(
+		db.Variable.CalculationID.Equals(calculation),
+	).OrderBy(
+		db.Variable.Name.Order(db.SortOrderAsc),
+	)).ReturnsMany(nil)
+
+	deletedAt := time.Now()
+	m.CalculationFormular.Expect(client.CalculationFormular.FindMany(
+		db.CalculationFormular.CalculationID.Equals(calculation),
+	).With(
+		db.CalculationFormular.Formular.Fetch().With(
+			db.Formular.Nodes.Fetch().With(
+				db.FormularNode.Node.Fetch(),
+			),
+		),
+		db.CalculationFormular.FormularVersion.Fetch(),
+	)).ReturnsMany([]db.CalculationFormularModel{{
+		InnerCalculationFormular: db.InnerCalculationFormular{ID: usage, CalculationID: calculation, FormularID: formular},
+		RelationsCalculationFormular: db.RelationsCalculationFormular{
+			Formular: &db.FormularModel{InnerFormular: db.InnerFormular{ID: formular, Name: "Deleted", WorkspaceID: workspaceA, DeletedAt: &deletedAt}},
+		},
+	}})
+
+	w := request(handlers.NewCalculationHandler(client).Routes(), http.MethodPost, "/"+calculation+"/evaluate", "")
+
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "is deleted") {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusConflict, w.Body)
+	}
+	ensure(t)
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// errBrokenChain is returned when a sequence cannot be walked from a single head
+var errBrokenChain = errors.New("broken chain")
+
+// errNotInChain is returned when a referenced member is not part of the sequence
+var errNotInChain = errors.New("not part of the sequence")
+
+// errDeletedMember is returned when a sequence contains a soft-deleted formular or node
+var errDeletedMember = errors.New("is deleted, restore it or remove it from the sequence")
+
+// orderChain sorts the members of a linked sequence by walking next pointers from the head.
+// The link function returns the ID of a member and the ID of its successor.
+func orderChain[T any](items []T, link func(T) (string, *string)) ([]T, error) {
+	if len(items) == 0 {
+		return items, nil
+	}
+
+	byID := make(map[string]T, len(items))
+	referenced := make(map[string]bool, len(items))
+	for _, item := range items {
+		id, next := link(item)
+		byID[id] = item
+		if next != nil {
+			referenced[*next] = true
+		}
+	}
+
+	var heads []string
+	for _, item := range items {
+		if id, _ := link(item); !referenced[id] {
+			heads = append(heads, id)
+		}
+	}
+	if len(heads) != 1 {
+		return nil, fmt.Errorf("%w: found %d heads", errBrokenChain, len(heads))
+	}
+
+	ordered := make([]T, 0, len(items))
+	visited := make(map[string]bool, len(items))
+	for id := &heads[0]; id != nil; {
+		item, ok := byID[*id]
+		if !ok {
+			return nil, fmt.Errorf("%w: next pointer to missing member %s", errBrokenChain, *id)
+		}
+		if visited[*id] {
+			return nil, fmt.Errorf("%w: cycle at %s", errBrokenChain, *id)
+		}
+		visited[*id] = true
+		ordered = append(ordered, item)
+		_, id = link(item)
+	}
+
+	if len(ordered) != len(items) {
+		return nil, fmt.Errorf("%w: %d of %d members are unreachable from the head", errBrokenChain, len(items)-len(ordered), len(items))
+	}
+
+	return ordered, nil
+}
+
+// insertPosition returns the neighbours of a member inserted into an ordered sequence.
+// The new member goes before the member with ID before, after the member with ID after,
+// or at the tail when neither is given. A nil neighbour means the new member is the head or tail.
+func insertPosition[T any](ordered []T, id func(T) string, before, after *string) (prev, next *T, err error) {
+	if before != nil && after != nil {
+		return nil, nil, errors.New("only one of nextId and afterId may be given")
+	}
+
+	target := before
+	if after != nil {
+		target = after
+	}
+	if target == nil {
+		if len(ordered) > 0 {
+			prev = &ordered[len(ordered)-1]
+		}
+		return prev, nil, nil
+	}
+
+	for i := range ordered {
+		if id(ordered[i]) != *target {
+			continue
+		}
+		if before != nil {
+			if i > 0 {
+				prev = &ordered[i-1]
+			}
+			return prev, &ordered[i], nil
+		}
+		if i < len(ordered)-1 {
+			next = &ordered[i+1]
+		}
+		return &ordered[i], next, nil
+	}
+
+	return nil, nil, fmt.Errorf("%s is %w", *target, errNotInChain)
+}
+
+// IntegrityReport describes the structural health of a linked sequence
+type IntegrityReport struct {
+	Healthy  bool       `json:"healthy" example:"false"`                                 // Whether the sequence forms a single well-formed chain
+	Length   int        `json:"length" example:"3"`                                      // The number of members in the sequence
+	Heads    []string   `json:"heads" example:"123e4567-e89b-12d3-a456-426614174000"`    // Members that no other member points to
+	Orphans  []string   `json:"orphans" example:"123e4567-e89b-12d3-a456-426614174001"`  // Members not reachable from the head of the longest chain
+	Cycles   [][]string `json:"cycles"`                                                  // Members that point back to each other in a loop
+	Dangling []string   `json:"dangling" example:"123e4567-e89b-12d3-a456-426614174002"` // Members whose next pointer leaves the sequence
+}
+
+// checkChain inspects a linked sequence for multiple heads, cycles, orphans and dangling pointers
+func checkChain[T any](items []T, link func(T) (string, *string)) IntegrityReport {
+	report := IntegrityReport{
+		Length:   len(items),
+		Heads:    []string{},
+		Orphans:  []string{},
+		Cycles:   [][]string{},
+		Dangling: []string{},
+	}
+
+	ids := make([]string, 0, len(items))
+	members := make(map[string]bool, len(items))
+	for _, item := range items {
+		id, _ := link(item)
+		ids = append(ids, id)
+		members[id] = true
+	}
+
+	next := make(map[string]string, len(items))
+	referenced := make(map[string]bool, len(items))
+	for _, item := range items {
+		id, nextID := link(item)
+		if nextID == nil {
+			continue
+		}
+		if !members[*nextID] {
+			report.Dangling = append(report.Dangling, id)
+			continue
+		}
+		next[id] = *nextID
+		referenced[*nextID] = true
+	}
+
+	// Walk every fragment from its head and remember the longest one
+	reachable := make(map[string]bool, len(items))
+	var longest []string
+	for _, id := range ids {
+		if referenced[id] {
+			continue
+		}
+		report.Heads = append(report.Heads, id)
+
+		var fragment []string
+		for current, ok := id, true; ok; current, ok = next[current] {
+			reachable[current] = true
+			fragment = append(fragment, current)
+		}
+		if len(fragment) > len(longest) {
+			longest = fragment
+		}
+	}
+
+	// Every member not reachable from a head is part of a cycle, as each member has at most one predecessor
+	inCycle := make(map[string]bool)
+	for _, id := range ids {
+		if reachable[id] || inCycle[id] {
+			continue
+		}
+		var cycle []string
+		for current := id; !inCycle[current]; current = next[current] {
+			inCycle[current] = true
+			cycle = append(cycle, current)
+		}
+		report.Cycles = append(report.Cycles, cycle)
+	}
+
+	main := make(map[string]bool, len(longest))
+	for _, id := range longest {
+		main[id] = true
+	}
+	for _, id := range ids {
+		if !main[id] {
+			report.Orphans = append(report.Orphans, id)
+		}
+	}
+
+	report.Healthy = len(report.Orphans) == 0 && len(report.Cycles) == 0 && len(report.Dangling) == 0 && len(report.Heads) <= 1
+	return report
+}
+
+// SequenceConflict details an error for a submitted order that does not match the current members of a sequence
+type SequenceConflict struct {
+	CurrentOrder []string `json:"currentOrder" example:"123e4567-e89b-12d3-a456-426614174000"` // The current order of member IDs
+}
+
+// ReorderLinksInput represents the input for reordering a sequence by its link IDs
+type ReorderLinksInput struct {
+	LinkOrder []string `json:"linkOrder" validate:"dive,uuid" example:"['123e4567-e89b-12d3-a456-426614174000', '123e4567-e89b-12d3-a456-426614174001']"` // The ordered list of link IDs
+}
+
+// currentOrder returns the members of a sequence in chain order, or in the given order when the chain is broken
+func currentOrder[T any](items []T, link func(T) (string, *string)) []T {
+	if ordered, err := orderChain(items, link); err == nil {
+		return ordered
+	}
+	return items
+}
+
+// matchOrder maps an ordered list of member IDs onto the rows of a sequence.
+// Members that appear several times are assigned rows in their current order.
+// It reports false unless order contains exactly the current members.
+func matchOrder[T any](items []T, member func(T) string, order []string) ([]T, bool) {
+	if len(order) != len(items) {
+		return nil, false
+	}
+
+	available := make(map[string][]T, len(items))
+	for _, item := range items {
+		id := member(item)
+		available[id] = append(available[id], item)
+	}
+
+	matched := make([]T, 0, len(order))
+	for _, id := range order {
+		candidates := available[id]
+		if len(candidates) == 0 {
+			return nil, false
+		}
+		matched = append(matched, candidates[0])
+		available[id] = candidates[1:]
+	}
+
+	return matched, true
+}
+
+// newID returns a random UUID so rows created in one transaction can reference each other
+func newID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,509 @@
+package handlers
+
+import (
+	"backend/prisma/db"
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// Delete modes
+const (
+	// DeleteRestrict refuses to delete a record that is still referenced
+	DeleteRestrict = "restrict"
+	// DeleteDetach removes every reference to the record, relinking the sequences it is removed from
+	DeleteDetach = "detach"
+	// DeleteCascade detaches the record and also deletes the formulars and nodes it leaves unused,
+	// as far as the caller owns them
+	DeleteCascade = "cascade"
+)
+
+// Dependent types
+const (
+	DependentFormularNode        = "formularNode"
+	DependentCalculationFormular = "calculationFormular"
+	DependentVariable            = "variable"
+)
+
+// Dependent is a record that references the record being deleted
+type Dependent struct {
+	Type     string `json:"type" example:"formularNode" enums:"formularNode,calculationFormular,variable"` // The kind of the referencing record
+	ID       string `json:"id" example:"123e4567-e89b-12d3-a456-426614174000"`                             // The ID of the referencing record
+	ParentID string `json:"parentId" example:"123e4567-e89b-12d3-a456-426614174001"`                       // The formular or calculation the referencing record belongs to
+}
+
+// DependentsConflict details an error for a restricted delete of a record that is still referenced
+type DependentsConflict struct {
+	Dependents []Dependent `json:"dependents"`         // The records that reference the record being deleted, in formulars and calculations the caller has a role on
+	Hidden     int         `json:"hidden" example:"0"` // The number of further referencing records, in formulars and calculations the caller has no role on
+}
+
+// dependentParents are the kinds of record the dependents of each type belong to
+var dependentParents = map[string]string{
+	DependentFormularNode:        ResourceFormular,
+	DependentCalculationFormular: ResourceCalculation,
+	DependentVariable:            ResourceCalculation,
+}
+
+// parseDeleteQuery reads the permanent flag and the mode query parameter, which defaults to restrict.
+// A mode is rejected on a soft delete, which keeps every reference, rather than silently ignored.
+func parseDeleteQuery(r *http.Request) (bool, string, []FieldProblem) {
+	permanent, problems := queryFlag(r, "permanent")
+
+	mode := r.URL.Query().Get("mode")
+	switch mode {
+	case "":
+		mode = DeleteRestrict
+	case DeleteRestrict, DeleteDetach, DeleteCascade:
+		if !permanent && len(problems) == 0 {
+			problems = append(problems, FieldProblem{Field: "mode", Message: "requires permanent=true"})
+		}
+	default:
+		problems = append(problems, FieldProblem{Field: "mode", Message: fmt.Sprintf("must be one of %s, %s, %s", DeleteRestrict, DeleteDetach, DeleteCascade)})
+	}
+
+	return permanent, mode, problems
+}
+
+// permitDeletion checks that the request's principal may permanently delete the record id with
+// the given dependents. In restrict mode any dependent prevents the delete with a 409, which lists
+// only the dependents in formulars and calculations the principal has a role on and counts the
+// others. Otherwise the delete changes the sequences of the formulars and calculations the
+// dependents belong to, so the principal needs the editor role on each of them or gets a 403. It
+// returns whether the delete may proceed.
+func permitDeletion(w http.ResponseWriter, r *http.Request, client *db.PrismaClient, resource, id, mode string, dependents []Dependent) bool {
+	// The principal is already authorized on the record itself, which owns its own sequence
+	roles := map[string]string{id: RoleOwner}
+	for _, dependent := range dependents {
+		if _, ok := roles[dependent.ParentID]; ok {
+			continue
+		}
+		role, err := roleOf(r.Context(), client, principalID(r), dependentParents[dependent.Type], dependent.ParentID)
+		if err != nil {
+			writeInternalError(w, r, err)
+			return false
+		}
+		roles[dependent.ParentID] = role
+	}
+
+	if mode == DeleteRestrict {
+		if len(dependents) == 0 {
+			return true
+		}
+
+		visible := make([]Dependent, 0, len(dependents))
+		for _, dependent := range dependents {
+			if roles[dependent.ParentID] != "" {
+				visible = append(visible, dependent)
+			}
+		}
+		message := fmt.Sprintf("%s is referenced by %d records, delete with mode=%s or mode=%s to remove them", resourceNames[resource], len(dependents), DeleteDetach, DeleteCascade)
+		writeError(w, r, http.StatusConflict, CodeHasDependents, message, DependentsConflict{Dependents: visible, Hidden: len(dependents) - len(visible)})
+		return false
+	}
+
+	for _, role := range roles {
+		if roleRanks[role] < roleRanks[RoleEditor] {
+			writeError(w, r, http.StatusForbidden, CodeForbidden, "The "+RoleEditor+" role is required on every formular and calculation the delete changes", nil)
+			return false
+		}
+	}
+	return true
+}
+
+// nodeDeletion returns the transactions that permanently delete a node and the node's dependents,
+// the links of the formulars that use it. In restrict mode it returns no transactions when there are
+// dependents.
+func nodeDeletion(ctx context.Context, client *db.PrismaClient, id, mode string) ([]db.PrismaTransaction, []Dependent, error) {
+	usages, err := client.FormularNode.FindMany(
+		db.FormularNode.NodeID.Equals(id),
+	).Exec(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dependents := formularNodeDependents(usages)
+	if mode == DeleteRestrict && len(dependents) > 0 {
+		return nil, dependents, nil
+	}
+
+	txs, err := unlinkFormularNodes(ctx, client, usages)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	txs = append(txs,
+		client.Node.FindUnique(
+			db.Node.ID.Equals(id),
+		).Delete().Tx(),
+		revokeAll(client, ResourceNode, id),
+	)
+
+	return txs, dependents, nil
+}
+
+// formularDeletion returns the transactions that permanently delete a formular and the formular's
+// dependents, its own node links and the links of the calculations that use it. In restrict mode it
+// returns no transactions when there are dependents.
+func formularDeletion(ctx context.Context, client *db.PrismaClient, id, mode string) ([]db.PrismaTransaction, []Dependent, error) {
+	formularNodes, err := client.FormularNode.FindMany(
+		db.FormularNode.FormularID.Equals(id),
+	).Exec(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	usages, err := client.CalculationFormular.FindMany(
+		db.CalculationFormular.FormularID.Equals(id),
+	).Exec(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dependents := append(formularNodeDependents(formularNodes), calculationFormularDependents(usages)...)
+	if mode == DeleteRestrict && len(dependents) > 0 {
+		return nil, dependents, nil
+	}
+
+	txs, err := unlinkCalculationFormulars(ctx, client, usages)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if mode == DeleteCascade {
+		cascade, err := deleteFormulars(ctx, client, []string{id})
+		if err != nil {
+			return nil, nil, err
+		}
+		return append(txs, cascade...), dependents, nil
+	}
+
+	txs = append(txs,
+		client.FormularNode.FindMany(
+			db.FormularNode.FormularID.Equals(id),
+		).Update(
+			db.FormularNode.NextID.SetOptional(nil),
+		).Tx(),
+		client.FormularNode.FindMany(
+			db.FormularNode.FormularID.Equals(id),
+		).Delete().Tx(),
+		client.FormularVersion.FindMany(
+			db.FormularVersion.FormularID.Equals(id),
+		).Delete().Tx(),
+		client.Formular.FindUnique(
+			db.Formular.ID.Equals(id),
+		).Delete().Tx(),
+		revokeAll(client, ResourceFormular, id),
+	)
+
+	return txs, dependents, nil
+}
+
+// calculationDeletion returns the transactions that permanently delete a calculation and the
+// calculation's dependents, its own formular links and variables. In restrict mode it returns no
+// transactions when there are dependents.
+func calculationDeletion(ctx context.Context, client *db.PrismaClient, id, mode string) ([]db.PrismaTransaction, []Dependent, error) {
+	calculationFormulars, err := client.CalculationFormular.FindMany(
+		db.CalculationFormular.CalculationID.Equals(id),
+	).Exec(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	variables, err := client.Variable.FindMany(
+		db.Variable.CalculationID.Equals(id),
+	).Exec(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dependents := calculationFormularDependents(calculationFormulars)
+	for _, variable := range variables {
+		dependents = append(dependents, Dependent{Type: DependentVariable, ID: variable.ID, ParentID: id})
+	}
+	if mode == DeleteRestrict && len(dependents) > 0 {
+		return nil, dependents, nil
+	}
+
+	txs := []db.PrismaTransaction{
+		client.CalculationFormular.FindMany(
+			db.CalculationFormular.CalculationID.Equals(id),
+		).Update(
+			db.CalculationFormular.NextID.SetOptional(nil),
+		).Tx(),
+		client.CalculationFormular.FindMany(
+			db.CalculationFormular.CalculationID.Equals(id),
+		).Delete().Tx(),
+		client.Variable.FindMany(
+			db.Variable.CalculationID.Equals(id),
+		).Delete().Tx(),
+	}
+
+	if mode == DeleteCascade {
+		orphanIDs, err := orphanedFormulars(ctx, client, id, calculationFormulars)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		cascade, err := deleteFormulars(ctx, client, orphanIDs)
+		if err != nil {
+			return nil, nil, err
+		}
+		txs = append(txs, cascade...)
+	}
+
+	txs = append(txs,
+		client.Calculation.FindUnique(
+			db.Calculation.ID.Equals(id),
+		).Delete().Tx(),
+		revokeAll(client, ResourceCalculation, id),
+	)
+
+	return txs, dependents, nil
+}
+
+// orphanedFormulars returns the formulars of the given links that no other calculation uses and
+// that the principal deleting the calculation owns. Formulars it does not own are left unused.
+func orphanedFormulars(ctx context.Context, client *db.PrismaClient, calculationID string, calculationFormulars []db.CalculationFormularModel) ([]string, error) {
+	var formularIDs []string
+	for _, calculationFormular := range calculationFormulars {
+		if !slices.Contains(formularIDs, calculationFormular.FormularID) {
+			formularIDs = append(formularIDs, calculationFormular.FormularID)
+		}
+	}
+
+	used, err := client.CalculationFormular.FindMany(
+		db.CalculationFormular.FormularID.In(formularIDs),
+		db.CalculationFormular.CalculationID.Not(calculationID),
+	).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	formularIDs = slices.DeleteFunc(formularIDs, func(formularID string) bool {
+		return slices.ContainsFunc(used, func(cf db.CalculationFormularModel) bool {
+			return cf.FormularID == formularID
+		})
+	})
+
+	return ownedIDs(ctx, client, ResourceFormular, formularIDs)
+}
+
+// formularNodeDependents lists formular node links as dependents
+func formularNodeDependents(links []db.FormularNodeModel) []Dependent {
+	dependents := make([]Dependent, 0, len(links))
+	for _, link := range links {
+		dependents = append(dependents, Dependent{Type: DependentFormularNode, ID: link.ID, ParentID: link.FormularID})
+	}
+	return dependents
+}
+
+// calculationFormularDependents lists calculation formular links as dependents
+func calculationFormularDependents(links []db.CalculationFormularModel) []Dependent {
+	dependents := make([]Dependent, 0, len(links))
+	for _, link := range links {
+		dependents = append(dependents, Dependent{Type: DependentCalculationFormular, ID: link.ID, ParentID: link.CalculationID})
+	}
+	return dependents
+}
+
+// unlinkFormularNodes returns the transactions that remove the given links from their formulars.
+// Every affected formular is relinked in its current order without the removed links, and its new
+// sequence is recorded as its next version.
+func unlinkFormularNodes(ctx context.Context, client *db.PrismaClient, removed []db.FormularNodeModel) ([]db.PrismaTransaction, error) {
+	removedIDs := make([]string, 0, len(removed))
+	var formularIDs []string
+	for _, link := range removed {
+		removedIDs = append(removedIDs, link.ID)
+		if !slices.Contains(formularIDs, link.FormularID) {
+			formularIDs = append(formularIDs, link.FormularID)
+		}
+	}
+
+	var txs []db.PrismaTransaction
+	for _, formularID := range formularIDs {
+		links, err := client.FormularNode.FindMany(
+			db.FormularNode.FormularID.Equals(formularID),
+		).Exec(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		links = currentOrder(links, func(fn db.FormularNodeModel) (string, *string) {
+			return fn.ID, fn.InnerFormularNode.NextID
+		})
+		remaining := slices.DeleteFunc(links, func(fn db.FormularNodeModel) bool {
+			return slices.Contains(removedIDs, fn.ID)
+		})
+
+		// Clear every next pointer first so relinking never violates the unique constraint
+		txs = append(txs, client.FormularNode.FindMany(
+			db.FormularNode.FormularID.Equals(formularID),
+		).Update(
+			db.FormularNode.NextID.SetOptional(nil),
+		).Tx())
+
+		for i := 0; i < len(remaining)-1; i++ {
+			txs = append(txs, client.FormularNode.FindUnique(
+				db.FormularNode.ID.Equals(remaining[i].ID),
+			).Update(
+				db.FormularNode.NextID.Set(remaining[i+1].ID),
+			).Tx())
+		}
+
+		versions, err := versionTxs(ctx, client, formularID, nil, nodeIDsOf(formularLinks(remaining)))
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, versions...)
+	}
+
+	if len(removedIDs) > 0 {
+		txs = append(txs, client.FormularNode.FindMany(
+			db.FormularNode.ID.In(removedIDs),
+		).Delete().Tx())
+	}
+
+	return txs, nil
+}
+
+// unlinkCalculationFormulars returns the transactions that remove the given links from their calculations.
+// Every affected calculation is relinked in its current order without the removed links.
+func unlinkCalculationFormulars(ctx context.Context, client *db.PrismaClient, removed []db.CalculationFormularModel) ([]db.PrismaTransaction, error) {
+	removedIDs := make([]string, 0, len(removed))
+	var calculationIDs []string
+	for _, link := range removed {
+		removedIDs = append(removedIDs, link.ID)
+		if !slices.Contains(calculationIDs, link.CalculationID) {
+			calculationIDs = append(calculationIDs, link.CalculationID)
+		}
+	}
+
+	var txs []db.PrismaTransaction
+	for _, calculationID := range calculationIDs {
+		links, err := client.CalculationFormular.FindMany(
+			db.CalculationFormular.CalculationID.Equals(calculationID),
+		).Exec(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		links = currentOrder(links, func(cf db.CalculationFormularModel) (string, *string) {
+			return cf.ID, cf.InnerCalculationFormular.NextID
+		})
+		remaining := slices.DeleteFunc(links, func(cf db.CalculationFormularModel) bool {
+			return slices.Contains(removedIDs, cf.ID)
+		})
+
+		// Clear every next pointer first so relinking never violates the unique constraint
+		txs = append(txs, client.CalculationFormular.FindMany(
+			db.CalculationFormular.CalculationID.Equals(calculationID),
+		).Update(
+			db.CalculationFormular.NextID.SetOptional(nil),
+		).Tx())
+
+		for i := 0; i < len(remaining)-1; i++ {
+			txs = append(txs, client.CalculationFormular.FindUnique(
+				db.CalculationFormular.ID.Equals(remaining[i].ID),
+			).Update(
+				db.CalculationFormular.NextID.Set(remaining[i+1].ID),
+			).Tx())
+		}
+	}
+
+	if len(removedIDs) > 0 {
+		txs = append(txs, client.CalculationFormular.FindMany(
+			db.CalculationFormular.ID.In(removedIDs),
+		).Delete().Tx())
+	}
+
+	return txs, nil
+}
+
+// deleteFormulars returns the transactions that delete formulars that are no longer used by any
+// calculation, together with their node sequences and the nodes no other formular uses that the
+// principal of ctx owns
+func deleteFormulars(ctx context.Context, client *db.PrismaClient, formularIDs []string) ([]db.PrismaTransaction, error) {
+	if len(formularIDs) == 0 {
+		return nil, nil
+	}
+
+	links, err := client.FormularNode.FindMany(
+		db.FormularNode.FormularID.In(formularIDs),
+	).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	orphanIDs, err := orphanedNodes(ctx, client, links, formularIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	txs := []db.PrismaTransaction{
+		client.FormularNode.FindMany(
+			db.FormularNode.FormularID.In(formularIDs),
+		).Update(
+			db.FormularNode.NextID.SetOptional(nil),
+		).Tx(),
+		client.FormularNode.FindMany(
+			db.FormularNode.FormularID.In(formularIDs),
+		).Delete().Tx(),
+	}
+	txs = append(txs, deleteNodes(client, orphanIDs)...)
+	txs = append(txs,
+		client.FormularVersion.FindMany(
+			db.FormularVersion.FormularID.In(formularIDs),
+		).Delete().Tx(),
+		client.Formular.FindMany(
+			db.Formular.ID.In(formularIDs),
+		).Delete().Tx(),
+		revokeAll(client, ResourceFormular, formularIDs...),
+	)
+
+	return txs, nil
+}
+
+// orphanedNodes returns the nodes of the given links that no formular outside of formularIDs uses
+// and that the principal of ctx owns, which removing the links leaves unused. Nodes it does not own
+// are left unused.
+func orphanedNodes(ctx context.Context, client *db.PrismaClient, links []db.FormularNodeModel, formularIDs []string) ([]string, error) {
+	var nodeIDs []string
+	for _, link := range links {
+		if !slices.Contains(nodeIDs, link.NodeID) {
+			nodeIDs = append(nodeIDs, link.NodeID)
+		}
+	}
+	if len(nodeIDs) == 0 {
+		return nil, nil
+	}
+
+	used, err := client.FormularNode.FindMany(
+		db.FormularNode.NodeID.In(nodeIDs),
+		db.FormularNode.FormularID.NotIn(formularIDs),
+	).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return ownedIDs(ctx, client, ResourceNode, slices.DeleteFunc(nodeIDs, func(nodeID string) bool {
+		return slices.ContainsFunc(used, func(fn db.FormularNodeModel) bool {
+			return fn.NodeID == nodeID
+		})
+	}))
+}
+
+// deleteNodes returns the transactions that delete nodes no formular uses anymore and every
+// permission on them
+func deleteNodes(client *db.PrismaClient, nodeIDs []string) []db.PrismaTransaction {
+	if len(nodeIDs) == 0 {
+		return nil
+	}
+	return []db.PrismaTransaction{
+		client.Node.FindMany(
+			db.Node.ID.In(nodeIDs),
+		).Delete().Tx(),
+		revokeAll(client, ResourceNode, nodeIDs...),
+	}
+}
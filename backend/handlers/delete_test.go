@@ -0,0 +1,92 @@
+package handlers_test
+
+import (
+	"backend/handlers"
+	"backend/prisma/db"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// Alice owns a node that her formular and a formular of somebody else use
+const (
+	usage      = "40000000-0000-4000-8000-00000000000a"
+	otherUsage = "40000000-0000-4000-8000-00000000000b"
+)
+
+// expectNodeUsages expects the lookups of a permanent node delete up to its dependents
+func expectNodeUsages(client *db.PrismaClient, m *db.Mock) {
+	expectLookup(client, m, handlers.ResourceNode, node)
+	m.Node.Expect(client.Node.FindUnique(
+		db.Node.ID.Equals(node),
+	)).Returns(db.NodeModel{InnerNode: db.InnerNode{ID: node, Name: "Mine", WorkspaceID: workspaceA}})
+	m.FormularNode.Expect(client.FormularNode.FindMany(
+		db.FormularNode.NodeID.Equals(node),
+	)).ReturnsMany([]db.FormularNodeModel{
+		{InnerFormularNode: db.InnerFormularNode{ID: usage, FormularID: formular, NodeID: node}},
+		{InnerFormularNode: db.InnerFormularNode{ID: otherUsage, FormularID: otherForm, NodeID: node}},
+	})
+}
+
+// expectVersionLookup expects the lookups that record the next version of a formular the node is
+// removed from, which has no nodes left and no version yet
+func expectVersionLookup(client *db.PrismaClient, m *db.Mock, formularID string) {
+	m.Formular.Expect(client.Formular.FindUnique(
+		db.Formular.ID.Equals(formularID),
+	)).Returns(db.FormularModel{InnerFormular: db.InnerFormular{ID: formularID, Name: "Formular", WorkspaceID: workspaceA}})
+	m.FormularVersion.Expect(client.FormularVersion.FindFirst(
+		db.FormularVersion.FormularID.Equals(formularID),
+	).OrderBy(
+		db.FormularVersion.Version.Order(db.SortOrderDesc),
+	)).Errors(db.ErrNotFound)
+}
+
+func TestRestrictedDeleteHidesDependentsOfOthers(t *testing.T) {
+	client, m, ensure := db.NewMock()
+	expectNodeUsages(client, m)
+	expectRole(client, m, handlers.ResourceFormular, formular, handlers.RoleEditor)
+	expectRole(client, m, handlers.ResourceFormular, otherForm, "")
+
+	w := request(handlers.NewNodeHandler(client).Routes(), http.MethodDelete, "/"+node+"?permanent=true", "")
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusConflict, w.Body)
+	}
+	var body struct {
+		Details handlers.DependentsConflict `json:"details"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Details.Dependents) != 1 || body.Details.Dependents[0].ID != usage || body.Details.Hidden != 1 {
+		t.Fatalf("details = %+v, want only %s and 1 hidden", body.Details, usage)
+	}
+	ensure(t)
+}
+
+func TestDetachRequiresEditorOnEveryFormular(t *testing.T) {
+	client, m, ensure := db.NewMock()
+	expectNodeUsages(client, m)
+	m.FormularNode.Expect(client.FormularNode.FindMany(
+		db.FormularNode.FormularID.Equals(formular),
+	)).ReturnsMany([]db.FormularNodeModel{
+		{InnerFormularNode: db.InnerFormularNode{ID: usage, FormularID: formular, NodeID: node}},
+	})
+	m.FormularNode.Expect(client.FormularNode.FindMany(
+		db.FormularNode.FormularID.Equals(otherForm),
+	)).ReturnsMany([]db.FormularNodeModel{
+		{InnerFormularNode: db.InnerFormularNode{ID: otherUsage, FormularID: otherForm, NodeID: node}},
+	})
+	expectVersionLookup(client, m, formular)
+	expectVersionLookup(client, m, otherForm)
+	expectRole(client, m, handlers.ResourceFormular, formular, handlers.RoleEditor)
+	expectRole(client, m, handlers.ResourceFormular, otherForm, handlers.RoleViewer)
+
+	// Executing the delete would panic, as the mock does not support transactions
+	w := request(handlers.NewNodeHandler(client).Routes(), http.MethodDelete, "/"+node+"?permanent=true&mode=detach", "")
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusForbidden, w.Body)
+	}
+	ensure(t)
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"backend/prisma/db"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// Error codes returned in APIError.Code
+const (
+	CodeBadRequest       = "bad_request"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeConflict         = "conflict"
+	CodeBrokenSequence   = "broken_sequence"
+	CodeSequenceConflict = "sequence_conflict"
+	CodeHasDependents    = "has_dependents"
+	CodeInvalid          = "invalid"
+	CodeInternal         = "internal_error"
+	CodeUpstream         = "upstream_error"
+)
+
+// APIError is the body of every error response
+type APIError struct {
+	Code      string `json:"code" example:"not_found"`                      // A stable, machine-readable error code
+	Message   string `json:"message" example:"Calculation not found"`       // A human-readable description of the error
+	Details   any    `json:"details,omitempty" swaggertype:"object"`        // Optional structured information about the error
+	RequestID string `json:"requestId,omitempty" example:"host/abc-000001"` // The ID of the request, for correlating with server logs
+}
+
+// UniqueConflict lists the fields whose unique constraint was violated
+type UniqueConflict struct {
+	Fields []string `json:"fields" example:"calculationId,name"` // The fields of the violated unique constraint
+}
+
+// writeError writes an APIError with the given status
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, message string, details any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(APIError{
+		Code:      code,
+		Message:   message,
+		Details:   details,
+		RequestID: chimiddleware.GetReqID(r.Context()),
+	})
+}
+
+// writeInternalError records an unexpected error for the request log and writes a 500 without
+// exposing its text
+func writeInternalError(w http.ResponseWriter, r *http.Request, err error) {
+	RecordError(r.Context(), err)
+	writeError(w, r, http.StatusInternalServerError, CodeInternal, "Internal server error", nil)
+}
+
+// writeDBError maps a Prisma error to a 404 when no record was found, a 409 when a unique
+// constraint was violated and a 500 otherwise. resource names the record in the message.
+func writeDBError(w http.ResponseWriter, r *http.Request, err error, resource string) {
+	if errors.Is(err, db.ErrNotFound) {
+		writeError(w, r, http.StatusNotFound, CodeNotFound, resource+" not found", nil)
+		return
+	}
+
+	if info, ok := db.IsErrUniqueConstraint(err); ok {
+		fields := make([]string, 0, len(info.Fields))
+		for _, field := range info.Fields {
+			fields = append(fields, string(field))
+		}
+		writeError(w, r, http.StatusConflict, CodeConflict, resource+" already exists", UniqueConflict{Fields: fields})
+		return
+	}
+
+	writeInternalError(w, r, err)
+}
+
+// writeDecodeError writes a 400 for a request body that could not be decoded
+func writeDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	writeError(w, r, http.StatusBadRequest, CodeBadRequest, "Invalid request body: "+err.Error(), nil)
+}
+
+// txConflictMessages identify a failed transaction that depended on records another request
+// changed since they were read
+var txConflictMessages = []string{"Unique constraint failed", "UniqueConstraintViolation", "RecordNotFound", "required but not found"}
+
+// isTxConflict reports whether a transaction failed because a unique constraint was violated or a
+// record it updates no longer matched. The client only reports the message of a failed
+// transaction, so it is matched on its text.
+func isTxConflict(err error) bool {
+	if err == nil {
+		return false
+	}
+	if _, ok := db.IsErrUniqueConstraint(err); ok || errors.Is(err, db.ErrNotFound) {
+		return true
+	}
+	return slices.ContainsFunc(txConflictMessages, func(message string) bool {
+		return strings.Contains(err.Error(), message)
+	})
+}
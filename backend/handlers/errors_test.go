@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestIsTxConflict(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"none", nil, false},
+		{"unique", errors.New("pql error: Unique constraint failed on the fields: (`nextId`)"), true},
+		{"record to update", errors.New(`pql error: Error occurred during query execution: InterpretationError("Error for binding '3'", Some(QueryGraphBuilderError(RecordNotFound("Record to update not found."))))`), true},
+		{"other", errors.New("pql error: Foreign key constraint failed on the field: `formularId`"), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isTxConflict(test.err); got != test.want {
+				t.Fatalf("isTxConflict(%v) = %t, want %t", test.err, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+)
+
+// parseExpand reads the comma separated expand parameter. Each value must be a prefix of one
+// of the supported relation paths, and the result maps every path to the number of its
+// segments that were requested, so "formulars.formular" expands two levels of
+// "formulars.formular.nodes.node". Paths that were not requested are absent.
+func parseExpand(r *http.Request, paths ...string) (map[string]int, []FieldProblem) {
+	expand := make(map[string]int)
+	var problems []FieldProblem
+
+	for _, value := range strings.Split(r.URL.Query().Get("expand"), ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		matched := false
+		for _, path := range paths {
+			if path == value || strings.HasPrefix(path, value+".") {
+				expand[path] = max(expand[path], strings.Count(value, ".")+1)
+				matched = true
+			}
+		}
+		if !matched {
+			problems = append(problems, FieldProblem{Field: "expand", Message: "unknown relation " + value + ", expected a prefix of " + strings.Join(paths, ", ")})
+		}
+	}
+
+	return expand, problems
+}
@@ -2,9 +2,13 @@
 package handlers
 
 import (
+	"backend/engine"
 	"backend/prisma/db"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"slices"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -28,6 +32,7 @@ func (h *FormularHandler) Routes() chi.Router {
 	r.Get("/{id}", h.Get)
 	r.Put("/{id}", h.Update)
 	r.Delete("/{id}", h.Delete)
+	r.Post("/{id}/restore", h.Restore)
 
 	// Node relationship endpoints
 	r.Post("/{id}/nodes", h.AddNode)
@@ -35,54 +40,154 @@ func (h *FormularHandler) Routes() chi.Router {
 	r.Get("/{id}/nodes", h.ListNodes)
 	r.Put("/{id}/nodes/reorder", h.ReorderNodes)
 
+	// Link endpoints address a single occurrence of a node in the sequence
+	r.Delete("/{id}/links/{linkId}", h.RemoveLink)
+	r.Put("/{id}/links/reorder", h.ReorderLinks)
+	r.Get("/{id}/integrity", h.Integrity)
+	r.Post("/{id}/compile", h.Compile)
+	r.Get("/{id}/affected-calculations", h.AffectedCalculations)
+
+	// Version history endpoints
+	r.Get("/{id}/versions", h.ListVersions)
+	r.Get("/{id}/versions/diff", h.DiffVersions)
+	r.Get("/{id}/versions/{version}", h.GetVersion)
+	r.Post("/{id}/versions/{version}/restore", h.RestoreVersion)
+
+	// Sharing endpoints
+	r.Get("/{id}/permissions", h.ListPermissions)
+	r.Put("/{id}/permissions/{principal}", h.GrantPermission)
+	r.Delete("/{id}/permissions/{principal}", h.RevokePermission)
+
 	return r
 }
 
 // List godoc
 // @Summary List formulars
-// @Description Get all formulars
+// @Description Get a page of formulars, optionally filtered by name and timestamps. Pass nextCursor as cursor to fetch the following page.
 // @Tags formulars
 // @Accept json
 // @Produce json
-// @Success 200 {array} db.FormularModel
+// @Param limit query int false "Maximum number of items to return" minimum(1) maximum(200) default(50)
+// @Param cursor query string false "Cursor returned as nextCursor by the previous page"
+// @Param name query string false "Only return formulars whose name contains this text"
+// @Param createdAfter query string false "Only return formulars created at or after this RFC 3339 timestamp"
+// @Param createdBefore query string false "Only return formulars created before this RFC 3339 timestamp"
+// @Param updatedAfter query string false "Only return formulars updated at or after this RFC 3339 timestamp"
+// @Param updatedBefore query string false "Only return formulars updated before this RFC 3339 timestamp"
+// @Param sort query string false "Sort field, prefixed with - for descending order" Enums(name, -name, createdAt, -createdAt, updatedAt, -updatedAt) default(createdAt)
+// @Param includeDeleted query bool false "Include soft-deleted formulars" default(false)
+// @Success 200 {object} Page[db.FormularModel]
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 422 {object} APIError{details=ValidationError} "Invalid query parameters"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /formulars [get]
 func (h *FormularHandler) List(w http.ResponseWriter, r *http.Request) {
-	formulars, err := h.db.Formular.FindMany().Exec(r.Context())
+	query, problems := parseListQuery(r)
+	if len(problems) > 0 {
+		writeValidationError(w, r, problems)
+		return
+	}
+
+	// Only list the formulars the caller has a role on
+	ids, total, err := listPage(r, h.db, "Formular", ResourceFormular, query)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeInternalError(w, r, err)
 		return
 	}
 
-	json.NewEncoder(w).Encode(formulars)
+	id := func(m db.FormularModel) string {
+		return m.ID
+	}
+	formulars, err := findInOrder(ids, func(ids []string) ([]db.FormularModel, error) {
+		return h.db.Formular.FindMany(db.Formular.ID.In(ids)).Exec(r.Context())
+	}, id)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(newPage(formulars, total, query.limit, id))
 }
 
 // Get godoc
 // @Summary Get a formular
-// @Description Get formular by ID
+// @Description Get formular by ID, optionally with its nodes in sequence order
 // @Tags formulars
 // @Accept json
 // @Produce json
 // @Param id path string true "Formular ID"
+// @Param includeDeleted query bool false "Return the formular even if it is soft-deleted" default(false)
+// @Param expand query string false "Comma separated relations to include, any prefix of nodes.node"
 // @Success 200 {object} db.FormularModel
-// @Failure 404 {string} string "Formular not found"
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 404 {object} APIError "Formular not found"
+// @Failure 422 {object} APIError{details=ValidationError} "Invalid query parameters"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /formulars/{id} [get]
 func (h *FormularHandler) Get(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
+
+	expand, problems := parseExpand(r, "nodes.node")
+	includeDeleted, flagProblems := queryFlag(r, "includeDeleted")
+	if problems = append(problems, flagProblems...); len(problems) > 0 {
+		writeValidationError(w, r, problems)
+		return
+	}
+
+	if !authorize(w, r, h.db, ResourceFormular, id, RoleViewer) {
+		return
+	}
+
+	var with []db.FormularRelationWith
+	if depth := expand["nodes.node"]; depth > 0 {
+		with = append(with, formularNodesWith(depth))
+	}
+
 	formular, err := h.db.Formular.FindUnique(
 		db.Formular.ID.Equals(id),
+	).With(
+		with...,
 	).Exec(r.Context())
 
 	if err != nil {
-		http.Error(w, "Formular not found", http.StatusNotFound)
+		writeDBError(w, r, err, "Formular")
+		return
+	}
+
+	if _, deleted := formular.DeletedAt(); deleted && !includeDeleted {
+		writeError(w, r, http.StatusNotFound, CodeNotFound, "Formular not found", nil)
 		return
 	}
 
+	orderFormularNodes(formular)
+
 	json.NewEncoder(w).Encode(formular)
 }
 
+// formularNodesWith fetches the node sequence of a formular, and the nodes themselves when depth is 2
+func formularNodesWith(depth int) db.FormularRelationWith {
+	nodes := db.Formular.Nodes.Fetch()
+	if depth > 1 {
+		nodes = nodes.With(db.FormularNode.Node.Fetch())
+	}
+	return nodes
+}
+
+// orderFormularNodes puts the fetched node sequence of a formular in sequence order
+func orderFormularNodes(formular *db.FormularModel) {
+	if formular.RelationsFormular.Nodes == nil {
+		return
+	}
+	formular.RelationsFormular.Nodes = currentOrder(formular.RelationsFormular.Nodes, func(fn db.FormularNodeModel) (string, *string) {
+		return fn.ID, fn.InnerFormularNode.NextID
+	})
+}
+
 // CreateFormularInput represents the input for creating a formular
 type CreateFormularInput struct {
-	Name string `json:"name" example:"My Formular"` // The name of the formular
+	Name string `json:"name" validate:"required,max=255" example:"My Formular"` // The name of the formular
 }
 
 // Create godoc
@@ -93,30 +198,59 @@ type CreateFormularInput struct {
 // @Produce json
 // @Param formular body CreateFormularInput true "Formular to create"
 // @Success 201 {object} db.FormularModel
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 422 {object} APIError{details=ValidationError} "Invalid input"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /formulars [post]
 func (h *FormularHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var input CreateFormularInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeInput(w, r, &input) {
 		return
 	}
 
-	formular, err := h.db.Formular.CreateOne(
-		db.Formular.Name.Set(input.Name),
-	).Exec(r.Context())
+	now := time.Now()
+	formular := db.FormularModel{InnerFormular: db.InnerFormular{
+		ID:          newID(),
+		Name:        input.Name,
+		WorkspaceID: workspaceID(r),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}}
 
+	create := h.db.Formular.CreateOne(
+		db.Formular.Name.Set(formular.Name),
+		db.Formular.Workspace.Link(db.Workspace.ID.Equals(formular.WorkspaceID)),
+		db.Formular.ID.Set(formular.ID),
+		db.Formular.CreatedAt.Set(now),
+		db.Formular.UpdatedAt.Set(now),
+	).Tx()
+
+	auditTx, err := audit(h.db, r, ActionCreate, ResourceFormular, formular.ID, nil, formular)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	versions, err := versionTxs(r.Context(), h.db, formular.ID, &formular.Name, nil)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeInternalError(w, r, err)
+		return
+	}
+
+	txs := append([]db.PrismaTransaction{create, grantOwner(h.db, r, ResourceFormular, formular.ID), auditTx}, versions...)
+	if err := h.db.Prisma.Transaction(txs...).Exec(r.Context()); err != nil {
+		writeInternalError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(formular)
+	json.NewEncoder(w).Encode(create.Result())
 }
 
 // UpdateFormularInput represents the input for updating a formular
 type UpdateFormularInput struct {
-	Name *string `json:"name,omitempty" example:"Updated Formular"` // The new name of the formular
+	Name *string `json:"name,omitempty" validate:"notblank,min=1,max=255" example:"Updated Formular"` // The new name of the formular
 }
 
 // Update godoc
@@ -128,108 +262,404 @@ type UpdateFormularInput struct {
 // @Param id path string true "Formular ID"
 // @Param formular body UpdateFormularInput true "Formular updates"
 // @Success 200 {object} db.FormularModel
-// @Failure 404 {string} string "Formular not found"
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 403 {object} APIError "Insufficient role"
+// @Failure 404 {object} APIError "Formular not found"
+// @Failure 422 {object} APIError{details=ValidationError} "Invalid input"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /formulars/{id} [put]
 func (h *FormularHandler) Update(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
 	var input UpdateFormularInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeInput(w, r, &input) {
+		return
+	}
+
+	if !authorize(w, r, h.db, ResourceFormular, id, RoleEditor) {
+		return
+	}
+
+	// Soft-deleted records must be restored before they can be changed
+	before, err := h.db.Formular.FindFirst(
+		db.Formular.ID.Equals(id),
+		db.Formular.DeletedAt.IsNull(),
+	).Exec(r.Context())
+
+	if err != nil {
+		writeDBError(w, r, err, "Formular")
 		return
 	}
 
-	params := []db.FormularSetParam{}
+	after := *before
+	after.UpdatedAt = time.Now()
+	params := []db.FormularSetParam{db.Formular.UpdatedAt.Set(after.UpdatedAt)}
 
 	if input.Name != nil {
+		after.Name = *input.Name
 		params = append(params, db.Formular.Name.Set(*input.Name))
 	}
 
-	formular, err := h.db.Formular.FindUnique(
+	update := h.db.Formular.FindUnique(
 		db.Formular.ID.Equals(id),
 	).Update(
 		params...,
+	).Tx()
+
+	auditTx, err := audit(h.db, r, ActionUpdate, ResourceFormular, id, before, after)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	formularNodes, err := h.db.FormularNode.FindMany(
+		db.FormularNode.FormularID.Equals(id),
 	).Exec(r.Context())
 
 	if err != nil {
-		http.Error(w, "Formular not found", http.StatusNotFound)
+		writeInternalError(w, r, err)
 		return
 	}
 
-	json.NewEncoder(w).Encode(formular)
+	formularNodes = currentOrder(formularNodes, func(fn db.FormularNodeModel) (string, *string) {
+		return fn.ID, fn.InnerFormularNode.NextID
+	})
+	versions, err := versionTxs(r.Context(), h.db, id, &after.Name, nodeIDsOf(formularLinks(formularNodes)))
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	txs := append([]db.PrismaTransaction{update, auditTx}, versions...)
+	if err := h.db.Prisma.Transaction(txs...).Exec(r.Context()); err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(update.Result())
 }
 
 // Delete godoc
 // @Summary Delete a formular
-// @Description Delete a formular by ID
+// @Description Soft delete a formular by ID so it can be restored. Soft-deleted formulars stay in the calculations that use them until they are purged, and those calculations fail to evaluate with a 409 until the formular is restored or removed. With permanent=true the formular is deleted immediately: in restrict mode a formular that has nodes or is used by a calculation is not deleted. Detach removes it from every calculation, relinking the surrounding formulars, which requires the editor role on each of those calculations, and deletes its node sequence; cascade also deletes the nodes no other formular uses that the caller owns. A restricted delete only lists the dependents in calculations the caller has a role on and counts the others.
 // @Tags formulars
 // @Accept json
 // @Produce json
 // @Param id path string true "Formular ID"
+// @Param permanent query bool false "Delete the formular immediately instead of soft deleting it" default(false)
+// @Param mode query string false "How a permanent delete handles related records, only with permanent=true" Enums(restrict, detach, cascade) default(restrict)
 // @Success 204 "No Content"
-// @Failure 404 {string} string "Formular not found"
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 403 {object} APIError "Insufficient role on the formular or a calculation using it"
+// @Failure 404 {object} APIError "Formular not found"
+// @Failure 409 {object} APIError{details=DependentsConflict} "Formular has nodes or is used by calculations"
+// @Failure 422 {object} APIError{details=ValidationError} "Invalid query parameters"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /formulars/{id} [delete]
 func (h *FormularHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
-	_, err := h.db.Formular.FindUnique(
+	permanent, mode, problems := parseDeleteQuery(r)
+	if len(problems) > 0 {
+		writeValidationError(w, r, problems)
+		return
+	}
+
+	if !authorize(w, r, h.db, ResourceFormular, id, RoleOwner) {
+		return
+	}
+
+	formular, err := h.db.Formular.FindUnique(
 		db.Formular.ID.Equals(id),
-	).Delete().Exec(r.Context())
+	).Exec(r.Context())
+
+	if err != nil {
+		writeDBError(w, r, err, "Formular")
+		return
+	}
+
+	if !permanent {
+		if _, deleted := formular.DeletedAt(); !deleted {
+			now := time.Now()
+			after := *formular
+			after.UpdatedAt = now
+			after.InnerFormular.DeletedAt = &now
+
+			auditTx, err := audit(h.db, r, ActionDelete, ResourceFormular, id, formular, after)
+			if err != nil {
+				writeInternalError(w, r, err)
+				return
+			}
+
+			err = h.db.Prisma.Transaction(
+				h.db.Formular.FindUnique(
+					db.Formular.ID.Equals(id),
+				).Update(
+					db.Formular.DeletedAt.Set(now),
+					db.Formular.UpdatedAt.Set(now),
+				).Tx(),
+				auditTx,
+			).Exec(r.Context())
+
+			if err != nil {
+				writeInternalError(w, r, err)
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	txs, dependents, err := formularDeletion(r.Context(), h.db, id, mode)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	if !permitDeletion(w, r, h.db, ResourceFormular, id, mode, dependents) {
+		return
+	}
 
+	auditTx, err := audit(h.db, r, ActionDelete, ResourceFormular, id, formular, nil)
 	if err != nil {
-		http.Error(w, "Formular not found", http.StatusNotFound)
+		writeInternalError(w, r, err)
+		return
+	}
+	txs = append(txs, auditTx)
+	if err := h.db.Prisma.Transaction(txs...).Exec(r.Context()); err != nil {
+		writeInternalError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// Restore godoc
+// @Summary Restore a formular
+// @Description Restore a soft-deleted formular by ID. Restoring a formular that is not deleted has no effect.
+// @Tags formulars
+// @Accept json
+// @Produce json
+// @Param id path string true "Formular ID"
+// @Success 200 {object} db.FormularModel
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 403 {object} APIError "Insufficient role"
+// @Failure 404 {object} APIError "Formular not found"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /formulars/{id}/restore [post]
+func (h *FormularHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if !authorize(w, r, h.db, ResourceFormular, id, RoleOwner) {
+		return
+	}
+
+	formular, err := h.db.Formular.FindUnique(
+		db.Formular.ID.Equals(id),
+	).Exec(r.Context())
+
+	if err != nil {
+		writeDBError(w, r, err, "Formular")
+		return
+	}
+
+	if _, deleted := formular.DeletedAt(); !deleted {
+		json.NewEncoder(w).Encode(formular)
+		return
+	}
+
+	after := *formular
+	after.UpdatedAt = time.Now()
+	after.InnerFormular.DeletedAt = nil
+
+	restore := h.db.Formular.FindUnique(
+		db.Formular.ID.Equals(id),
+	).Update(
+		db.Formular.DeletedAt.SetOptional(nil),
+		db.Formular.UpdatedAt.Set(after.UpdatedAt),
+	).Tx()
+
+	auditTx, err := audit(h.db, r, ActionRestore, ResourceFormular, id, formular, after)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	if err := h.db.Prisma.Transaction(
+		restore,
+		auditTx,
+	).Exec(r.Context()); err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(restore.Result())
+}
+
 // AddNodeInput represents the input for adding a node to a formular
 type AddNodeInput struct {
-	NodeID string  `json:"nodeId" example:"123e4567-e89b-12d3-a456-426614174000"`           // The ID of the node to add
-	NextID *string `json:"nextId,omitempty" example:"123e4567-e89b-12d3-a456-426614174001"` // Optional ID of the next node in sequence
+	NodeID  string  `json:"nodeId" validate:"required,uuid" format:"uuid" example:"123e4567-e89b-12d3-a456-426614174000"`   // The ID of the node to add
+	NextID  *string `json:"nextId,omitempty" validate:"uuid" format:"uuid" example:"123e4567-e89b-12d3-a456-426614174001"`  // Optional ID of the formular node to insert before
+	AfterID *string `json:"afterId,omitempty" validate:"uuid" format:"uuid" example:"123e4567-e89b-12d3-a456-426614174002"` // Optional ID of the formular node to insert after
 }
 
 // AddNode godoc
 // @Summary Add a node to a formular
-// @Description Add a node to a formular's sequence
+// @Description Insert a node into a formular's sequence before nextId, after afterId, or at the end when neither is given
 // @Tags formulars
 // @Accept json
 // @Produce json
 // @Param id path string true "Formular ID"
 // @Param node body AddNodeInput true "Node to add"
 // @Success 201 {object} db.FormularNodeModel
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 403 {object} APIError "Insufficient role"
+// @Failure 404 {object} APIError "Formular or node not found"
+// @Failure 409 {object} APIError "Broken node sequence, or the sequence changed while the node was added"
+// @Failure 422 {object} APIError{details=ValidationError} "Invalid input or insert position"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /formulars/{id}/nodes [post]
 func (h *FormularHandler) AddNode(w http.ResponseWriter, r *http.Request) {
 	formularID := chi.URLParam(r, "id")
 
 	var input AddNodeInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeInput(w, r, &input) {
 		return
 	}
 
-	formularNode, err := h.db.FormularNode.CreateOne(
-		db.FormularNode.Formular.Link(db.Formular.ID.Equals(formularID)),
-		db.FormularNode.Node.Link(db.Node.ID.Equals(input.NodeID)),
+	// The node is only referenced, so seeing it is enough
+	if !authorize(w, r, h.db, ResourceFormular, formularID, RoleEditor) ||
+		!authorize(w, r, h.db, ResourceNode, input.NodeID, RoleViewer) {
+		return
+	}
+
+	_, err := h.db.Node.FindFirst(
+		db.Node.ID.Equals(input.NodeID),
+		db.Node.DeletedAt.IsNull(),
 	).Exec(r.Context())
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeDBError(w, r, err, "Node")
+		return
+	}
+
+	formularNodes, err := h.db.FormularNode.FindMany(
+		db.FormularNode.FormularID.Equals(formularID),
+	).Exec(r.Context())
+
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	formularNodes, err = orderChain(formularNodes, func(fn db.FormularNodeModel) (string, *string) {
+		return fn.ID, fn.InnerFormularNode.NextID
+	})
+	if err != nil {
+		writeError(w, r, http.StatusConflict, CodeBrokenSequence, err.Error(), nil)
 		return
 	}
 
-	if input.NextID != nil {
-		_, err = h.db.FormularNode.FindUnique(
-			db.FormularNode.ID.Equals(formularNode.ID),
+	prev, next, err := insertPosition(formularNodes, func(fn db.FormularNodeModel) string {
+		return fn.ID
+	}, input.NextID, input.AfterID)
+	if err != nil {
+		writeError(w, r, http.StatusUnprocessableEntity, CodeInvalid, err.Error(), nil)
+		return
+	}
+
+	formularNodeID := newID()
+	params := []db.FormularNodeSetParam{db.FormularNode.ID.Set(formularNodeID)}
+	if next != nil {
+		params = append(params, db.FormularNode.Next.Link(db.FormularNode.ID.Equals(next.ID)))
+	}
+
+	// The sequence was read outside of the transaction, so every pointer change is conditional on
+	// the state that was read. Detach the predecessor first so the new row can take over its next
+	// pointer; the new row's unique next pointer fails when another row took the successor since.
+	var txs []db.PrismaTransaction
+	if prev != nil && next != nil {
+		txs = append(txs, h.db.FormularNode.FindMany(
+			db.FormularNode.ID.Equals(prev.ID),
+			db.FormularNode.NextID.Equals(next.ID),
 		).Update(
-			db.FormularNode.NextID.Set(*input.NextID),
-		).Exec(r.Context())
+			db.FormularNode.NextID.SetOptional(nil),
+		).Tx())
+	}
 
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+	txs = append(txs, h.db.FormularNode.CreateOne(
+		db.FormularNode.Formular.Link(db.Formular.ID.Equals(formularID)),
+		db.FormularNode.Node.Link(db.Node.ID.Equals(input.NodeID)),
+		params...,
+	).Tx())
+
+	if prev != nil {
+		// Link the predecessor only if nothing was inserted after it since, and fail the
+		// transaction when it was not linked
+		txs = append(txs, h.db.FormularNode.FindMany(
+			db.FormularNode.ID.Equals(prev.ID),
+			db.FormularNode.NextID.IsNull(),
+		).Update(
+			db.FormularNode.NextID.Set(formularNodeID),
+		).Tx(), h.db.FormularNode.FindUnique(
+			db.FormularNode.NextID.Equals(formularNodeID),
+		).Update(
+			db.FormularNode.UpdatedAt.Set(time.Now()),
+		).Tx())
+	} else if next == nil {
+		// A member another request added to the empty sequence since goes first, so the sequence
+		// stays a single chain
+		txs = append(txs, h.db.FormularNode.FindMany(
+			db.FormularNode.FormularID.Equals(formularID),
+			db.FormularNode.ID.Not(formularNodeID),
+			db.FormularNode.NextID.IsNull(),
+		).Update(
+			db.FormularNode.NextID.Set(formularNodeID),
+		).Tx())
+	}
+
+	position := len(formularNodes)
+	if next != nil {
+		position = slices.IndexFunc(formularNodes, func(link db.FormularNodeModel) bool {
+			return link.ID == next.ID
+		})
+	}
+	before := formularLinks(formularNodes)
+	after := slices.Insert(slices.Clone(before), position, auditLink{ID: formularNodeID, NodeID: input.NodeID})
+	auditTx, err := audit(h.db, r, ActionAddNode, ResourceFormular, formularID, before, after)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	txs = append(txs, auditTx)
+
+	versions, err := versionTxs(r.Context(), h.db, formularID, nil, nodeIDsOf(after))
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	txs = append(txs, versions...)
+
+	if err := h.db.Prisma.Transaction(txs...).Exec(r.Context()); err != nil {
+		if isTxConflict(err) {
+			writeError(w, r, http.StatusConflict, CodeSequenceConflict, "The formular changed while the node was added, retry the request", nil)
 			return
 		}
+		writeInternalError(w, r, err)
+		return
+	}
+
+	formularNode, err := h.db.FormularNode.FindUnique(
+		db.FormularNode.ID.Equals(formularNodeID),
+	).Exec(r.Context())
+
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
@@ -238,145 +668,642 @@ func (h *FormularHandler) AddNode(w http.ResponseWriter, r *http.Request) {
 
 // RemoveNode godoc
 // @Summary Remove a node from a formular
-// @Description Remove a node from a formular's sequence
+// @Description Remove the first occurrence of a node from a formular's sequence and link its predecessor to its successor. Use the link endpoint to remove a specific occurrence.
 // @Tags formulars
 // @Accept json
 // @Produce json
 // @Param id path string true "Formular ID"
 // @Param nodeId path string true "Node ID"
 // @Success 204 "No Content"
-// @Failure 404 {string} string "FormularNode not found"
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 403 {object} APIError "Insufficient role"
+// @Failure 404 {object} APIError "Formular or FormularNode not found"
+// @Deprecated
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /formulars/{id}/nodes/{nodeId} [delete]
 func (h *FormularHandler) RemoveNode(w http.ResponseWriter, r *http.Request) {
 	formularID := chi.URLParam(r, "id")
 	nodeID := chi.URLParam(r, "nodeId")
 
-	// Find the formular node first
+	if !authorize(w, r, h.db, ResourceFormular, formularID, RoleEditor) {
+		return
+	}
+
+	formularNodes, err := h.db.FormularNode.FindMany(
+		db.FormularNode.FormularID.Equals(formularID),
+	).Exec(r.Context())
+
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	formularNodes = currentOrder(formularNodes, func(fn db.FormularNodeModel) (string, *string) {
+		return fn.ID, fn.InnerFormularNode.NextID
+	})
+
+	// Remove the occurrence closest to the head
+	for _, formularNode := range formularNodes {
+		if formularNode.NodeID == nodeID {
+			h.unlinkFormularNode(w, r, &formularNode)
+			return
+		}
+	}
+
+	writeError(w, r, http.StatusNotFound, CodeNotFound, "FormularNode not found", nil)
+}
+
+// RemoveLink godoc
+// @Summary Remove a formular node from a formular
+// @Description Remove a single occurrence of a node from a formular's sequence by its link ID and link its predecessor to its successor
+// @Tags formulars
+// @Accept json
+// @Produce json
+// @Param id path string true "Formular ID"
+// @Param linkId path string true "FormularNode ID"
+// @Success 204 "No Content"
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 403 {object} APIError "Insufficient role"
+// @Failure 404 {object} APIError "Formular or FormularNode not found"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /formulars/{id}/links/{linkId} [delete]
+func (h *FormularHandler) RemoveLink(w http.ResponseWriter, r *http.Request) {
+	formularID := chi.URLParam(r, "id")
+	linkID := chi.URLParam(r, "linkId")
+
+	if !authorize(w, r, h.db, ResourceFormular, formularID, RoleEditor) {
+		return
+	}
+
 	formularNode, err := h.db.FormularNode.FindFirst(
+		db.FormularNode.ID.Equals(linkID),
 		db.FormularNode.FormularID.Equals(formularID),
-		db.FormularNode.NodeID.Equals(nodeID),
 	).Exec(r.Context())
 
 	if err != nil {
-		http.Error(w, "FormularNode not found", http.StatusNotFound)
+		writeDBError(w, r, err, "FormularNode")
+		return
+	}
+
+	h.unlinkFormularNode(w, r, formularNode)
+}
+
+// unlinkFormularNode deletes a formular node and relinks its predecessor to its successor in one transaction
+func (h *FormularHandler) unlinkFormularNode(w http.ResponseWriter, r *http.Request, formularNode *db.FormularNodeModel) {
+	prev, err := h.db.FormularNode.FindFirst(
+		db.FormularNode.NextID.Equals(formularNode.ID),
+	).Exec(r.Context())
+
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		writeInternalError(w, r, err)
 		return
 	}
 
-	// Then delete it
-	_, err = h.db.FormularNode.FindUnique(
+	var txs []db.PrismaTransaction
+	if prev != nil {
+		txs = append(txs, h.db.FormularNode.FindUnique(
+			db.FormularNode.ID.Equals(prev.ID),
+		).Update(
+			db.FormularNode.NextID.SetOptional(nil),
+		).Tx())
+	}
+
+	txs = append(txs, h.db.FormularNode.FindUnique(
 		db.FormularNode.ID.Equals(formularNode.ID),
-	).Delete().Exec(r.Context())
+	).Delete().Tx())
+
+	if next, ok := formularNode.NextID(); ok && prev != nil {
+		txs = append(txs, h.db.FormularNode.FindUnique(
+			db.FormularNode.ID.Equals(prev.ID),
+		).Update(
+			db.FormularNode.NextID.Set(next),
+		).Tx())
+	}
+
+	siblings, err := h.db.FormularNode.FindMany(
+		db.FormularNode.FormularID.Equals(formularNode.FormularID),
+	).Exec(r.Context())
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeInternalError(w, r, err)
+		return
+	}
+
+	siblings = currentOrder(siblings, func(link db.FormularNodeModel) (string, *string) {
+		return link.ID, link.InnerFormularNode.NextID
+	})
+	remaining := slices.DeleteFunc(slices.Clone(siblings), func(link db.FormularNodeModel) bool {
+		return link.ID == formularNode.ID
+	})
+	auditTx, err := audit(h.db, r, ActionRemoveNode, ResourceFormular, formularNode.FormularID, formularLinks(siblings), formularLinks(remaining))
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	txs = append(txs, auditTx)
+
+	versions, err := versionTxs(r.Context(), h.db, formularNode.FormularID, nil, nodeIDsOf(formularLinks(remaining)))
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	txs = append(txs, versions...)
+
+	if err := h.db.Prisma.Transaction(txs...).Exec(r.Context()); err != nil {
+		writeInternalError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// OrderedFormularNode is a formular node with its position in the formular's sequence
+type OrderedFormularNode struct {
+	db.FormularNodeModel
+	Position int `json:"position" example:"0"` // The zero-based position in the sequence
+}
+
 // ListNodes godoc
 // @Summary List nodes in a formular
-// @Description Get all nodes in a formular's sequence
+// @Description Get all nodes in a formular's sequence, ordered by walking the sequence from its head
 // @Tags formulars
 // @Accept json
 // @Produce json
 // @Param id path string true "Formular ID"
-// @Success 200 {array} db.FormularNodeModel
+// @Success 200 {array} OrderedFormularNode
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 404 {object} APIError "Formular not found"
+// @Failure 409 {object} APIError{details=IntegrityReport} "Broken node sequence"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /formulars/{id}/nodes [get]
 func (h *FormularHandler) ListNodes(w http.ResponseWriter, r *http.Request) {
 	formularID := chi.URLParam(r, "id")
 
-	nodes, err := h.db.FormularNode.FindMany(
+	if !authorize(w, r, h.db, ResourceFormular, formularID, RoleViewer) {
+		return
+	}
+
+	formularNodes, err := h.db.FormularNode.FindMany(
 		db.FormularNode.FormularID.Equals(formularID),
 	).With(
 		db.FormularNode.Node.Fetch(),
 	).Exec(r.Context())
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeInternalError(w, r, err)
 		return
 	}
 
-	json.NewEncoder(w).Encode(nodes)
+	link := func(fn db.FormularNodeModel) (string, *string) {
+		return fn.ID, fn.InnerFormularNode.NextID
+	}
+
+	ordered, err := orderChain(formularNodes, link)
+	if err != nil {
+		writeError(w, r, http.StatusConflict, CodeBrokenSequence, "Broken node sequence", checkChain(formularNodes, link))
+		return
+	}
+
+	response := make([]OrderedFormularNode, 0, len(ordered))
+	for i, formularNode := range ordered {
+		response = append(response, OrderedFormularNode{
+			FormularNodeModel: formularNode,
+			Position:          i,
+		})
+	}
+
+	json.NewEncoder(w).Encode(response)
 }
 
 // ReorderNodesInput represents the input for reordering nodes in a formular
 type ReorderNodesInput struct {
-	NodeOrder []string `json:"nodeOrder" example:"['123e4567-e89b-12d3-a456-426614174000', '123e4567-e89b-12d3-a456-426614174001']"` // The ordered list of node IDs
+	NodeOrder []string `json:"nodeOrder" validate:"dive,uuid" example:"['123e4567-e89b-12d3-a456-426614174000', '123e4567-e89b-12d3-a456-426614174001']"` // The ordered list of node IDs
 }
 
 // ReorderNodes godoc
 // @Summary Reorder nodes in a formular
-// @Description Update the sequence of nodes in a formular
+// @Description Atomically update the sequence of nodes in a formular. The order must contain exactly the current nodes of the formular; repeated nodes keep their relative order. Use the link endpoint to reorder specific occurrences.
 // @Tags formulars
 // @Accept json
 // @Produce json
 // @Param id path string true "Formular ID"
 // @Param order body ReorderNodesInput true "New node order"
 // @Success 200 "OK"
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 403 {object} APIError "Insufficient role"
+// @Failure 404 {object} APIError "Formular not found"
+// @Failure 409 {object} APIError{details=SequenceConflict} "Order does not match the current nodes"
+// @Failure 422 {object} APIError{details=ValidationError} "Invalid input"
+// @Deprecated
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /formulars/{id}/nodes/reorder [put]
 func (h *FormularHandler) ReorderNodes(w http.ResponseWriter, r *http.Request) {
+	var input ReorderNodesInput
+	if !decodeInput(w, r, &input) {
+		return
+	}
+
+	h.reorder(w, r, func(fn db.FormularNodeModel) string {
+		return fn.NodeID
+	}, input.NodeOrder)
+}
+
+// ReorderLinks godoc
+// @Summary Reorder the links of a formular
+// @Description Atomically update the sequence of a formular by formular node IDs. The order must contain exactly the current links of the formular.
+// @Tags formulars
+// @Accept json
+// @Produce json
+// @Param id path string true "Formular ID"
+// @Param order body ReorderLinksInput true "New link order"
+// @Success 200 "OK"
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 403 {object} APIError "Insufficient role"
+// @Failure 404 {object} APIError "Formular not found"
+// @Failure 409 {object} APIError{details=SequenceConflict} "Order does not match the current links"
+// @Failure 422 {object} APIError{details=ValidationError} "Invalid input"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /formulars/{id}/links/reorder [put]
+func (h *FormularHandler) ReorderLinks(w http.ResponseWriter, r *http.Request) {
+	var input ReorderLinksInput
+	if !decodeInput(w, r, &input) {
+		return
+	}
+
+	h.reorder(w, r, func(fn db.FormularNodeModel) string {
+		return fn.ID
+	}, input.LinkOrder)
+}
+
+// reorder relinks the sequence of the formular in the URL so that its members follow order,
+// where member returns the ID a formular node is addressed by
+func (h *FormularHandler) reorder(w http.ResponseWriter, r *http.Request, member func(db.FormularNodeModel) string, order []string) {
 	formularID := chi.URLParam(r, "id")
 
-	var input ReorderNodesInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !authorize(w, r, h.db, ResourceFormular, formularID, RoleEditor) {
+		return
+	}
+
+	formularNodes, err := h.db.FormularNode.FindMany(
+		db.FormularNode.FormularID.Equals(formularID),
+	).Exec(r.Context())
+
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	formularNodes = currentOrder(formularNodes, func(fn db.FormularNodeModel) (string, *string) {
+		return fn.ID, fn.InnerFormularNode.NextID
+	})
+
+	ordered, ok := matchOrder(formularNodes, member, order)
+
+	if !ok {
+		current := make([]string, 0, len(formularNodes))
+		for _, formularNode := range formularNodes {
+			current = append(current, member(formularNode))
+		}
+
+		writeError(w, r, http.StatusConflict, CodeSequenceConflict, "Order must contain exactly the current members of the formular", SequenceConflict{
+			CurrentOrder: current,
+		})
 		return
 	}
 
-	// Update the next pointers for each node in the order
-	for i := 0; i < len(input.NodeOrder)-1; i++ {
-		currentNodeID := input.NodeOrder[i]
-		nextNodeID := input.NodeOrder[i+1]
+	if len(ordered) == 0 {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
 
-		// Find the formular node first
-		formularNode, err := h.db.FormularNode.FindFirst(
+	// Clear every next pointer first so relinking never violates the unique constraint
+	txs := []db.PrismaTransaction{
+		h.db.FormularNode.FindMany(
 			db.FormularNode.FormularID.Equals(formularID),
-			db.FormularNode.NodeID.Equals(currentNodeID),
-		).Exec(r.Context())
+		).Update(
+			db.FormularNode.NextID.SetOptional(nil),
+		).Tx(),
+	}
+
+	for i := 0; i < len(ordered)-1; i++ {
+		txs = append(txs, h.db.FormularNode.FindUnique(
+			db.FormularNode.ID.Equals(ordered[i].ID),
+		).Update(
+			db.FormularNode.NextID.Set(ordered[i+1].ID),
+		).Tx())
+	}
+
+	auditTx, err := audit(h.db, r, ActionReorderNodes, ResourceFormular, formularID, formularLinks(formularNodes), formularLinks(ordered))
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	txs = append(txs, auditTx)
+
+	versions, err := versionTxs(r.Context(), h.db, formularID, nil, nodeIDsOf(formularLinks(ordered)))
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	txs = append(txs, versions...)
+
+	if err := h.db.Prisma.Transaction(txs...).Exec(r.Context()); err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// CompileFormularInput represents the input for compiling an expression into a formular
+type CompileFormularInput struct {
+	Expression string `json:"expression" validate:"required,max=10000" example:"(price * qty) - discount"` // The infix expression to compile
+	Replace    bool   `json:"replace,omitempty" example:"false"`                                           // Whether to replace the existing node sequence of the formular
+}
+
+// Compile godoc
+// @Summary Compile an expression into a formular
+// @Description Parse an infix expression and create the nodes and node sequence that evaluate it. Numbers are written in plain decimal notation, exponent notation such as 1e5 is rejected. With replace, the old sequence is removed and its nodes that no other formular uses and the caller owns are deleted.
+// @Tags formulars
+// @Accept json
+// @Produce json
+// @Param id path string true "Formular ID"
+// @Param expression body CompileFormularInput true "Expression to compile"
+// @Success 201 {array} db.FormularNodeModel
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 403 {object} APIError "Insufficient role"
+// @Failure 404 {object} APIError "Formular not found"
+// @Failure 409 {object} APIError "Formular already has nodes"
+// @Failure 422 {object} APIError{details=engine.SyntaxError} "Invalid input, or syntax error with line and column"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /formulars/{id}/compile [post]
+func (h *FormularHandler) Compile(w http.ResponseWriter, r *http.Request) {
+	formularID := chi.URLParam(r, "id")
+
+	var input CompileFormularInput
+	if !decodeInput(w, r, &input) {
+		return
+	}
+
+	if !authorize(w, r, h.db, ResourceFormular, formularID, RoleEditor) {
+		return
+	}
+
+	formular, err := h.db.Formular.FindFirst(
+		db.Formular.ID.Equals(formularID),
+		db.Formular.DeletedAt.IsNull(),
+	).Exec(r.Context())
+
+	if err != nil {
+		writeDBError(w, r, err, "Formular")
+		return
+	}
+
+	start := time.Now()
+	compiled, err := engine.Compile(input.Expression)
+	observeEngine("compile", start, err)
+	if err != nil {
+		writeError(w, r, http.StatusUnprocessableEntity, CodeInvalid, err.Error(), err)
+		return
+	}
+
+	existing, err := h.db.FormularNode.FindMany(
+		db.FormularNode.FormularID.Equals(formularID),
+	).Exec(r.Context())
 
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	if len(existing) > 0 && !input.Replace {
+		writeError(w, r, http.StatusConflict, CodeConflict, "Formular already has nodes, set replace to overwrite them", nil)
+		return
+	}
+
+	// Generate IDs up front so the whole chain can be created in one transaction
+	nodeIDs := make([]string, len(compiled))
+	linkIDs := make([]string, len(compiled))
+	for i := range compiled {
+		nodeIDs[i] = newID()
+		linkIDs[i] = newID()
+	}
+
+	// Replacing removes the old links first, clearing every next pointer so they can be deleted in
+	// any order, and deletes the nodes that leaves unused like a cascade delete would
+	var txs []db.PrismaTransaction
+	if len(existing) > 0 {
+		orphanIDs, err := orphanedNodes(r.Context(), h.db, existing, []string{formularID})
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalError(w, r, err)
 			return
 		}
 
-		// Then update it
-		_, err = h.db.FormularNode.FindUnique(
-			db.FormularNode.ID.Equals(formularNode.ID),
-		).Update(
-			db.FormularNode.NextID.Set(nextNodeID),
-		).Exec(r.Context())
+		txs = append(txs,
+			h.db.FormularNode.FindMany(
+				db.FormularNode.FormularID.Equals(formularID),
+			).Update(
+				db.FormularNode.NextID.SetOptional(nil),
+			).Tx(),
+			h.db.FormularNode.FindMany(
+				db.FormularNode.FormularID.Equals(formularID),
+			).Delete().Tx(),
+		)
+		txs = append(txs, deleteNodes(h.db, orphanIDs)...)
+	}
 
+	created := make([]SnapshotNode, 0, len(compiled))
+	for i, node := range compiled {
+		nodeData, err := engine.MarshalNodeData(node.Expr)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeInternalError(w, r, err)
 			return
 		}
+		created = append(created, SnapshotNode{NodeID: nodeIDs[i], Name: node.Name, NodeData: nodeData})
+
+		txs = append(txs, h.db.Node.CreateOne(
+			db.Node.Name.Set(node.Name),
+			db.Node.Workspace.Link(db.Workspace.ID.Equals(workspaceID(r))),
+			db.Node.NodeData.Set(nodeData),
+			db.Node.ID.Set(nodeIDs[i]),
+		).Tx(), grantOwner(h.db, r, ResourceNode, nodeIDs[i]))
+	}
+
+	// Create the links from the tail backwards so every next pointer refers to an existing row
+	for i := len(compiled) - 1; i >= 0; i-- {
+		params := []db.FormularNodeSetParam{db.FormularNode.ID.Set(linkIDs[i])}
+		if i < len(compiled)-1 {
+			params = append(params, db.FormularNode.Next.Link(db.FormularNode.ID.Equals(linkIDs[i+1])))
+		}
+
+		txs = append(txs, h.db.FormularNode.CreateOne(
+			db.FormularNode.Formular.Link(db.Formular.ID.Equals(formularID)),
+			db.FormularNode.Node.Link(db.Node.ID.Equals(nodeIDs[i])),
+			params...,
+		).Tx())
+	}
+
+	before := formularLinks(currentOrder(existing, func(fn db.FormularNodeModel) (string, *string) {
+		return fn.ID, fn.InnerFormularNode.NextID
+	}))
+	after := make([]auditLink, 0, len(compiled))
+	for i := range compiled {
+		after = append(after, auditLink{ID: linkIDs[i], NodeID: nodeIDs[i]})
+	}
+	auditTx, err := audit(h.db, r, ActionCompile, ResourceFormular, formularID, before, after)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	txs = append(txs, auditTx)
+
+	versions, err := versionTxs(r.Context(), h.db, formularID, &formular.Name, nodeIDs, created...)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
 	}
+	txs = append(txs, versions...)
 
-	// Clear the next pointer for the last node
-	// Find the last formular node first
-	lastNode, err := h.db.FormularNode.FindFirst(
+	if err := h.db.Prisma.Transaction(txs...).Exec(r.Context()); err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	nodes, err := h.db.FormularNode.FindMany(
 		db.FormularNode.FormularID.Equals(formularID),
-		db.FormularNode.NodeID.Equals(input.NodeOrder[len(input.NodeOrder)-1]),
+	).With(
+		db.FormularNode.Node.Fetch(),
 	).Exec(r.Context())
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeInternalError(w, r, err)
 		return
 	}
 
-	// Then update it
-	_, err = h.db.FormularNode.FindUnique(
-		db.FormularNode.ID.Equals(lastNode.ID),
-	).Update(
-		db.FormularNode.NextID.SetOptional(nil),
+	nodes, err = orderChain(nodes, func(fn db.FormularNodeModel) (string, *string) {
+		return fn.ID, fn.InnerFormularNode.NextID
+	})
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(nodes)
+}
+
+// Integrity godoc
+// @Summary Check the node sequence of a formular
+// @Description Report multiple heads, cycles, orphans and dangling next pointers in a formular's node sequence
+// @Tags formulars
+// @Accept json
+// @Produce json
+// @Param id path string true "Formular ID"
+// @Success 200 {object} IntegrityReport
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 404 {object} APIError "Formular not found"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /formulars/{id}/integrity [get]
+func (h *FormularHandler) Integrity(w http.ResponseWriter, r *http.Request) {
+	formularID := chi.URLParam(r, "id")
+
+	if !authorize(w, r, h.db, ResourceFormular, formularID, RoleViewer) {
+		return
+	}
+
+	_, err := h.db.Formular.FindUnique(
+		db.Formular.ID.Equals(formularID),
 	).Exec(r.Context())
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeDBError(w, r, err, "Formular")
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	formularNodes, err := h.db.FormularNode.FindMany(
+		db.FormularNode.FormularID.Equals(formularID),
+	).Exec(r.Context())
+
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	report := checkChain(formularNodes, func(fn db.FormularNodeModel) (string, *string) {
+		return fn.ID, fn.InnerFormularNode.NextID
+	})
+
+	json.NewEncoder(w).Encode(report)
+}
+
+// AffectedCalculations godoc
+// @Summary List the calculations a formular change would affect
+// @Description Get the calculations that use the formular without pinning a version, whose results would change if the formular were updated. Calculations that only use pinned versions of the formular are not affected, and only calculations the caller has a role on are returned.
+// @Tags formulars
+// @Accept json
+// @Produce json
+// @Param id path string true "Formular ID"
+// @Param includeDeleted query bool false "Include soft-deleted calculations" default(false)
+// @Success 200 {array} db.CalculationModel
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 404 {object} APIError "Formular not found"
+// @Failure 422 {object} APIError{details=ValidationError} "Invalid query parameters"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /formulars/{id}/affected-calculations [get]
+func (h *FormularHandler) AffectedCalculations(w http.ResponseWriter, r *http.Request) {
+	formularID := chi.URLParam(r, "id")
+
+	includeDeleted, problems := queryFlag(r, "includeDeleted")
+	if len(problems) > 0 {
+		writeValidationError(w, r, problems)
+		return
+	}
+
+	if !authorize(w, r, h.db, ResourceFormular, formularID, RoleViewer) {
+		return
+	}
+
+	_, err := h.db.Formular.FindUnique(
+		db.Formular.ID.Equals(formularID),
+	).Exec(r.Context())
+
+	if err != nil {
+		writeDBError(w, r, err, "Formular")
+		return
+	}
+
+	// Calculations the caller has no role on are not revealed
+	filter := filterQuery{table: "Calculation"}
+	filter.where(`"id" IN (SELECT "resourceId" FROM "Permission" WHERE "principal" = ? AND "resource" = ?)`, principalID(r), ResourceCalculation)
+	filter.where(`"workspaceId" = ?`, workspaceID(r))
+	filter.where(`"id" IN (SELECT "calculationId" FROM "CalculationFormular" WHERE "formularId" = ? AND "formularVersionId" IS NULL)`, formularID)
+	if !includeDeleted {
+		filter.where(`"deletedAt" IS NULL`)
+	}
+
+	ids, err := filter.ids(r.Context(), h.db, "name", false, nil, 0)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	calculations, err := findInOrder(ids, func(ids []string) ([]db.CalculationModel, error) {
+		return h.db.Calculation.FindMany(db.Calculation.ID.In(ids)).Exec(r.Context())
+	}, func(m db.CalculationModel) string {
+		return m.ID
+	})
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(calculations)
 }
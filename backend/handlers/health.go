@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"backend/config"
+	"backend/prisma/db"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// readyTimeout bounds the database checks of a readiness probe
+const readyTimeout = 5 * time.Second
+
+// HealthHandler handles the probes of load balancers and orchestrators. Its endpoints are mounted
+// outside of /api, so they are not part of the API documentation.
+type HealthHandler struct {
+	db *db.PrismaClient
+	ai config.AI
+}
+
+// NewHealthHandler creates a new health handler
+func NewHealthHandler(db *db.PrismaClient, ai config.AI) *HealthHandler {
+	return &HealthHandler{db: db, ai: ai}
+}
+
+// Routes registers the probe endpoints at the root of r
+func (h *HealthHandler) Routes(r chi.Router) {
+	r.Get("/healthz", h.Health)
+	r.Get("/readyz", h.Ready)
+	r.Get("/version", h.Version)
+}
+
+// Health reports that the process is alive. It checks nothing else, so a failing dependency does
+// not get the process restarted.
+func (h *HealthHandler) Health(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok\n"))
+}
+
+// Readiness is the result of a readiness probe
+type Readiness struct {
+	Ready  bool              `json:"ready"`  // Whether every check passed
+	Checks map[string]string `json:"checks"` // "ok" or "failed" by check, "not configured" for an unset AI provider
+}
+
+// Ready reports whether the server can handle API requests: the database answers queries and every
+// table and column of the schema exists. It responds with a 503 when a check fails, whose reason is
+// logged rather than exposed. Whether the AI provider is configured is reported but does not affect
+// readiness.
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	readiness := Readiness{Ready: true, Checks: map[string]string{}}
+	check := func(name string, err error) {
+		if err != nil {
+			RecordError(r.Context(), fmt.Errorf("%s: %w", name, err))
+			readiness.Ready = false
+			readiness.Checks[name] = "failed"
+			return
+		}
+		readiness.Checks[name] = "ok"
+	}
+
+	var rows []map[string]any
+	dbErr := h.db.Prisma.QueryRaw("SELECT 1").Exec(ctx, &rows)
+	check("database", dbErr)
+
+	if dbErr != nil {
+		check("schema", errors.New("database unavailable"))
+	} else {
+		check("schema", h.checkSchema(ctx))
+	}
+
+	// Only AI requests need the provider, so a missing key is reported without failing the probe
+	if h.ai.Configured() {
+		readiness.Checks["ai"] = "ok"
+	} else {
+		readiness.Checks["ai"] = "not configured"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if !readiness.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(readiness)
+}
+
+// checkSchema reads a row of every model, which fails when the database lacks a table or column
+// of the schema, such as when the schema was not pushed after an upgrade
+func (h *HealthHandler) checkSchema(ctx context.Context) error {
+	queries := []func() error{
+		func() error { _, err := h.db.Calculation.FindFirst().Exec(ctx); return err },
+		func() error { _, err := h.db.Variable.FindFirst().Exec(ctx); return err },
+		func() error { _, err := h.db.CalculationFormular.FindFirst().Exec(ctx); return err },
+		func() error { _, err := h.db.Formular.FindFirst().Exec(ctx); return err },
+		func() error { _, err := h.db.FormularVersion.FindFirst().Exec(ctx); return err },
+		func() error { _, err := h.db.FormularNode.FindFirst().Exec(ctx); return err },
+		func() error { _, err := h.db.Node.FindFirst().Exec(ctx); return err },
+		func() error { _, err := h.db.AuditEvent.FindFirst().Exec(ctx); return err },
+		func() error { _, err := h.db.APIKey.FindFirst().Exec(ctx); return err },
+		func() error { _, err := h.db.Permission.FindFirst().Exec(ctx); return err },
+		func() error { _, err := h.db.Workspace.FindFirst().Exec(ctx); return err },
+		func() error { _, err := h.db.WorkspaceMember.FindFirst().Exec(ctx); return err },
+	}
+
+	for _, query := range queries {
+		if err := query(); err != nil && !errors.Is(err, db.ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// BuildInfo describes the running binary
+type BuildInfo struct {
+	Module    string `json:"module"`    // The main module path
+	Version   string `json:"version"`   // The main module version, "(devel)" for local builds
+	GoVersion string `json:"goVersion"` // The Go version the binary was built with
+	Revision  string `json:"revision"`  // The VCS revision, empty when built outside of a repository
+	Time      string `json:"time"`      // The time of the revision
+	Modified  bool   `json:"modified"`  // Whether the working tree had uncommitted changes
+}
+
+// Version reports the build of the running binary
+func (h *HealthHandler) Version(w http.ResponseWriter, r *http.Request) {
+	var build BuildInfo
+	if info, ok := debug.ReadBuildInfo(); ok {
+		build.Module = info.Main.Path
+		build.Version = info.Main.Version
+		build.GoVersion = info.GoVersion
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				build.Revision = setting.Value
+			case "vcs.time":
+				build.Time = setting.Value
+			case "vcs.modified":
+				build.Modified = setting.Value == "true"
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(build)
+}
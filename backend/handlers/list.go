@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"backend/prisma/db"
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits for the number of items on a page
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 200
+)
+
+// sortFields are the fields List endpoints can sort by; prefix a field with - to sort descending
+var sortFields = []string{"name", "createdAt", "updatedAt"}
+
+// Page is a page of a list response
+type Page[T any] struct {
+	Items      []T     `json:"items"`                                                     // The items on this page
+	NextCursor *string `json:"nextCursor" example:"123e4567-e89b-12d3-a456-426614174000"` // The cursor of the next page, null on the last page
+	Total      int     `json:"total" example:"42"`                                        // The number of items matching the filters across all pages
+}
+
+// listQuery holds the pagination, filter and sort parameters of a List request
+type listQuery struct {
+	limit          int
+	cursor         *string
+	name           *string
+	createdAfter   *time.Time
+	createdBefore  *time.Time
+	updatedAfter   *time.Time
+	updatedBefore  *time.Time
+	sort           string
+	descending     bool
+	includeDeleted bool
+}
+
+// parseListQuery reads the List parameters from the query string, reporting every invalid one
+func parseListQuery(r *http.Request) (listQuery, []FieldProblem) {
+	values := r.URL.Query()
+	query := listQuery{sort: "createdAt"}
+
+	var problems []FieldProblem
+	query.limit, query.cursor, problems = parsePage(r)
+
+	if name := values.Get("name"); name != "" {
+		query.name = &name
+	}
+
+	times := []struct {
+		param string
+		dest  **time.Time
+	}{
+		{"createdAfter", &query.createdAfter},
+		{"createdBefore", &query.createdBefore},
+		{"updatedAfter", &query.updatedAfter},
+		{"updatedBefore", &query.updatedBefore},
+	}
+	for _, t := range times {
+		parsed, timeProblems := queryTime(r, t.param)
+		*t.dest = parsed
+		problems = append(problems, timeProblems...)
+	}
+
+	includeDeleted, flagProblems := queryFlag(r, "includeDeleted")
+	query.includeDeleted = includeDeleted
+	problems = append(problems, flagProblems...)
+
+	if sort := values.Get("sort"); sort != "" {
+		field, descending := strings.CutPrefix(sort, "-")
+		if !slices.Contains(sortFields, field) {
+			problems = append(problems, FieldProblem{Field: "sort", Message: "must be one of " + strings.Join(sortFields, ", ") + ", optionally prefixed with -"})
+		}
+		query.sort, query.descending = field, descending
+	}
+
+	return query, problems
+}
+
+// parsePage reads the limit and cursor parameters shared by every paginated endpoint
+func parsePage(r *http.Request) (int, *string, []FieldProblem) {
+	values := r.URL.Query()
+	limit := DefaultPageLimit
+	var cursor *string
+	var problems []FieldProblem
+
+	if value := values.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > MaxPageLimit {
+			problems = append(problems, FieldProblem{Field: "limit", Message: "must be an integer between 1 and " + strconv.Itoa(MaxPageLimit)})
+		}
+		limit = n
+	}
+
+	if value := values.Get("cursor"); value != "" {
+		if !uuidPattern.MatchString(value) {
+			problems = append(problems, FieldProblem{Field: "cursor", Message: "must be a UUID"})
+		}
+		cursor = &value
+	}
+
+	return limit, cursor, problems
+}
+
+// queryTime reads an optional RFC 3339 timestamp query parameter
+func queryTime(r *http.Request, name string) (*time.Time, []FieldProblem) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, []FieldProblem{{Field: name, Message: "must be an RFC 3339 timestamp"}}
+	}
+	return &parsed, nil
+}
+
+// queryFlag reads an optional boolean query parameter
+func queryFlag(r *http.Request, name string) (bool, []FieldProblem) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+	flag, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, []FieldProblem{{Field: name, Message: "must be true or false"}}
+	}
+	return flag, nil
+}
+
+// newPage trims items fetched with one extra row to the page limit and sets the next cursor
+func newPage[T any](items []T, total, limit int, id func(T) string) Page[T] {
+	page := Page[T]{Items: items, Total: total}
+	if len(items) > limit {
+		page.Items = items[:limit]
+		next := id(items[limit-1])
+		page.NextCursor = &next
+	}
+	return page
+}
+
+// filterQuery builds raw queries over a table from conditions joined with AND. The caller's roles
+// are filtered with a subquery on Permission, as the IDs of every resource a caller has a role on
+// could exceed the number of variables a query can bind, and the Prisma client cannot express such
+// a subquery. Pages are fetched as IDs and then loaded with findInOrder.
+type filterQuery struct {
+	table      string
+	conditions []string
+	params     []any
+}
+
+// where adds a condition with ? placeholders for params
+func (q *filterQuery) where(condition string, params ...any) {
+	q.conditions = append(q.conditions, condition)
+	q.params = append(q.params, params...)
+}
+
+// whereIfPresent adds a condition on a single optional value when it is set
+func whereIfPresent[T any](q *filterQuery, condition string, value *T) {
+	if value != nil {
+		q.where(condition, *value)
+	}
+}
+
+// sql returns the query selecting columns from the matching rows
+func (q filterQuery) sql(columns string) string {
+	sql := `SELECT ` + columns + ` FROM "` + q.table + `"`
+	if len(q.conditions) > 0 {
+		sql += " WHERE " + strings.Join(q.conditions, " AND ")
+	}
+	return sql
+}
+
+// count returns the number of matching rows. The Prisma client has no count query, and the total
+// of a page would otherwise require fetching every matching row.
+func (q filterQuery) count(ctx context.Context, client *db.PrismaClient) (int, error) {
+	var rows []struct {
+		Count json.Number `json:"count"`
+	}
+	if err := client.Prisma.QueryRaw(q.sql(`COUNT(*) AS "count"`), q.params...).Exec(ctx, &rows); err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	count, err := rows[0].Count.Int64()
+	return int(count), err
+}
+
+// ids returns the IDs of the matching rows ordered by column and then by ID, at most limit of
+// them unless limit is 0. A cursor starts after the row with that ID, as Prisma's cursor with
+// Skip(1) does.
+func (q filterQuery) ids(ctx context.Context, client *db.PrismaClient, column string, descending bool, cursor *string, limit int) ([]string, error) {
+	direction, after := "ASC", ">"
+	if descending {
+		direction, after = "DESC", "<"
+	}
+	if cursor != nil {
+		q.where(`("`+column+`", "id") `+after+` (SELECT "`+column+`", "id" FROM "`+q.table+`" WHERE "id" = ?)`, *cursor)
+	}
+
+	sql := q.sql(`"id"`) + ` ORDER BY "` + column + `" ` + direction + `, "id" ` + direction
+	if limit > 0 {
+		sql += " LIMIT " + strconv.Itoa(limit)
+	}
+
+	var rows []struct {
+		ID string `json:"id"`
+	}
+	if err := client.Prisma.QueryRaw(sql, q.params...).Exec(ctx, &rows); err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+	return ids, nil
+}
+
+// maxFindIDs bounds the IDs a single FindMany of findInOrder binds, well below SQLite's limit on
+// the variables of a query
+const maxFindIDs = 500
+
+// findInOrder loads the records with the given IDs, in chunks of maxFindIDs, and returns them in
+// the order of ids. IDs of records find does not return are left out.
+func findInOrder[T any](ids []string, find func(ids []string) ([]T, error), id func(T) string) ([]T, error) {
+	found := make(map[string]T, len(ids))
+	for chunk := range slices.Chunk(ids, maxFindIDs) {
+		records, err := find(chunk)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			found[id(record)] = record
+		}
+	}
+
+	ordered := make([]T, 0, len(ids))
+	for _, id := range ids {
+		if record, ok := found[id]; ok {
+			ordered = append(ordered, record)
+		}
+	}
+	return ordered, nil
+}
+
+// listFilter builds the filters of a List request on the records of a resource. Like the list
+// itself, it only matches records in the request's workspace the caller has a role on.
+func listFilter(r *http.Request, table, resource string, query listQuery) filterQuery {
+	filter := filterQuery{table: table}
+	filter.where(`"id" IN (SELECT "resourceId" FROM "Permission" WHERE "principal" = ? AND "resource" = ?)`, principalID(r), resource)
+	filter.where(`"workspaceId" = ?`, workspaceID(r))
+	if query.name != nil {
+		filter.where(`"name" LIKE ? ESCAPE '\'`, "%"+likeEscaper.Replace(*query.name)+"%")
+	}
+	whereIfPresent(&filter, `"createdAt" >= ?`, query.createdAfter)
+	whereIfPresent(&filter, `"createdAt" < ?`, query.createdBefore)
+	whereIfPresent(&filter, `"updatedAt" >= ?`, query.updatedAfter)
+	whereIfPresent(&filter, `"updatedAt" < ?`, query.updatedBefore)
+	if !query.includeDeleted {
+		filter.where(`"deletedAt" IS NULL`)
+	}
+	return filter
+}
+
+// listPage returns the IDs on the page of a List request, fetching one extra to know whether there
+// is a next page, and the number of records across all pages
+func listPage(r *http.Request, client *db.PrismaClient, table, resource string, query listQuery) ([]string, int, error) {
+	filter := listFilter(r, table, resource, query)
+
+	ids, err := filter.ids(r.Context(), client, query.sort, query.descending, query.cursor, query.limit+1)
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := filter.count(r.Context(), client)
+	if err != nil {
+		return nil, 0, err
+	}
+	return ids, total, nil
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern, so that a name filter matches literally as
+// Prisma's contains does
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestFindInOrder(t *testing.T) {
+	ids := make([]string, 0, 2*maxFindIDs+1)
+	for i := range 2*maxFindIDs + 1 {
+		ids = append(ids, fmt.Sprintf("%04d", i))
+	}
+	slices.Reverse(ids)
+
+	// The records are found in chunks, in the opposite order, and one of them is gone
+	var chunks []int
+	records, err := findInOrder(ids, func(chunk []string) ([]string, error) {
+		chunks = append(chunks, len(chunk))
+		found := slices.Clone(chunk)
+		slices.Sort(found)
+		return slices.DeleteFunc(found, func(id string) bool { return id == "0007" }), nil
+	}, func(record string) string {
+		return record
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []int{maxFindIDs, maxFindIDs, 1}; !slices.Equal(chunks, want) {
+		t.Fatalf("chunks = %v, want %v", chunks, want)
+	}
+	want := slices.DeleteFunc(slices.Clone(ids), func(id string) bool { return id == "0007" })
+	if !slices.Equal(records, want) {
+		t.Fatalf("records are not in the order of ids")
+	}
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+)
+
+type requestLogKey struct{}
+
+// requestLog collects what the access log line of a request reports besides the response itself.
+// It is shared by every context derived from the request's, so middleware further down the chain
+// can add to it.
+type requestLog struct {
+	mu     sync.Mutex
+	logger *slog.Logger
+	err    error
+}
+
+// WithLogger returns a copy of ctx carrying the logger of a request
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, requestLogKey{}, &requestLog{logger: logger})
+}
+
+// Logger returns the logger of the request of ctx, or the default logger outside of a request
+func Logger(ctx context.Context) *slog.Logger {
+	if log, ok := ctx.Value(requestLogKey{}).(*requestLog); ok {
+		log.mu.Lock()
+		defer log.mu.Unlock()
+		return log.logger
+	}
+	return slog.Default()
+}
+
+// AddLogAttrs adds attributes, as key-value pairs like those of slog.Logger.With, to the logger of
+// the request of ctx and so to its access log line
+func AddLogAttrs(ctx context.Context, args ...any) {
+	if log, ok := ctx.Value(requestLogKey{}).(*requestLog); ok {
+		log.mu.Lock()
+		defer log.mu.Unlock()
+		log.logger = log.logger.With(args...)
+	}
+}
+
+// RecordError records the error behind a failed response, such as a Prisma or AI provider error,
+// for the access log line of the request of ctx. Outside of a request it is logged immediately.
+func RecordError(ctx context.Context, err error) {
+	log, ok := ctx.Value(requestLogKey{}).(*requestLog)
+	if !ok {
+		slog.ErrorContext(ctx, "request failed", "error", err)
+		return
+	}
+
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	log.err = errors.Join(log.err, err)
+}
+
+// RequestError returns the errors recorded for the request of ctx
+func RequestError(ctx context.Context) error {
+	if log, ok := ctx.Value(requestLogKey{}).(*requestLog); ok {
+		log.mu.Lock()
+		defer log.mu.Unlock()
+		return log.err
+	}
+	return nil
+}
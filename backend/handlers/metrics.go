@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"backend/metrics"
+	"backend/prisma/db"
+	"bytes"
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/steebchen/prisma-client-go/engine"
+)
+
+// prismaMetricsTimeout bounds the request for the metrics of the Prisma query engine
+const prismaMetricsTimeout = 5 * time.Second
+
+// MetricsHandler exposes the metrics of the server and of the Prisma query engine in the
+// Prometheus text exposition format. It is mounted outside of /api without authentication, so it
+// should only be reachable by the scraper.
+type MetricsHandler struct {
+	db *db.PrismaClient
+}
+
+// NewMetricsHandler creates a new metrics handler
+func NewMetricsHandler(db *db.PrismaClient) *MetricsHandler {
+	return &MetricsHandler{db: db}
+}
+
+// Metrics writes every metric. When the query engine cannot be asked for its metrics, the error is
+// logged and only the metrics of the server are written, so that a scrape does not fail entirely.
+func (h *MetricsHandler) Metrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	if err := metrics.WriteTo(w); err != nil {
+		Logger(r.Context()).Error("write metrics", "error", err)
+		return
+	}
+
+	prisma, err := h.prismaMetrics(r.Context())
+	if err != nil {
+		Logger(r.Context()).Error("prisma metrics", "error", err)
+		return
+	}
+	if len(prisma) > 0 && !bytes.HasSuffix(prisma, []byte("\n")) {
+		prisma = append(prisma, '\n')
+	}
+	w.Write(prisma)
+}
+
+// prismaMetrics returns the metrics of the query engine, which it exposes in the Prometheus
+// format because the schema enables the metrics preview feature
+func (h *MetricsHandler) prismaMetrics(ctx context.Context) ([]byte, error) {
+	queryEngine, ok := h.db.Engine.(*engine.QueryEngine)
+	if !ok {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, prismaMetricsTimeout)
+	defer cancel()
+	return queryEngine.Request(ctx, http.MethodGet, "/metrics?format=prometheus", nil, true)
+}
+
+// observeEngine records the duration of an evaluation engine operation that started at start
+func observeEngine(operation string, start time.Time, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	metrics.EngineDuration.Since(start, operation, result)
+}
@@ -2,9 +2,14 @@
 package handlers
 
 import (
+	"backend/engine"
 	"backend/prisma/db"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -28,26 +33,63 @@ func (h *NodeHandler) Routes() chi.Router {
 	r.Get("/{id}", h.Get)
 	r.Put("/{id}", h.Update)
 	r.Delete("/{id}", h.Delete)
+	r.Post("/{id}/restore", h.Restore)
+
+	// Sharing endpoints
+	r.Get("/{id}/permissions", h.ListPermissions)
+	r.Put("/{id}/permissions/{principal}", h.GrantPermission)
+	r.Delete("/{id}/permissions/{principal}", h.RevokePermission)
 
 	return r
 }
 
 // List godoc
 // @Summary List nodes
-// @Description Get all nodes
+// @Description Get a page of nodes, optionally filtered by name and timestamps. Pass nextCursor as cursor to fetch the following page.
 // @Tags nodes
 // @Accept json
 // @Produce json
-// @Success 200 {array} db.NodeModel
+// @Param limit query int false "Maximum number of items to return" minimum(1) maximum(200) default(50)
+// @Param cursor query string false "Cursor returned as nextCursor by the previous page"
+// @Param name query string false "Only return nodes whose name contains this text"
+// @Param createdAfter query string false "Only return nodes created at or after this RFC 3339 timestamp"
+// @Param createdBefore query string false "Only return nodes created before this RFC 3339 timestamp"
+// @Param updatedAfter query string false "Only return nodes updated at or after this RFC 3339 timestamp"
+// @Param updatedBefore query string false "Only return nodes updated before this RFC 3339 timestamp"
+// @Param sort query string false "Sort field, prefixed with - for descending order" Enums(name, -name, createdAt, -createdAt, updatedAt, -updatedAt) default(createdAt)
+// @Param includeDeleted query bool false "Include soft-deleted nodes" default(false)
+// @Success 200 {object} Page[db.NodeModel]
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 422 {object} APIError{details=ValidationError} "Invalid query parameters"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /nodes [get]
 func (h *NodeHandler) List(w http.ResponseWriter, r *http.Request) {
-	nodes, err := h.db.Node.FindMany().Exec(r.Context())
+	query, problems := parseListQuery(r)
+	if len(problems) > 0 {
+		writeValidationError(w, r, problems)
+		return
+	}
+
+	// Only list the nodes the caller has a role on
+	ids, total, err := listPage(r, h.db, "Node", ResourceNode, query)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	id := func(m db.NodeModel) string {
+		return m.ID
+	}
+	nodes, err := findInOrder(ids, func(ids []string) ([]db.NodeModel, error) {
+		return h.db.Node.FindMany(db.Node.ID.In(ids)).Exec(r.Context())
+	}, id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeInternalError(w, r, err)
 		return
 	}
 
-	json.NewEncoder(w).Encode(nodes)
+	json.NewEncoder(w).Encode(newPage(nodes, total, query.limit, id))
 }
 
 // Get godoc
@@ -57,17 +99,38 @@ func (h *NodeHandler) List(w http.ResponseWriter, r *http.Request) {
 // @Accept json
 // @Produce json
 // @Param id path string true "Node ID"
+// @Param includeDeleted query bool false "Return the node even if it is soft-deleted" default(false)
 // @Success 200 {object} db.NodeModel
-// @Failure 404 {string} string "Node not found"
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 404 {object} APIError "Node not found"
+// @Failure 422 {object} APIError{details=ValidationError} "Invalid query parameters"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /nodes/{id} [get]
 func (h *NodeHandler) Get(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
+
+	includeDeleted, problems := queryFlag(r, "includeDeleted")
+	if len(problems) > 0 {
+		writeValidationError(w, r, problems)
+		return
+	}
+
+	if !authorize(w, r, h.db, ResourceNode, id, RoleViewer) {
+		return
+	}
+
 	node, err := h.db.Node.FindUnique(
 		db.Node.ID.Equals(id),
 	).Exec(r.Context())
 
 	if err != nil {
-		http.Error(w, "Node not found", http.StatusNotFound)
+		writeDBError(w, r, err, "Node")
+		return
+	}
+
+	if _, deleted := node.DeletedAt(); deleted && !includeDeleted {
+		writeError(w, r, http.StatusNotFound, CodeNotFound, "Node not found", nil)
 		return
 	}
 
@@ -76,8 +139,8 @@ func (h *NodeHandler) Get(w http.ResponseWriter, r *http.Request) {
 
 // CreateNodeInput represents the input for creating a node
 type CreateNodeInput struct {
-	Name     string `json:"name" example:"My Node"`      // The name of the node
-	NodeData string `json:"nodeData" example:"raw data"` // The data associated with the node
+	Name     string `json:"name" validate:"required,max=255" example:"My Node"`                                                      // The name of the node
+	NodeData string `json:"nodeData" validate:"required,max=10000" example:"{\"version\":1,\"type\":\"constant\",\"value\":\"42\"}"` // The node expression as a versioned nodeData JSON document
 }
 
 // Create godoc
@@ -88,98 +151,370 @@ type CreateNodeInput struct {
 // @Produce json
 // @Param node body CreateNodeInput true "Node to create"
 // @Success 201 {object} db.NodeModel
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 422 {object} APIError{details=ValidationError} "Invalid input or nodeData"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /nodes [post]
 func (h *NodeHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var input CreateNodeInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeInput(w, r, &input) {
 		return
 	}
 
-	node, err := h.db.Node.CreateOne(
-		db.Node.Name.Set(input.Name),
-		db.Node.NodeData.Set(input.NodeData),
-	).Exec(r.Context())
+	if _, err := engine.ParseNodeData(input.NodeData); err != nil {
+		writeValidationError(w, r, []FieldProblem{nodeDataProblem(err)})
+		return
+	}
+
+	now := time.Now()
+	node := db.NodeModel{InnerNode: db.InnerNode{
+		ID:          newID(),
+		Name:        input.Name,
+		NodeData:    input.NodeData,
+		WorkspaceID: workspaceID(r),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}}
+
+	create := h.db.Node.CreateOne(
+		db.Node.Name.Set(node.Name),
+		db.Node.Workspace.Link(db.Workspace.ID.Equals(node.WorkspaceID)),
+		db.Node.NodeData.Set(node.NodeData),
+		db.Node.ID.Set(node.ID),
+		db.Node.CreatedAt.Set(now),
+		db.Node.UpdatedAt.Set(now),
+	).Tx()
 
+	auditTx, err := audit(h.db, r, ActionCreate, ResourceNode, node.ID, nil, node)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeInternalError(w, r, err)
+		return
+	}
+
+	if err := h.db.Prisma.Transaction(
+		create,
+		grantOwner(h.db, r, ResourceNode, node.ID),
+		auditTx,
+	).Exec(r.Context()); err != nil {
+		writeInternalError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(node)
+	json.NewEncoder(w).Encode(create.Result())
 }
 
 // UpdateNodeInput represents the input for updating a node
 type UpdateNodeInput struct {
-	Name     *string `json:"name,omitempty" example:"Updated Node"`     // The new name of the node
-	NodeData *string `json:"nodeData,omitempty" example:"updated data"` // The new data for the node
+	Name     *string `json:"name,omitempty" validate:"notblank,min=1,max=255" example:"Updated Node"`                                    // The new name of the node
+	NodeData *string `json:"nodeData,omitempty" validate:"max=10000" example:"{\"version\":1,\"type\":\"operator\",\"operator\":\"+\"}"` // The new node expression as a versioned nodeData JSON document
 }
 
 // Update godoc
 // @Summary Update a node
-// @Description Update a node by ID
+// @Description Update a node by ID. Every formular using the node records a new version with the change.
 // @Tags nodes
 // @Accept json
 // @Produce json
 // @Param id path string true "Node ID"
 // @Param node body UpdateNodeInput true "Node updates"
 // @Success 200 {object} db.NodeModel
-// @Failure 404 {string} string "Node not found"
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 403 {object} APIError "Insufficient role"
+// @Failure 404 {object} APIError "Node not found"
+// @Failure 422 {object} APIError{details=ValidationError} "Invalid input or nodeData"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /nodes/{id} [put]
 func (h *NodeHandler) Update(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
 	var input UpdateNodeInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeInput(w, r, &input) {
 		return
 	}
 
-	params := []db.NodeSetParam{}
+	if input.NodeData != nil {
+		if _, err := engine.ParseNodeData(*input.NodeData); err != nil {
+			writeValidationError(w, r, []FieldProblem{nodeDataProblem(err)})
+			return
+		}
+	}
+
+	if !authorize(w, r, h.db, ResourceNode, id, RoleEditor) {
+		return
+	}
+
+	// Soft-deleted records must be restored before they can be changed
+	before, err := h.db.Node.FindFirst(
+		db.Node.ID.Equals(id),
+		db.Node.DeletedAt.IsNull(),
+	).Exec(r.Context())
+
+	if err != nil {
+		writeDBError(w, r, err, "Node")
+		return
+	}
+
+	after := *before
+	after.UpdatedAt = time.Now()
+	params := []db.NodeSetParam{db.Node.UpdatedAt.Set(after.UpdatedAt)}
 
 	if input.Name != nil {
+		after.Name = *input.Name
 		params = append(params, db.Node.Name.Set(*input.Name))
 	}
 	if input.NodeData != nil {
+		after.NodeData = *input.NodeData
 		params = append(params, db.Node.NodeData.Set(*input.NodeData))
 	}
 
-	node, err := h.db.Node.FindUnique(
+	update := h.db.Node.FindUnique(
 		db.Node.ID.Equals(id),
 	).Update(
 		params...,
-	).Exec(r.Context())
+	).Tx()
 
+	auditTx, err := audit(h.db, r, ActionUpdate, ResourceNode, id, before, after)
 	if err != nil {
-		http.Error(w, "Node not found", http.StatusNotFound)
+		writeInternalError(w, r, err)
 		return
 	}
 
-	json.NewEncoder(w).Encode(node)
+	// The formulars using the node change with it, so their history must too
+	versions, err := nodeVersionTxs(r.Context(), h.db, SnapshotNode{NodeID: id, Name: after.Name, NodeData: after.NodeData})
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	txs := append([]db.PrismaTransaction{update, auditTx}, versions...)
+	if err := h.db.Prisma.Transaction(txs...).Exec(r.Context()); err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(update.Result())
 }
 
 // Delete godoc
 // @Summary Delete a node
-// @Description Delete a node by ID
+// @Description Soft delete a node by ID so it can be restored. Soft-deleted nodes stay in the formulars that use them until they are purged, and calculations using those formulars unpinned fail to evaluate with a 409 until the node is restored or removed. With permanent=true the node is deleted immediately: in restrict mode a node that is used by a formular is not deleted; detach and cascade remove it from every formular, relink the surrounding nodes and record a new version of each formular, which requires the editor role on each of those formulars. A restricted delete only lists the dependents in formulars the caller has a role on and counts the others.
 // @Tags nodes
 // @Accept json
 // @Produce json
 // @Param id path string true "Node ID"
+// @Param permanent query bool false "Delete the node immediately instead of soft deleting it" default(false)
+// @Param mode query string false "How a permanent delete handles related records, only with permanent=true" Enums(restrict, detach, cascade) default(restrict)
 // @Success 204 "No Content"
-// @Failure 404 {string} string "Node not found"
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 403 {object} APIError "Insufficient role on the node or a formular using it"
+// @Failure 404 {object} APIError "Node not found"
+// @Failure 409 {object} APIError{details=DependentsConflict} "Node is used by formulars"
+// @Failure 422 {object} APIError{details=ValidationError} "Invalid query parameters"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /nodes/{id} [delete]
 func (h *NodeHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
-	_, err := h.db.Node.FindUnique(
+	permanent, mode, problems := parseDeleteQuery(r)
+	if len(problems) > 0 {
+		writeValidationError(w, r, problems)
+		return
+	}
+
+	if !authorize(w, r, h.db, ResourceNode, id, RoleOwner) {
+		return
+	}
+
+	node, err := h.db.Node.FindUnique(
 		db.Node.ID.Equals(id),
-	).Delete().Exec(r.Context())
+	).Exec(r.Context())
 
 	if err != nil {
-		http.Error(w, "Node not found", http.StatusNotFound)
+		writeDBError(w, r, err, "Node")
+		return
+	}
+
+	if !permanent {
+		if _, deleted := node.DeletedAt(); !deleted {
+			now := time.Now()
+			after := *node
+			after.UpdatedAt = now
+			after.InnerNode.DeletedAt = &now
+
+			auditTx, err := audit(h.db, r, ActionDelete, ResourceNode, id, node, after)
+			if err != nil {
+				writeInternalError(w, r, err)
+				return
+			}
+
+			err = h.db.Prisma.Transaction(
+				h.db.Node.FindUnique(
+					db.Node.ID.Equals(id),
+				).Update(
+					db.Node.DeletedAt.Set(now),
+					db.Node.UpdatedAt.Set(now),
+				).Tx(),
+				auditTx,
+			).Exec(r.Context())
+
+			if err != nil {
+				writeInternalError(w, r, err)
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	txs, dependents, err := nodeDeletion(r.Context(), h.db, id, mode)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	if !permitDeletion(w, r, h.db, ResourceNode, id, mode, dependents) {
+		return
+	}
+
+	auditTx, err := audit(h.db, r, ActionDelete, ResourceNode, id, node, nil)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	txs = append(txs, auditTx)
+	if err := h.db.Prisma.Transaction(txs...).Exec(r.Context()); err != nil {
+		writeInternalError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// Restore godoc
+// @Summary Restore a node
+// @Description Restore a soft-deleted node by ID. Restoring a node that is not deleted has no effect.
+// @Tags nodes
+// @Accept json
+// @Produce json
+// @Param id path string true "Node ID"
+// @Success 200 {object} db.NodeModel
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 403 {object} APIError "Insufficient role"
+// @Failure 404 {object} APIError "Node not found"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /nodes/{id}/restore [post]
+func (h *NodeHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if !authorize(w, r, h.db, ResourceNode, id, RoleOwner) {
+		return
+	}
+
+	node, err := h.db.Node.FindUnique(
+		db.Node.ID.Equals(id),
+	).Exec(r.Context())
+
+	if err != nil {
+		writeDBError(w, r, err, "Node")
+		return
+	}
+
+	if _, deleted := node.DeletedAt(); !deleted {
+		json.NewEncoder(w).Encode(node)
+		return
+	}
+
+	after := *node
+	after.UpdatedAt = time.Now()
+	after.InnerNode.DeletedAt = nil
+
+	restore := h.db.Node.FindUnique(
+		db.Node.ID.Equals(id),
+	).Update(
+		db.Node.DeletedAt.SetOptional(nil),
+		db.Node.UpdatedAt.Set(after.UpdatedAt),
+	).Tx()
+
+	auditTx, err := audit(h.db, r, ActionRestore, ResourceNode, id, node, after)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	if err := h.db.Prisma.Transaction(
+		restore,
+		auditTx,
+	).Exec(r.Context()); err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(restore.Result())
+}
+
+// nodeDataProblem converts a nodeData parse error into a problem with the nodeData field of the request body
+func nodeDataProblem(err error) FieldProblem {
+	var fieldErr *engine.FieldError
+	if !errors.As(err, &fieldErr) {
+		return FieldProblem{Field: "nodeData", Message: err.Error()}
+	}
+	if fieldErr.Field == "" {
+		return FieldProblem{Field: "nodeData", Message: fieldErr.Message}
+	}
+	return FieldProblem{Field: "nodeData." + fieldErr.Field, Message: fieldErr.Message}
+}
+
+// UpgradeNodeData rewrites the free-text nodeData of nodes created before the versioned schema,
+// operators and decimal numbers, as versioned documents so that they evaluate again. Each node is
+// upgraded in its own transaction and audited as updated by the system. It returns the number of
+// upgraded nodes and the IDs of the nodes whose nodeData is neither, which fail to evaluate until
+// they are edited.
+func UpgradeNodeData(ctx context.Context, client *db.PrismaClient) (int, []string, error) {
+	// Every versioned document is a JSON object
+	nodes, err := client.Node.FindMany(
+		db.Node.Not(db.Node.NodeData.StartsWith("{")),
+	).Exec(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	upgraded := 0
+	var invalid []string
+	for _, node := range nodes {
+		expr, ok := engine.ParseLegacyNodeData(node.NodeData)
+		if !ok {
+			invalid = append(invalid, node.ID)
+			continue
+		}
+
+		nodeData, err := engine.MarshalNodeData(expr)
+		if err != nil {
+			return upgraded, invalid, err
+		}
+
+		after := node
+		after.NodeData = nodeData
+		auditTx, err := auditEvent(client, node.WorkspaceID, "system", "", ActionUpdate, ResourceNode, node.ID, node, after)
+		if err != nil {
+			return upgraded, invalid, err
+		}
+
+		if err := client.Prisma.Transaction(
+			client.Node.FindUnique(
+				db.Node.ID.Equals(node.ID),
+			).Update(
+				db.Node.NodeData.Set(nodeData),
+			).Tx(),
+			auditTx,
+		).Exec(ctx); err != nil {
+			return upgraded, invalid, fmt.Errorf("node %s: %w", node.ID, err)
+		}
+		upgraded++
+	}
+	return upgraded, invalid, nil
+}
@@ -0,0 +1,584 @@
+package handlers
+
+import (
+	"backend/auth"
+	"backend/prisma/db"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Roles a principal can have on a calculation, formular or node. Every role includes the
+// permissions of the roles before it.
+const (
+	// RoleViewer may read and evaluate a resource
+	RoleViewer = "viewer"
+	// RoleEditor may also change a resource and its sequence
+	RoleEditor = "editor"
+	// RoleOwner may also delete, restore and share a resource
+	RoleOwner = "owner"
+)
+
+// roleRanks orders the roles by privilege
+var roleRanks = map[string]int{RoleViewer: 1, RoleEditor: 2, RoleOwner: 3}
+
+// resourceNames are the names of the resources in error messages
+var resourceNames = map[string]string{
+	ResourceCalculation: "Calculation",
+	ResourceFormular:    "Formular",
+	ResourceNode:        "Node",
+}
+
+// GrantPermissionInput represents the input for sharing a resource with a principal
+type GrantPermissionInput struct {
+	Role string `json:"role" validate:"required,oneof=viewer editor owner" example:"editor"` // The role to grant: viewer, editor or owner
+}
+
+// ListPermissions godoc
+// @Summary List who has access to a calculation
+// @Description Get the principals that have a role on a calculation, owners first
+// @Tags calculations
+// @Accept json
+// @Produce json
+// @Param id path string true "Calculation ID"
+// @Success 200 {array} db.PermissionModel
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 404 {object} APIError "Calculation not found"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /calculations/{id}/permissions [get]
+func (h *CalculationHandler) ListPermissions(w http.ResponseWriter, r *http.Request) {
+	listPermissions(w, r, h.db, ResourceCalculation)
+}
+
+// GrantPermission godoc
+// @Summary Share a calculation
+// @Description Give a principal a role on a calculation, replacing the role it had. Viewers can read and evaluate the calculation, editors can also change it, and owners can also delete and share it. The last owner cannot be demoted.
+// @Tags calculations
+// @Accept json
+// @Produce json
+// @Param id path string true "Calculation ID"
+// @Param principal path string true "Principal ID"
+// @Param permission body GrantPermissionInput true "Role to grant"
+// @Success 200 {object} db.PermissionModel "Role changed"
+// @Success 201 {object} db.PermissionModel "Role granted"
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 403 {object} APIError "Insufficient role"
+// @Failure 404 {object} APIError "Calculation not found"
+// @Failure 409 {object} APIError "Calculation must keep at least one owner"
+// @Failure 422 {object} APIError{details=ValidationError} "Invalid input"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /calculations/{id}/permissions/{principal} [put]
+func (h *CalculationHandler) GrantPermission(w http.ResponseWriter, r *http.Request) {
+	grantPermission(w, r, h.db, ResourceCalculation)
+}
+
+// RevokePermission godoc
+// @Summary Stop sharing a calculation
+// @Description Take a principal's role on a calculation away. The last owner cannot be removed.
+// @Tags calculations
+// @Accept json
+// @Produce json
+// @Param id path string true "Calculation ID"
+// @Param principal path string true "Principal ID"
+// @Success 204 "No Content"
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 403 {object} APIError "Insufficient role"
+// @Failure 404 {object} APIError "Calculation or permission not found"
+// @Failure 409 {object} APIError "Calculation must keep at least one owner"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /calculations/{id}/permissions/{principal} [delete]
+func (h *CalculationHandler) RevokePermission(w http.ResponseWriter, r *http.Request) {
+	revokePermission(w, r, h.db, ResourceCalculation)
+}
+
+// ListPermissions godoc
+// @Summary List who has access to a formular
+// @Description Get the principals that have a role on a formular, owners first
+// @Tags formulars
+// @Accept json
+// @Produce json
+// @Param id path string true "Formular ID"
+// @Success 200 {array} db.PermissionModel
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 404 {object} APIError "Formular not found"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /formulars/{id}/permissions [get]
+func (h *FormularHandler) ListPermissions(w http.ResponseWriter, r *http.Request) {
+	listPermissions(w, r, h.db, ResourceFormular)
+}
+
+// GrantPermission godoc
+// @Summary Share a formular
+// @Description Give a principal a role on a formular, replacing the role it had. Viewers can read and evaluate the formular, editors can also change it, and owners can also delete and share it. The last owner cannot be demoted.
+// @Tags formulars
+// @Accept json
+// @Produce json
+// @Param id path string true "Formular ID"
+// @Param principal path string true "Principal ID"
+// @Param permission body GrantPermissionInput true "Role to grant"
+// @Success 200 {object} db.PermissionModel "Role changed"
+// @Success 201 {object} db.PermissionModel "Role granted"
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 403 {object} APIError "Insufficient role"
+// @Failure 404 {object} APIError "Formular not found"
+// @Failure 409 {object} APIError "Formular must keep at least one owner"
+// @Failure 422 {object} APIError{details=ValidationError} "Invalid input"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /formulars/{id}/permissions/{principal} [put]
+func (h *FormularHandler) GrantPermission(w http.ResponseWriter, r *http.Request) {
+	grantPermission(w, r, h.db, ResourceFormular)
+}
+
+// RevokePermission godoc
+// @Summary Stop sharing a formular
+// @Description Take a principal's role on a formular away. The last owner cannot be removed.
+// @Tags formulars
+// @Accept json
+// @Produce json
+// @Param id path string true "Formular ID"
+// @Param principal path string true "Principal ID"
+// @Success 204 "No Content"
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 403 {object} APIError "Insufficient role"
+// @Failure 404 {object} APIError "Formular or permission not found"
+// @Failure 409 {object} APIError "Formular must keep at least one owner"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /formulars/{id}/permissions/{principal} [delete]
+func (h *FormularHandler) RevokePermission(w http.ResponseWriter, r *http.Request) {
+	revokePermission(w, r, h.db, ResourceFormular)
+}
+
+// ListPermissions godoc
+// @Summary List who has access to a node
+// @Description Get the principals that have a role on a node, owners first
+// @Tags nodes
+// @Accept json
+// @Produce json
+// @Param id path string true "Node ID"
+// @Success 200 {array} db.PermissionModel
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 404 {object} APIError "Node not found"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /nodes/{id}/permissions [get]
+func (h *NodeHandler) ListPermissions(w http.ResponseWriter, r *http.Request) {
+	listPermissions(w, r, h.db, ResourceNode)
+}
+
+// GrantPermission godoc
+// @Summary Share a node
+// @Description Give a principal a role on a node, replacing the role it had. Viewers can read the node and use it in formulars, editors can also change it, and owners can also delete and share it. The last owner cannot be demoted.
+// @Tags nodes
+// @Accept json
+// @Produce json
+// @Param id path string true "Node ID"
+// @Param principal path string true "Principal ID"
+// @Param permission body GrantPermissionInput true "Role to grant"
+// @Success 200 {object} db.PermissionModel "Role changed"
+// @Success 201 {object} db.PermissionModel "Role granted"
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 403 {object} APIError "Insufficient role"
+// @Failure 404 {object} APIError "Node not found"
+// @Failure 409 {object} APIError "Node must keep at least one owner"
+// @Failure 422 {object} APIError{details=ValidationError} "Invalid input"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /nodes/{id}/permissions/{principal} [put]
+func (h *NodeHandler) GrantPermission(w http.ResponseWriter, r *http.Request) {
+	grantPermission(w, r, h.db, ResourceNode)
+}
+
+// RevokePermission godoc
+// @Summary Stop sharing a node
+// @Description Take a principal's role on a node away. The last owner cannot be removed.
+// @Tags nodes
+// @Accept json
+// @Produce json
+// @Param id path string true "Node ID"
+// @Param principal path string true "Principal ID"
+// @Success 204 "No Content"
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 403 {object} APIError "Insufficient role"
+// @Failure 404 {object} APIError "Node or permission not found"
+// @Failure 409 {object} APIError "Node must keep at least one owner"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /nodes/{id}/permissions/{principal} [delete]
+func (h *NodeHandler) RevokePermission(w http.ResponseWriter, r *http.Request) {
+	revokePermission(w, r, h.db, ResourceNode)
+}
+
+// principalID returns the ID of the principal that made a request, or an empty string for a
+// request that was not authenticated
+func principalID(r *http.Request) string {
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		return principal.ID
+	}
+	return ""
+}
+
+// roleOf returns the role of a principal on a resource, or an empty string when it has none
+func roleOf(ctx context.Context, client *db.PrismaClient, principal, resource, id string) (string, error) {
+	if principal == "" {
+		return "", nil
+	}
+
+	permission, err := client.Permission.FindUnique(
+		db.Permission.ResourceResourceIDPrincipal(
+			db.Permission.Resource.Equals(resource),
+			db.Permission.ResourceID.Equals(id),
+			db.Permission.Principal.Equals(principal),
+		),
+	).Exec(ctx)
+
+	if errors.Is(err, db.ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return permission.Role, nil
+}
+
+// authorize checks that a resource belongs to the request's workspace and that the request's
+// principal has at least the required role on it. A resource of another workspace or one the
+// principal has no role on gets the same 404 as a missing resource, so the principal cannot probe
+// for IDs; a lesser role gets a 403. It returns whether the request may proceed.
+func authorize(w http.ResponseWriter, r *http.Request, client *db.PrismaClient, resource, id, required string) bool {
+	found, err := inWorkspace(r.Context(), client, resource, id, workspaceID(r))
+	if err != nil {
+		writeInternalError(w, r, err)
+		return false
+	}
+
+	role := ""
+	if found {
+		role, err = roleOf(r.Context(), client, principalID(r), resource, id)
+		if err != nil {
+			writeInternalError(w, r, err)
+			return false
+		}
+	}
+
+	if role == "" {
+		writeError(w, r, http.StatusNotFound, CodeNotFound, resourceNames[resource]+" not found", nil)
+		return false
+	}
+	if roleRanks[role] < roleRanks[required] {
+		writeError(w, r, http.StatusForbidden, CodeForbidden, "The "+required+" role is required", nil)
+		return false
+	}
+	return true
+}
+
+// UnownedIDs returns the IDs of the calculations, formulars and nodes nobody owns by resource, in
+// one workspace or, when workspaceID is nil, in every workspace. Such records, like those created
+// before permissions were enforced, are invisible to every caller until cmd/claim assigns an owner.
+func UnownedIDs(ctx context.Context, client *db.PrismaClient, workspaceID *string) (map[string][]string, error) {
+	calculations, err := client.Calculation.FindMany(
+		db.Calculation.WorkspaceID.EqualsIfPresent(workspaceID),
+	).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	formulars, err := client.Formular.FindMany(
+		db.Formular.WorkspaceID.EqualsIfPresent(workspaceID),
+	).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := client.Node.FindMany(
+		db.Node.WorkspaceID.EqualsIfPresent(workspaceID),
+	).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := map[string][]string{}
+	for _, calculation := range calculations {
+		ids[ResourceCalculation] = append(ids[ResourceCalculation], calculation.ID)
+	}
+	for _, formular := range formulars {
+		ids[ResourceFormular] = append(ids[ResourceFormular], formular.ID)
+	}
+	for _, node := range nodes {
+		ids[ResourceNode] = append(ids[ResourceNode], node.ID)
+	}
+
+	unowned := map[string][]string{}
+	for resource, resourceIDs := range ids {
+		owners, err := client.Permission.FindMany(
+			db.Permission.Resource.Equals(resource),
+			db.Permission.ResourceID.In(resourceIDs),
+			db.Permission.Role.Equals(RoleOwner),
+		).Exec(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		owned := map[string]bool{}
+		for _, owner := range owners {
+			owned[owner.ResourceID] = true
+		}
+		for _, id := range resourceIDs {
+			if !owned[id] {
+				unowned[resource] = append(unowned[resource], id)
+			}
+		}
+	}
+	return unowned, nil
+}
+
+// ownedIDs filters ids to the resources of a kind the principal of ctx owns. Without a principal,
+// as for the purger, every ID is kept.
+func ownedIDs(ctx context.Context, client *db.PrismaClient, resource string, ids []string) ([]string, error) {
+	principal, ok := auth.FromContext(ctx)
+	if !ok || len(ids) == 0 {
+		return ids, nil
+	}
+
+	permissions, err := client.Permission.FindMany(
+		db.Permission.Principal.Equals(principal.ID),
+		db.Permission.Resource.Equals(resource),
+		db.Permission.ResourceID.In(ids),
+		db.Permission.Role.Equals(RoleOwner),
+	).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	owned := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		owned = append(owned, permission.ResourceID)
+	}
+	return owned, nil
+}
+
+// grantOwner returns the transaction that makes the request's principal the owner of a resource it
+// creates, to be executed together with the creation
+func grantOwner(client *db.PrismaClient, r *http.Request, resource, id string) db.PrismaTransaction {
+	return client.Permission.CreateOne(
+		db.Permission.Resource.Set(resource),
+		db.Permission.ResourceID.Set(id),
+		db.Permission.Principal.Set(principalID(r)),
+		db.Permission.Role.Set(RoleOwner),
+	).Tx()
+}
+
+// revokeAll returns the transaction that deletes every permission on the given resources of a kind
+func revokeAll(client *db.PrismaClient, resource string, ids ...string) db.PrismaTransaction {
+	return client.Permission.FindMany(
+		db.Permission.Resource.Equals(resource),
+		db.Permission.ResourceID.In(ids),
+	).Delete().Tx()
+}
+
+// listPermissions writes the permissions on the resource in the URL, owners first
+func listPermissions(w http.ResponseWriter, r *http.Request, client *db.PrismaClient, resource string) {
+	id := chi.URLParam(r, "id")
+	if !authorize(w, r, client, resource, id, RoleViewer) {
+		return
+	}
+
+	permissions, err := client.Permission.FindMany(
+		db.Permission.Resource.Equals(resource),
+		db.Permission.ResourceID.Equals(id),
+	).OrderBy(
+		db.Permission.CreatedAt.Order(db.SortOrderAsc),
+	).Exec(r.Context())
+
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	ordered := make([]db.PermissionModel, 0, len(permissions))
+	for _, role := range []string{RoleOwner, RoleEditor, RoleViewer} {
+		for _, permission := range permissions {
+			if permission.Role == role {
+				ordered = append(ordered, permission)
+			}
+		}
+	}
+
+	json.NewEncoder(w).Encode(ordered)
+}
+
+// grantPermission gives the principal in the URL a role on the resource in the URL, replacing the
+// role it had before
+func grantPermission(w http.ResponseWriter, r *http.Request, client *db.PrismaClient, resource string) {
+	id := chi.URLParam(r, "id")
+	principal := chi.URLParam(r, "principal")
+
+	var input GrantPermissionInput
+	if !decodeInput(w, r, &input) {
+		return
+	}
+
+	if !authorize(w, r, client, resource, id, RoleOwner) {
+		return
+	}
+
+	before, err := client.Permission.FindUnique(
+		db.Permission.ResourceResourceIDPrincipal(
+			db.Permission.Resource.Equals(resource),
+			db.Permission.ResourceID.Equals(id),
+			db.Permission.Principal.Equals(principal),
+		),
+	).Exec(r.Context())
+
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	now := time.Now()
+	if before == nil {
+		after := db.PermissionModel{InnerPermission: db.InnerPermission{
+			ID:         newID(),
+			Resource:   resource,
+			ResourceID: id,
+			Principal:  principal,
+			Role:       input.Role,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		}}
+
+		create := client.Permission.CreateOne(
+			db.Permission.Resource.Set(resource),
+			db.Permission.ResourceID.Set(id),
+			db.Permission.Principal.Set(principal),
+			db.Permission.Role.Set(input.Role),
+			db.Permission.ID.Set(after.ID),
+			db.Permission.CreatedAt.Set(now),
+			db.Permission.UpdatedAt.Set(now),
+		).Tx()
+
+		auditTx, err := audit(client, r, ActionGrant, resource, id, nil, after)
+		if err != nil {
+			writeInternalError(w, r, err)
+			return
+		}
+
+		if err := client.Prisma.Transaction(
+			create,
+			auditTx,
+		).Exec(r.Context()); err != nil {
+			writeInternalError(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(create.Result())
+		return
+	}
+
+	if before.Role == RoleOwner && input.Role != RoleOwner && !keepsOwner(w, r, client, resource, id) {
+		return
+	}
+
+	after := *before
+	after.Role = input.Role
+	after.UpdatedAt = now
+
+	update := client.Permission.FindUnique(
+		db.Permission.ID.Equals(before.ID),
+	).Update(
+		db.Permission.Role.Set(input.Role),
+		db.Permission.UpdatedAt.Set(now),
+	).Tx()
+
+	auditTx, err := audit(client, r, ActionGrant, resource, id, before, after)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	if err := client.Prisma.Transaction(
+		update,
+		auditTx,
+	).Exec(r.Context()); err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(update.Result())
+}
+
+// revokePermission takes every role on the resource in the URL away from the principal in the URL
+func revokePermission(w http.ResponseWriter, r *http.Request, client *db.PrismaClient, resource string) {
+	id := chi.URLParam(r, "id")
+	principal := chi.URLParam(r, "principal")
+
+	if !authorize(w, r, client, resource, id, RoleOwner) {
+		return
+	}
+
+	permission, err := client.Permission.FindUnique(
+		db.Permission.ResourceResourceIDPrincipal(
+			db.Permission.Resource.Equals(resource),
+			db.Permission.ResourceID.Equals(id),
+			db.Permission.Principal.Equals(principal),
+		),
+	).Exec(r.Context())
+
+	if err != nil {
+		writeDBError(w, r, err, "Permission")
+		return
+	}
+
+	if permission.Role == RoleOwner && !keepsOwner(w, r, client, resource, id) {
+		return
+	}
+
+	auditTx, err := audit(client, r, ActionRevoke, resource, id, permission, nil)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	err = client.Prisma.Transaction(
+		client.Permission.FindUnique(
+			db.Permission.ID.Equals(permission.ID),
+		).Delete().Tx(),
+		auditTx,
+	).Exec(r.Context())
+
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// keepsOwner checks that a resource has another owner besides the one whose role is being taken
+// away, writing a 409 if it does not. Otherwise nobody could delete or share the resource anymore.
+func keepsOwner(w http.ResponseWriter, r *http.Request, client *db.PrismaClient, resource, id string) bool {
+	owners, err := client.Permission.FindMany(
+		db.Permission.Resource.Equals(resource),
+		db.Permission.ResourceID.Equals(id),
+		db.Permission.Role.Equals(RoleOwner),
+	).Exec(r.Context())
+
+	if err != nil {
+		writeInternalError(w, r, err)
+		return false
+	}
+
+	if len(owners) < 2 {
+		writeError(w, r, http.StatusConflict, CodeConflict, resourceNames[resource]+" must keep at least one owner", nil)
+		return false
+	}
+	return true
+}
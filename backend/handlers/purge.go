@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"backend/prisma/db"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Purger permanently deletes records that were soft deleted longer than the retention period ago
+type Purger struct {
+	db        *db.PrismaClient
+	retention time.Duration
+}
+
+// NewPurger creates a new purger
+func NewPurger(db *db.PrismaClient, retention time.Duration) *Purger {
+	return &Purger{db: db, retention: retention}
+}
+
+// Start purges once immediately and then every interval until ctx is canceled
+func (p *Purger) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := p.Run(ctx)
+		if err != nil {
+			slog.Error("purge failed", "error", err)
+		}
+		if purged > 0 {
+			slog.Info("purged expired records", "records", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run permanently deletes every expired calculation, formular and node, in that order so that
+// formulars and nodes are no longer referenced by purged parents. Each record is deleted in its
+// own transaction in detach mode, so a record that fails does not keep the others from being
+// purged. It returns the number of deleted records and every error that occurred.
+func (p *Purger) Run(ctx context.Context) (int, error) {
+	cutoff := time.Now().Add(-p.retention)
+	purged := 0
+	var errs []error
+
+	calculations, err := p.db.Calculation.FindMany(
+		db.Calculation.DeletedAt.Lt(cutoff),
+	).Exec(ctx)
+	if err != nil {
+		return purged, err
+	}
+	for _, calculation := range calculations {
+		if err := p.purge(ctx, ResourceCalculation, calculation.ID, calculation.WorkspaceID, calculation, calculationDeletion); err != nil {
+			errs = append(errs, fmt.Errorf("calculation %s: %w", calculation.ID, err))
+			continue
+		}
+		purged++
+	}
+
+	formulars, err := p.db.Formular.FindMany(
+		db.Formular.DeletedAt.Lt(cutoff),
+	).Exec(ctx)
+	if err != nil {
+		return purged, errors.Join(append(errs, err)...)
+	}
+	for _, formular := range formulars {
+		if err := p.purge(ctx, ResourceFormular, formular.ID, formular.WorkspaceID, formular, formularDeletion); err != nil {
+			errs = append(errs, fmt.Errorf("formular %s: %w", formular.ID, err))
+			continue
+		}
+		purged++
+	}
+
+	nodes, err := p.db.Node.FindMany(
+		db.Node.DeletedAt.Lt(cutoff),
+	).Exec(ctx)
+	if err != nil {
+		return purged, errors.Join(append(errs, err)...)
+	}
+	for _, node := range nodes {
+		if err := p.purge(ctx, ResourceNode, node.ID, node.WorkspaceID, node, nodeDeletion); err != nil {
+			errs = append(errs, fmt.Errorf("node %s: %w", node.ID, err))
+			continue
+		}
+		purged++
+	}
+
+	return purged, errors.Join(errs...)
+}
+
+// purge permanently deletes a single record in detach mode and audits it as deleted by the system
+// in the record's workspace
+func (p *Purger) purge(ctx context.Context, resource, id, workspaceID string, before any, deletion func(context.Context, *db.PrismaClient, string, string) ([]db.PrismaTransaction, []Dependent, error)) error {
+	txs, _, err := deletion(ctx, p.db, id, DeleteDetach)
+	if err != nil {
+		return err
+	}
+
+	auditTx, err := auditEvent(p.db, workspaceID, "system", "", ActionDelete, resource, id, before, nil)
+	if err != nil {
+		return err
+	}
+	txs = append(txs, auditTx)
+	return p.db.Prisma.Transaction(txs...).Exec(ctx)
+}
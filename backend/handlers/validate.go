@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// FieldProblem describes a single invalid field of a request body
+type FieldProblem struct {
+	Field   string `json:"field" example:"name"`          // The JSON path of the field
+	Message string `json:"message" example:"is required"` // What is wrong with the field
+}
+
+// ValidationError lists every invalid field of a request body
+type ValidationError struct {
+	Fields []FieldProblem `json:"fields"` // The invalid fields in declaration order
+}
+
+// decodeInput decodes a JSON request body into dst, rejecting unknown fields, and validates it
+// against the validate tags of its fields. It writes a 400 for malformed JSON and a 422 listing
+// every invalid field, and reports whether dst may be used.
+//
+// Supported rules, evaluated left to right:
+//
+//	required    the field must be present and not blank or empty
+//	notblank    a present string must not be blank
+//	omitempty   skip the remaining rules when the value is empty
+//	min=N max=N the length of a string in characters or of a slice in items
+//	uuid        the string must be a UUID
+//	oneof=a b   the string must be one of the space separated values
+//	dive        apply the remaining rules to every element of a slice
+func decodeInput(w http.ResponseWriter, r *http.Request, dst any) bool {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(dst); err != nil {
+		if problem, ok := decodeProblem(err); ok {
+			writeValidationError(w, r, []FieldProblem{problem})
+			return false
+		}
+		writeDecodeError(w, r, err)
+		return false
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		writeDecodeError(w, r, errors.New("unexpected data after JSON object"))
+		return false
+	}
+
+	if problems := validate(dst); len(problems) > 0 {
+		writeValidationError(w, r, problems)
+		return false
+	}
+	return true
+}
+
+// writeValidationError writes a 422 listing the invalid fields
+func writeValidationError(w http.ResponseWriter, r *http.Request, problems []FieldProblem) {
+	messages := make([]string, 0, len(problems))
+	for _, problem := range problems {
+		messages = append(messages, problem.Field+" "+problem.Message)
+	}
+	writeError(w, r, http.StatusUnprocessableEntity, CodeInvalid, "Invalid input: "+strings.Join(messages, "; "), ValidationError{Fields: problems})
+}
+
+// decodeProblem converts a JSON decoding error that concerns a single field into a field problem
+func decodeProblem(err error) (FieldProblem, bool) {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return FieldProblem{Field: typeErr.Field, Message: fmt.Sprintf("must be of type %s", typeErr.Type)}, true
+	}
+
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return FieldProblem{Field: strings.Trim(field, `"`), Message: "is not a known field"}, true
+	}
+
+	return FieldProblem{}, false
+}
+
+// validate checks every field of the struct v points to against its validate tag
+func validate(v any) []FieldProblem {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var problems []FieldProblem
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		tag, ok := field.Tag.Lookup("validate")
+		if !ok || !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" {
+			name = field.Name
+		}
+
+		problems = append(problems, validateField(name, value.Field(i), strings.Split(tag, ","))...)
+	}
+	return problems
+}
+
+// validateField applies rules to a single value, stopping at the first violated rule
+func validateField(name string, value reflect.Value, rules []string) []FieldProblem {
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			if slices.Contains(rules, "required") {
+				return []FieldProblem{{Field: name, Message: "is required"}}
+			}
+			return nil
+		}
+		value = value.Elem()
+	}
+
+	for i, rule := range rules {
+		key, param, _ := strings.Cut(rule, "=")
+		switch key {
+		case "omitempty":
+			if value.IsZero() {
+				return nil
+			}
+		case "dive":
+			var problems []FieldProblem
+			for j := 0; j < value.Len(); j++ {
+				problems = append(problems, validateField(fmt.Sprintf("%s[%d]", name, j), value.Index(j), rules[i+1:])...)
+			}
+			return problems
+		default:
+			if message := checkRule(key, param, value); message != "" {
+				return []FieldProblem{{Field: name, Message: message}}
+			}
+		}
+	}
+	return nil
+}
+
+// checkRule returns why value violates a rule, or an empty string if it does not
+func checkRule(key, param string, value reflect.Value) string {
+	switch key {
+	case "required":
+		switch value.Kind() {
+		case reflect.String:
+			if strings.TrimSpace(value.String()) == "" {
+				return "is required"
+			}
+		case reflect.Slice, reflect.Map:
+			if value.Len() == 0 {
+				return "is required"
+			}
+		}
+	case "notblank":
+		if strings.TrimSpace(value.String()) == "" {
+			return "must not be blank"
+		}
+	case "min", "max":
+		limit, err := strconv.Atoi(param)
+		if err != nil {
+			panic(fmt.Sprintf("validate: invalid %s parameter %q", key, param))
+		}
+
+		length, unit := value.Len(), "items"
+		if value.Kind() == reflect.String {
+			length, unit = utf8.RuneCountInString(value.String()), "characters"
+		}
+
+		if key == "min" && length < limit {
+			return fmt.Sprintf("must be at least %d %s", limit, unit)
+		}
+		if key == "max" && length > limit {
+			return fmt.Sprintf("must be at most %d %s", limit, unit)
+		}
+	case "uuid":
+		if !uuidPattern.MatchString(value.String()) {
+			return "must be a UUID"
+		}
+	case "oneof":
+		allowed := strings.Fields(param)
+		if !slices.Contains(allowed, value.String()) {
+			return "must be one of " + strings.Join(allowed, ", ")
+		}
+	default:
+		panic(fmt.Sprintf("validate: unknown rule %q", key))
+	}
+	return ""
+}
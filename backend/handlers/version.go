@@ -0,0 +1,585 @@
+package handlers
+
+import (
+	"backend/prisma/db"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Node change operations of a FormularDiff
+const (
+	NodeAdded    = "added"
+	NodeRemoved  = "removed"
+	NodeModified = "modified"
+)
+
+// SnapshotNode is a node as it was when a formular version was recorded
+type SnapshotNode struct {
+	NodeID   string `json:"nodeId" example:"123e4567-e89b-12d3-a456-426614174000"`                       // The ID of the node
+	Name     string `json:"name" example:"Multiply"`                                                     // The name of the node
+	NodeData string `json:"nodeData" example:"{\"version\":1,\"type\":\"operator\",\"operator\":\"*\"}"` // The node expression as a versioned nodeData JSON document
+}
+
+// FormularVersion is an immutable snapshot of a formular's name and node sequence
+type FormularVersion struct {
+	ID         string         `json:"id" example:"123e4567-e89b-12d3-a456-426614174000"`         // The ID of the version
+	FormularID string         `json:"formularId" example:"123e4567-e89b-12d3-a456-426614174001"` // The ID of the formular
+	Version    int            `json:"version" example:"3"`                                       // The version number, starting at 1
+	Name       string         `json:"name" example:"My Formular"`                                // The name of the formular
+	Nodes      []SnapshotNode `json:"nodes"`                                                     // The nodes in sequence order
+	CreatedAt  time.Time      `json:"createdAt" example:"2024-01-01T00:00:00Z"`                  // When the version was recorded
+}
+
+// NameChange is a changed formular name
+type NameChange struct {
+	From string `json:"from" example:"Old Formular"` // The name in the older version
+	To   string `json:"to" example:"New Formular"`   // The name in the newer version
+}
+
+// NodeChange is a node that was added to, removed from or modified in a formular's sequence
+type NodeChange struct {
+	Op           string        `json:"op" example:"modified" enums:"added,removed,modified"` // The kind of change
+	FromPosition *int          `json:"fromPosition" example:"0"`                             // The position in the from version, null for added nodes
+	ToPosition   *int          `json:"toPosition" example:"1"`                               // The position in the to version, null for removed nodes
+	From         *SnapshotNode `json:"from"`                                                 // The node in the from version, null for added nodes
+	To           *SnapshotNode `json:"to"`                                                   // The node in the to version, null for removed nodes
+}
+
+// FormularDiff is the structural difference between two versions of a formular
+type FormularDiff struct {
+	From  int          `json:"from" example:"1"` // The version compared from
+	To    int          `json:"to" example:"3"`   // The version compared to
+	Name  *NameChange  `json:"name"`             // The name change, null when the name is unchanged
+	Nodes []NodeChange `json:"nodes"`            // The node changes in sequence order
+}
+
+// newFormularVersion decodes the node snapshot of a stored version
+func newFormularVersion(version *db.FormularVersionModel) (FormularVersion, error) {
+	var nodes []SnapshotNode
+	if err := json.Unmarshal([]byte(version.Nodes), &nodes); err != nil {
+		return FormularVersion{}, err
+	}
+
+	return FormularVersion{
+		ID:         version.ID,
+		FormularID: version.FormularID,
+		Version:    version.Version,
+		Name:       version.Name,
+		Nodes:      nodes,
+		CreatedAt:  version.CreatedAt,
+	}, nil
+}
+
+// versionTxs returns the transaction that records name and the nodes with the given IDs, in
+// sequence order, as the next version of a formular. It belongs in the transaction of the mutation
+// that produced this state, so that no version is recorded for a mutation that failed and no
+// mutation commits without its version. A nil name keeps the stored name. Nodes are read from the
+// database unless they are pending, which are the nodes the mutation creates or changes. Nothing is
+// returned when the state is unchanged since the latest version.
+func versionTxs(ctx context.Context, client *db.PrismaClient, formularID string, name *string, nodeIDs []string, pending ...SnapshotNode) ([]db.PrismaTransaction, error) {
+	if name == nil {
+		formular, err := client.Formular.FindUnique(
+			db.Formular.ID.Equals(formularID),
+		).Exec(ctx)
+		if err != nil {
+			return nil, err
+		}
+		name = &formular.Name
+	}
+
+	known := map[string]SnapshotNode{}
+	if len(nodeIDs) > 0 {
+		stored, err := client.Node.FindMany(
+			db.Node.ID.In(nodeIDs),
+		).Exec(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range stored {
+			known[node.ID] = SnapshotNode{NodeID: node.ID, Name: node.Name, NodeData: node.NodeData}
+		}
+	}
+	for _, node := range pending {
+		known[node.NodeID] = node
+	}
+
+	nodes := make([]SnapshotNode, 0, len(nodeIDs))
+	for _, id := range nodeIDs {
+		node, ok := known[id]
+		if !ok {
+			return nil, fmt.Errorf("version of formular %s: node %s not found", formularID, id)
+		}
+		nodes = append(nodes, node)
+	}
+
+	snapshot, err := json.Marshal(nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	// A concurrent mutation claiming the same version number fails its transaction on the unique
+	// version constraint rather than recording a stale snapshot
+	next := 1
+	latest, err := client.FormularVersion.FindFirst(
+		db.FormularVersion.FormularID.Equals(formularID),
+	).OrderBy(
+		db.FormularVersion.Version.Order(db.SortOrderDesc),
+	).Exec(ctx)
+
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+	case err != nil:
+		return nil, err
+	case latest.Name == *name && latest.Nodes == string(snapshot):
+		return nil, nil
+	default:
+		next = latest.Version + 1
+	}
+
+	return []db.PrismaTransaction{
+		client.FormularVersion.CreateOne(
+			db.FormularVersion.Formular.Link(db.Formular.ID.Equals(formularID)),
+			db.FormularVersion.Version.Set(next),
+			db.FormularVersion.Name.Set(*name),
+			db.FormularVersion.Nodes.Set(string(snapshot)),
+		).Tx(),
+	}, nil
+}
+
+// nodeVersionTxs returns the transactions that record the next version of every formular whose
+// sequence contains a node, with the node in the state a mutation changes it to
+func nodeVersionTxs(ctx context.Context, client *db.PrismaClient, node SnapshotNode) ([]db.PrismaTransaction, error) {
+	usages, err := client.FormularNode.FindMany(
+		db.FormularNode.NodeID.Equals(node.NodeID),
+	).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var txs []db.PrismaTransaction
+	seen := map[string]bool{}
+	for _, usage := range usages {
+		if seen[usage.FormularID] {
+			continue
+		}
+		seen[usage.FormularID] = true
+
+		formularNodes, err := client.FormularNode.FindMany(
+			db.FormularNode.FormularID.Equals(usage.FormularID),
+		).Exec(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		formularNodes = currentOrder(formularNodes, func(fn db.FormularNodeModel) (string, *string) {
+			return fn.ID, fn.InnerFormularNode.NextID
+		})
+		versions, err := versionTxs(ctx, client, usage.FormularID, nil, nodeIDsOf(formularLinks(formularNodes)), node)
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, versions...)
+	}
+	return txs, nil
+}
+
+// nodeIDsOf returns the node IDs of a sequence of formular links, in the given order
+func nodeIDsOf(links []auditLink) []string {
+	ids := make([]string, 0, len(links))
+	for _, link := range links {
+		ids = append(ids, link.NodeID)
+	}
+	return ids
+}
+
+// recordVersion snapshots the stored name and node sequence of a formular as its next version,
+// for formulars whose state predates versioning. Nothing is recorded when the formular is
+// unchanged since its latest version.
+func recordVersion(ctx context.Context, client *db.PrismaClient, formularID string) error {
+	// Concurrent requests may claim the same version number, so retry on a unique conflict
+	for attempt := 0; ; attempt++ {
+		formular, err := client.Formular.FindUnique(
+			db.Formular.ID.Equals(formularID),
+		).With(
+			formularNodesWith(1),
+		).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		orderFormularNodes(formular)
+
+		txs, err := versionTxs(ctx, client, formularID, &formular.Name, nodeIDsOf(formularLinks(formular.Nodes())))
+		if err != nil || len(txs) == 0 {
+			return err
+		}
+
+		err = client.Prisma.Transaction(txs...).Exec(ctx)
+		if !isTxConflict(err) || attempt == 2 {
+			return err
+		}
+	}
+}
+
+// currentVersion records the current state of a formular if it changed and returns its latest version
+func currentVersion(ctx context.Context, client *db.PrismaClient, formularID string) (*db.FormularVersionModel, error) {
+	if err := recordVersion(ctx, client, formularID); err != nil {
+		return nil, err
+	}
+
+	return client.FormularVersion.FindFirst(
+		db.FormularVersion.FormularID.Equals(formularID),
+	).OrderBy(
+		db.FormularVersion.Version.Order(db.SortOrderDesc),
+	).Exec(ctx)
+}
+
+// findVersion fetches a version of the formular in the URL, writing a 404 or 422 when it cannot
+func (h *FormularHandler) findVersion(w http.ResponseWriter, r *http.Request, field, value string) (FormularVersion, bool) {
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 1 {
+		writeValidationError(w, r, []FieldProblem{{Field: field, Message: "must be a positive integer"}})
+		return FormularVersion{}, false
+	}
+
+	version, err := h.db.FormularVersion.FindFirst(
+		db.FormularVersion.FormularID.Equals(chi.URLParam(r, "id")),
+		db.FormularVersion.Version.Equals(number),
+	).Exec(r.Context())
+
+	if err != nil {
+		writeDBError(w, r, err, "FormularVersion")
+		return FormularVersion{}, false
+	}
+
+	decoded, err := newFormularVersion(version)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return FormularVersion{}, false
+	}
+	return decoded, true
+}
+
+// ListVersions godoc
+// @Summary List the versions of a formular
+// @Description Get every recorded version of a formular, newest first. A version is recorded whenever the formular's name or node sequence changes.
+// @Tags formulars
+// @Accept json
+// @Produce json
+// @Param id path string true "Formular ID"
+// @Success 200 {array} FormularVersion
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 404 {object} APIError "Formular not found"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /formulars/{id}/versions [get]
+func (h *FormularHandler) ListVersions(w http.ResponseWriter, r *http.Request) {
+	formularID := chi.URLParam(r, "id")
+
+	if !authorize(w, r, h.db, ResourceFormular, formularID, RoleViewer) {
+		return
+	}
+
+	_, err := h.db.Formular.FindUnique(
+		db.Formular.ID.Equals(formularID),
+	).Exec(r.Context())
+
+	if err != nil {
+		writeDBError(w, r, err, "Formular")
+		return
+	}
+
+	versions, err := h.db.FormularVersion.FindMany(
+		db.FormularVersion.FormularID.Equals(formularID),
+	).OrderBy(
+		db.FormularVersion.Version.Order(db.SortOrderDesc),
+	).Exec(r.Context())
+
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	response := make([]FormularVersion, 0, len(versions))
+	for _, version := range versions {
+		decoded, err := newFormularVersion(&version)
+		if err != nil {
+			writeInternalError(w, r, err)
+			return
+		}
+		response = append(response, decoded)
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetVersion godoc
+// @Summary Get a version of a formular
+// @Description Get a single recorded version of a formular by its version number
+// @Tags formulars
+// @Accept json
+// @Produce json
+// @Param id path string true "Formular ID"
+// @Param version path int true "Version number"
+// @Success 200 {object} FormularVersion
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 404 {object} APIError "Formular or FormularVersion not found"
+// @Failure 422 {object} APIError{details=ValidationError} "Invalid version number"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /formulars/{id}/versions/{version} [get]
+func (h *FormularHandler) GetVersion(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, h.db, ResourceFormular, chi.URLParam(r, "id"), RoleViewer) {
+		return
+	}
+
+	version, ok := h.findVersion(w, r, "version", chi.URLParam(r, "version"))
+	if !ok {
+		return
+	}
+
+	json.NewEncoder(w).Encode(version)
+}
+
+// DiffVersions godoc
+// @Summary Compare two versions of a formular
+// @Description Get the structural difference between two versions of a formular: the name change and the nodes that were added, removed or modified, matched by node ID along the longest common subsequence. A node that moved appears as removed and added.
+// @Tags formulars
+// @Accept json
+// @Produce json
+// @Param id path string true "Formular ID"
+// @Param from query int true "Version number to compare from"
+// @Param to query int true "Version number to compare to"
+// @Success 200 {object} FormularDiff
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 404 {object} APIError "Formular or FormularVersion not found"
+// @Failure 422 {object} APIError{details=ValidationError} "Invalid version numbers"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /formulars/{id}/versions/diff [get]
+func (h *FormularHandler) DiffVersions(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, h.db, ResourceFormular, chi.URLParam(r, "id"), RoleViewer) {
+		return
+	}
+
+	from, ok := h.findVersion(w, r, "from", r.URL.Query().Get("from"))
+	if !ok {
+		return
+	}
+	to, ok := h.findVersion(w, r, "to", r.URL.Query().Get("to"))
+	if !ok {
+		return
+	}
+
+	json.NewEncoder(w).Encode(diffVersions(from, to))
+}
+
+// diffVersions compares two versions, aligning their node sequences by node ID
+func diffVersions(from, to FormularVersion) FormularDiff {
+	diff := FormularDiff{From: from.Version, To: to.Version, Nodes: []NodeChange{}}
+	if from.Name != to.Name {
+		diff.Name = &NameChange{From: from.Name, To: to.Name}
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of from.Nodes[i:] and to.Nodes[j:]
+	a, b := from.Nodes, to.Nodes
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i].NodeID == b[j].NodeID {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		fromPosition, toPosition := i, j
+		switch {
+		case i < len(a) && j < len(b) && a[i].NodeID == b[j].NodeID:
+			if a[i] != b[j] {
+				diff.Nodes = append(diff.Nodes, NodeChange{Op: NodeModified, FromPosition: &fromPosition, ToPosition: &toPosition, From: &a[i], To: &b[j]})
+			}
+			i, j = i+1, j+1
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			diff.Nodes = append(diff.Nodes, NodeChange{Op: NodeRemoved, FromPosition: &fromPosition, From: &a[i]})
+			i++
+		default:
+			diff.Nodes = append(diff.Nodes, NodeChange{Op: NodeAdded, ToPosition: &toPosition, To: &b[j]})
+			j++
+		}
+	}
+
+	return diff
+}
+
+// RestoreVersion godoc
+// @Summary Roll a formular back to a version
+// @Description Replace the name and node sequence of a formular with those of a recorded version and record the result as a new version. Nodes that still exist unchanged are reused; nodes that were deleted or changed since are recreated from the snapshot, so other formulars using them are not affected.
+// @Tags formulars
+// @Accept json
+// @Produce json
+// @Param id path string true "Formular ID"
+// @Param version path int true "Version number to roll back to"
+// @Success 200 {object} FormularVersion
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 403 {object} APIError "Insufficient role"
+// @Failure 404 {object} APIError "Formular or FormularVersion not found"
+// @Failure 422 {object} APIError{details=ValidationError} "Invalid version number"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /formulars/{id}/versions/{version}/restore [post]
+func (h *FormularHandler) RestoreVersion(w http.ResponseWriter, r *http.Request) {
+	formularID := chi.URLParam(r, "id")
+
+	if !authorize(w, r, h.db, ResourceFormular, formularID, RoleEditor) {
+		return
+	}
+
+	_, err := h.db.Formular.FindFirst(
+		db.Formular.ID.Equals(formularID),
+		db.Formular.DeletedAt.IsNull(),
+	).Exec(r.Context())
+
+	if err != nil {
+		writeDBError(w, r, err, "Formular")
+		return
+	}
+
+	version, ok := h.findVersion(w, r, "version", chi.URLParam(r, "version"))
+	if !ok {
+		return
+	}
+
+	// Record the state being rolled back from, so it stays in the history
+	current, err := currentVersion(r.Context(), h.db, formularID)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	before, err := newFormularVersion(current)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	nodeIDs := make([]string, 0, len(version.Nodes))
+	for _, node := range version.Nodes {
+		nodeIDs = append(nodeIDs, node.NodeID)
+	}
+
+	existing, err := h.db.Node.FindMany(
+		db.Node.ID.In(nodeIDs),
+		db.Node.DeletedAt.IsNull(),
+	).Exec(r.Context())
+
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	// Clear every next pointer first so the old links can be deleted in any order
+	txs := []db.PrismaTransaction{
+		h.db.FormularNode.FindMany(
+			db.FormularNode.FormularID.Equals(formularID),
+		).Update(
+			db.FormularNode.NextID.SetOptional(nil),
+		).Tx(),
+		h.db.FormularNode.FindMany(
+			db.FormularNode.FormularID.Equals(formularID),
+		).Delete().Tx(),
+		h.db.Formular.FindUnique(
+			db.Formular.ID.Equals(formularID),
+		).Update(
+			db.Formular.Name.Set(version.Name),
+		).Tx(),
+	}
+
+	linkNodeIDs := make([]string, len(version.Nodes))
+	linkIDs := make([]string, len(version.Nodes))
+	for i, node := range version.Nodes {
+		linkIDs[i] = newID()
+
+		unchanged := slices.ContainsFunc(existing, func(n db.NodeModel) bool {
+			return n.ID == node.NodeID && n.Name == node.Name && n.NodeData == node.NodeData
+		})
+		if unchanged {
+			linkNodeIDs[i] = node.NodeID
+			continue
+		}
+
+		linkNodeIDs[i] = newID()
+		txs = append(txs, h.db.Node.CreateOne(
+			db.Node.Name.Set(node.Name),
+			db.Node.Workspace.Link(db.Workspace.ID.Equals(workspaceID(r))),
+			db.Node.NodeData.Set(node.NodeData),
+			db.Node.ID.Set(linkNodeIDs[i]),
+		).Tx(), grantOwner(h.db, r, ResourceNode, linkNodeIDs[i]))
+	}
+
+	// Create the links from the tail backwards so every next pointer refers to an existing row
+	for i := len(linkIDs) - 1; i >= 0; i-- {
+		params := []db.FormularNodeSetParam{db.FormularNode.ID.Set(linkIDs[i])}
+		if i < len(linkIDs)-1 {
+			params = append(params, db.FormularNode.Next.Link(db.FormularNode.ID.Equals(linkIDs[i+1])))
+		}
+
+		txs = append(txs, h.db.FormularNode.CreateOne(
+			db.FormularNode.Formular.Link(db.Formular.ID.Equals(formularID)),
+			db.FormularNode.Node.Link(db.Node.ID.Equals(linkNodeIDs[i])),
+			params...,
+		).Tx())
+	}
+
+	// The rolled back formular matches the version, except for nodes that had to be recreated
+	after := version
+	after.Nodes = slices.Clone(version.Nodes)
+	for i := range after.Nodes {
+		after.Nodes[i].NodeID = linkNodeIDs[i]
+	}
+	auditTx, err := audit(h.db, r, ActionRollback, ResourceFormular, formularID, before, after)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	txs = append(txs, auditTx)
+
+	versions, err := versionTxs(r.Context(), h.db, formularID, &after.Name, linkNodeIDs, after.Nodes...)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	txs = append(txs, versions...)
+
+	if err := h.db.Prisma.Transaction(txs...).Exec(r.Context()); err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	latest, err := currentVersion(r.Context(), h.db, formularID)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	response, err := newFormularVersion(latest)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
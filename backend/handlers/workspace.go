@@ -0,0 +1,529 @@
+package handlers
+
+import (
+	"backend/prisma/db"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// DefaultWorkspace is the workspace of requests that neither are bound to a workspace nor select
+// one, and of the records created before workspaces existed
+const DefaultWorkspace = "default"
+
+// Workspace member roles. Every member may select the workspace, owners also manage its members.
+const (
+	MemberRoleMember = "member"
+	MemberRoleOwner  = "owner"
+)
+
+type workspaceKey struct{}
+
+// WithWorkspace returns a copy of ctx scoped to a workspace
+func WithWorkspace(ctx context.Context, workspaceID string) context.Context {
+	return context.WithValue(ctx, workspaceKey{}, workspaceID)
+}
+
+// workspaceID returns the workspace a request is scoped to
+func workspaceID(r *http.Request) string {
+	if workspaceID, ok := r.Context().Value(workspaceKey{}).(string); ok && workspaceID != "" {
+		return workspaceID
+	}
+	return DefaultWorkspace
+}
+
+// EnsureDefaultWorkspace creates the default workspace if it does not exist yet
+func EnsureDefaultWorkspace(ctx context.Context, client *db.PrismaClient) error {
+	_, err := client.Workspace.UpsertOne(
+		db.Workspace.ID.Equals(DefaultWorkspace),
+	).Create(
+		db.Workspace.Name.Set("Default"),
+		db.Workspace.ID.Set(DefaultWorkspace),
+	).Update().Exec(ctx)
+	return err
+}
+
+// IsMember reports whether a principal may select a workspace with the X-Workspace-ID header.
+// Every principal is a member of the default workspace.
+func IsMember(ctx context.Context, client *db.PrismaClient, workspaceID, principal string) (bool, error) {
+	if workspaceID == DefaultWorkspace {
+		return true, nil
+	}
+
+	_, err := client.WorkspaceMember.FindUnique(
+		db.WorkspaceMember.WorkspaceIDPrincipal(
+			db.WorkspaceMember.WorkspaceID.Equals(workspaceID),
+			db.WorkspaceMember.Principal.Equals(principal),
+		),
+	).Exec(ctx)
+
+	if errors.Is(err, db.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// inWorkspace reports whether a calculation, formular or node belongs to a workspace. Every
+// handler reaches records by ID through authorize, which uses it so that no request can read or
+// change a record of another workspace.
+func inWorkspace(ctx context.Context, client *db.PrismaClient, resource, id, workspaceID string) (bool, error) {
+	var err error
+	switch resource {
+	case ResourceCalculation:
+		_, err = client.Calculation.FindFirst(
+			db.Calculation.ID.Equals(id),
+			db.Calculation.WorkspaceID.Equals(workspaceID),
+		).Exec(ctx)
+	case ResourceFormular:
+		_, err = client.Formular.FindFirst(
+			db.Formular.ID.Equals(id),
+			db.Formular.WorkspaceID.Equals(workspaceID),
+		).Exec(ctx)
+	case ResourceNode:
+		_, err = client.Node.FindFirst(
+			db.Node.ID.Equals(id),
+			db.Node.WorkspaceID.Equals(workspaceID),
+		).Exec(ctx)
+	default:
+		return false, fmt.Errorf("unknown resource %q", resource)
+	}
+
+	if errors.Is(err, db.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// WorkspaceHandler handles HTTP requests for workspaces
+type WorkspaceHandler struct {
+	db *db.PrismaClient
+}
+
+// NewWorkspaceHandler creates a new workspace handler
+func NewWorkspaceHandler(db *db.PrismaClient) *WorkspaceHandler {
+	return &WorkspaceHandler{db: db}
+}
+
+// Routes returns the router for workspace endpoints
+func (h *WorkspaceHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", h.List)
+	r.Post("/", h.Create)
+
+	// Member endpoints
+	r.Get("/{id}/members", h.ListMembers)
+	r.Put("/{id}/members/{principal}", h.AddMember)
+	r.Delete("/{id}/members/{principal}", h.RemoveMember)
+
+	return r
+}
+
+// List godoc
+// @Summary List workspaces
+// @Description Get the workspaces the caller is a member of, which it can select with the X-Workspace-ID header. The default workspace is always included.
+// @Tags workspaces
+// @Accept json
+// @Produce json
+// @Success 200 {array} db.WorkspaceModel
+// @Failure 401 {object} APIError "Authentication required"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /workspaces [get]
+func (h *WorkspaceHandler) List(w http.ResponseWriter, r *http.Request) {
+	workspaces, err := h.db.Workspace.FindMany(
+		db.Workspace.Or(
+			db.Workspace.ID.Equals(DefaultWorkspace),
+			db.Workspace.Members.Some(
+				db.WorkspaceMember.Principal.Equals(principalID(r)),
+			),
+		),
+	).OrderBy(
+		db.Workspace.Name.Order(db.SortOrderAsc),
+	).Exec(r.Context())
+
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(workspaces)
+}
+
+// CreateWorkspaceInput represents the input for creating a workspace
+type CreateWorkspaceInput struct {
+	Name string `json:"name" validate:"required,max=255" example:"Finance"` // The name of the workspace
+}
+
+// Create godoc
+// @Summary Create a workspace
+// @Description Create a new workspace with the caller as its only member and owner
+// @Tags workspaces
+// @Accept json
+// @Produce json
+// @Param workspace body CreateWorkspaceInput true "Workspace to create"
+// @Success 201 {object} db.WorkspaceModel
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 422 {object} APIError{details=ValidationError} "Invalid input"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /workspaces [post]
+func (h *WorkspaceHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var input CreateWorkspaceInput
+	if !decodeInput(w, r, &input) {
+		return
+	}
+
+	now := time.Now()
+	workspace := db.WorkspaceModel{InnerWorkspace: db.InnerWorkspace{
+		ID:        newID(),
+		Name:      input.Name,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}}
+
+	create := h.db.Workspace.CreateOne(
+		db.Workspace.Name.Set(workspace.Name),
+		db.Workspace.ID.Set(workspace.ID),
+		db.Workspace.CreatedAt.Set(now),
+		db.Workspace.UpdatedAt.Set(now),
+	).Tx()
+
+	auditTx, err := auditEvent(h.db, workspace.ID, actor(r), chimiddleware.GetReqID(r.Context()), ActionCreate, ResourceWorkspace, workspace.ID, nil, workspace)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	if err := h.db.Prisma.Transaction(
+		create,
+		h.db.WorkspaceMember.CreateOne(
+			db.WorkspaceMember.Workspace.Link(db.Workspace.ID.Equals(workspace.ID)),
+			db.WorkspaceMember.Principal.Set(principalID(r)),
+			db.WorkspaceMember.Role.Set(MemberRoleOwner),
+		).Tx(),
+		auditTx,
+	).Exec(r.Context()); err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(create.Result())
+}
+
+// authorizeMember checks that the caller is a member of the workspace in the URL with the given
+// role, any role for MemberRoleMember. Workspaces the caller is not a member of are reported as not
+// found, and a member without the role gets a 403.
+func (h *WorkspaceHandler) authorizeMember(w http.ResponseWriter, r *http.Request, role string) bool {
+	id := chi.URLParam(r, "id")
+
+	// Members of the default workspace are implicit and cannot be managed
+	if id == DefaultWorkspace {
+		writeError(w, r, http.StatusNotFound, CodeNotFound, "Workspace not found", nil)
+		return false
+	}
+
+	member, err := h.db.WorkspaceMember.FindUnique(
+		db.WorkspaceMember.WorkspaceIDPrincipal(
+			db.WorkspaceMember.WorkspaceID.Equals(id),
+			db.WorkspaceMember.Principal.Equals(principalID(r)),
+		),
+	).Exec(r.Context())
+
+	if err != nil {
+		writeDBError(w, r, err, "Workspace")
+		return false
+	}
+	if role == MemberRoleOwner && member.Role != MemberRoleOwner {
+		writeError(w, r, http.StatusForbidden, CodeForbidden, "Only the owners of a workspace can manage its members", nil)
+		return false
+	}
+	return true
+}
+
+// ownerGuard returns a transaction that fails when the owner with the given member ID is no longer
+// a member. A change that would leave a workspace without an owner includes it for another owner,
+// so that concurrent changes cannot remove every owner.
+func ownerGuard(client *db.PrismaClient, memberID string) db.PrismaTransaction {
+	return client.WorkspaceMember.FindUnique(
+		db.WorkspaceMember.ID.Equals(memberID),
+	).Update(
+		db.WorkspaceMember.Role.Set(MemberRoleOwner),
+	).Tx()
+}
+
+// keeper returns an owner of a workspace other than the member with the given ID, preferring the
+// caller, or nil when there is none
+func keeper(r *http.Request, members []db.WorkspaceMemberModel, memberID string) *db.WorkspaceMemberModel {
+	var other *db.WorkspaceMemberModel
+	for i, member := range members {
+		if member.ID == memberID || member.Role != MemberRoleOwner {
+			continue
+		}
+		if member.Principal == principalID(r) {
+			return &members[i]
+		}
+		if other == nil {
+			other = &members[i]
+		}
+	}
+	return other
+}
+
+// ListMembers godoc
+// @Summary List the members of a workspace
+// @Description Get the principals that can select a workspace
+// @Tags workspaces
+// @Accept json
+// @Produce json
+// @Param id path string true "Workspace ID"
+// @Success 200 {array} db.WorkspaceMemberModel
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 404 {object} APIError "Workspace not found"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /workspaces/{id}/members [get]
+func (h *WorkspaceHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeMember(w, r, MemberRoleMember) {
+		return
+	}
+
+	members, err := h.db.WorkspaceMember.FindMany(
+		db.WorkspaceMember.WorkspaceID.Equals(chi.URLParam(r, "id")),
+	).OrderBy(
+		db.WorkspaceMember.CreatedAt.Order(db.SortOrderAsc),
+	).Exec(r.Context())
+
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(members)
+}
+
+// AddMember godoc
+// @Summary Add a member to a workspace
+// @Description Let a principal select a workspace, which requires the caller to be an owner of it. Adding a principal that is already a member has no effect unless a role is given, which changes its role. The last owner cannot become a member.
+// @Tags workspaces
+// @Accept json
+// @Produce json
+// @Param id path string true "Workspace ID"
+// @Param principal path string true "Principal ID"
+// @Param role query string false "The role of the member, owners manage the members; defaults to member for new members" Enums(member, owner)
+// @Success 200 {object} db.WorkspaceMemberModel "Already a member"
+// @Success 201 {object} db.WorkspaceMemberModel "Member added"
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 403 {object} APIError "Caller is not an owner of the workspace"
+// @Failure 404 {object} APIError "Workspace not found"
+// @Failure 409 {object} APIError "Workspace must keep at least one owner"
+// @Failure 422 {object} APIError{details=ValidationError} "Invalid role"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /workspaces/{id}/members/{principal} [put]
+func (h *WorkspaceHandler) AddMember(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	principal := chi.URLParam(r, "principal")
+
+	role := r.URL.Query().Get("role")
+	if role != "" && role != MemberRoleMember && role != MemberRoleOwner {
+		writeValidationError(w, r, []FieldProblem{{Field: "role", Message: fmt.Sprintf("must be one of %s, %s", MemberRoleMember, MemberRoleOwner)}})
+		return
+	}
+
+	if !h.authorizeMember(w, r, MemberRoleOwner) {
+		return
+	}
+
+	existing, err := h.db.WorkspaceMember.FindUnique(
+		db.WorkspaceMember.WorkspaceIDPrincipal(
+			db.WorkspaceMember.WorkspaceID.Equals(id),
+			db.WorkspaceMember.Principal.Equals(principal),
+		),
+	).Exec(r.Context())
+
+	if err == nil {
+		if role == "" || role == existing.Role {
+			json.NewEncoder(w).Encode(existing)
+			return
+		}
+		h.changeRole(w, r, existing, role)
+		return
+	}
+	if !errors.Is(err, db.ErrNotFound) {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	if role == "" {
+		role = MemberRoleMember
+	}
+	member := db.WorkspaceMemberModel{InnerWorkspaceMember: db.InnerWorkspaceMember{
+		ID:          newID(),
+		WorkspaceID: id,
+		Principal:   principal,
+		Role:        role,
+		CreatedAt:   time.Now(),
+	}}
+
+	create := h.db.WorkspaceMember.CreateOne(
+		db.WorkspaceMember.Workspace.Link(db.Workspace.ID.Equals(id)),
+		db.WorkspaceMember.Principal.Set(principal),
+		db.WorkspaceMember.ID.Set(member.ID),
+		db.WorkspaceMember.Role.Set(member.Role),
+		db.WorkspaceMember.CreatedAt.Set(member.CreatedAt),
+	).Tx()
+
+	auditTx, err := auditEvent(h.db, id, actor(r), chimiddleware.GetReqID(r.Context()), ActionAddMember, ResourceWorkspace, id, nil, member)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	if err := h.db.Prisma.Transaction(
+		create,
+		auditTx,
+	).Exec(r.Context()); err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(create.Result())
+}
+
+// changeRole changes the role of an existing member, keeping another owner when an owner becomes a
+// member
+func (h *WorkspaceHandler) changeRole(w http.ResponseWriter, r *http.Request, before *db.WorkspaceMemberModel, role string) {
+	var txs []db.PrismaTransaction
+	if before.Role == MemberRoleOwner {
+		members, err := h.db.WorkspaceMember.FindMany(
+			db.WorkspaceMember.WorkspaceID.Equals(before.WorkspaceID),
+		).Exec(r.Context())
+
+		if err != nil {
+			writeInternalError(w, r, err)
+			return
+		}
+
+		owner := keeper(r, members, before.ID)
+		if owner == nil {
+			writeError(w, r, http.StatusConflict, CodeConflict, "Workspace must keep at least one owner", nil)
+			return
+		}
+		txs = append(txs, ownerGuard(h.db, owner.ID))
+	}
+
+	after := *before
+	after.Role = role
+
+	update := h.db.WorkspaceMember.FindUnique(
+		db.WorkspaceMember.ID.Equals(before.ID),
+	).Update(
+		db.WorkspaceMember.Role.Set(role),
+	).Tx()
+
+	auditTx, err := auditEvent(h.db, before.WorkspaceID, actor(r), chimiddleware.GetReqID(r.Context()), ActionAddMember, ResourceWorkspace, before.WorkspaceID, before, after)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	txs = append(txs, update, auditTx)
+	if err := h.db.Prisma.Transaction(txs...).Exec(r.Context()); err != nil {
+		if isTxConflict(err) {
+			writeError(w, r, http.StatusConflict, CodeConflict, "Workspace must keep at least one owner", nil)
+			return
+		}
+		writeInternalError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(update.Result())
+}
+
+// RemoveMember godoc
+// @Summary Remove a member from a workspace
+// @Description Stop a principal from selecting a workspace, which requires the caller to be an owner of it. Credentials bound to the workspace keep working. The last owner, and so the last member, cannot be removed.
+// @Tags workspaces
+// @Accept json
+// @Produce json
+// @Param id path string true "Workspace ID"
+// @Param principal path string true "Principal ID"
+// @Success 204 "No Content"
+// @Failure 401 {object} APIError "Authentication required"
+// @Failure 403 {object} APIError "Caller is not an owner of the workspace"
+// @Failure 404 {object} APIError "Workspace or member not found"
+// @Failure 409 {object} APIError "Workspace must keep at least one owner"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /workspaces/{id}/members/{principal} [delete]
+func (h *WorkspaceHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	principal := chi.URLParam(r, "principal")
+
+	if !h.authorizeMember(w, r, MemberRoleOwner) {
+		return
+	}
+
+	member, err := h.db.WorkspaceMember.FindUnique(
+		db.WorkspaceMember.WorkspaceIDPrincipal(
+			db.WorkspaceMember.WorkspaceID.Equals(id),
+			db.WorkspaceMember.Principal.Equals(principal),
+		),
+	).Exec(r.Context())
+
+	if err != nil {
+		writeDBError(w, r, err, "Member")
+		return
+	}
+
+	members, err := h.db.WorkspaceMember.FindMany(
+		db.WorkspaceMember.WorkspaceID.Equals(id),
+	).Exec(r.Context())
+
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	// Every workspace keeps an owner, and so a member. The removal fails if that owner is removed
+	// concurrently.
+	owner := keeper(r, members, member.ID)
+	if owner == nil {
+		writeError(w, r, http.StatusConflict, CodeConflict, "Workspace must keep at least one owner", nil)
+		return
+	}
+
+	auditTx, err := auditEvent(h.db, id, actor(r), chimiddleware.GetReqID(r.Context()), ActionRemoveMember, ResourceWorkspace, id, member, nil)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	err = h.db.Prisma.Transaction(
+		h.db.WorkspaceMember.FindUnique(
+			db.WorkspaceMember.ID.Equals(member.ID),
+		).Delete().Tx(),
+		ownerGuard(h.db, owner.ID),
+		auditTx,
+	).Exec(r.Context())
+
+	if isTxConflict(err) {
+		writeError(w, r, http.StatusConflict, CodeConflict, "Workspace must keep at least one owner", nil)
+		return
+	}
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,402 @@
+package handlers_test
+
+import (
+	"backend/auth"
+	"backend/handlers"
+	"backend/prisma/db"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/steebchen/prisma-client-go/engine/mock"
+)
+
+// Alice is a member of workspaces a and b and owns a calculation, a formular and a node in each,
+// but her requests select workspace a. The mock client answers only the queries a test expects and
+// panics on any other, so a handler that looks up a record without the workspace filter, or that
+// runs a transaction after the lookup failed, fails the test. Tests call ensure last instead of
+// deferring it, as a deferred ensure would hide that panic.
+const (
+	principal   = "alice"
+	workspaceA  = "a"
+	calculation = "10000000-0000-4000-8000-00000000000a"
+	otherCalc   = "10000000-0000-4000-8000-00000000000b"
+	formular    = "20000000-0000-4000-8000-00000000000a"
+	otherForm   = "20000000-0000-4000-8000-00000000000b"
+	node        = "30000000-0000-4000-8000-00000000000a"
+	otherNode   = "30000000-0000-4000-8000-00000000000b"
+)
+
+// request sends a request as alice in workspace a to a handler's routes
+func request(routes chi.Router, method, target, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	ctx := auth.WithPrincipal(r.Context(), &auth.Principal{ID: principal, Method: auth.MethodAPIKey})
+	r = r.WithContext(handlers.WithWorkspace(ctx, workspaceA))
+
+	w := httptest.NewRecorder()
+	routes.ServeHTTP(w, r)
+	return w
+}
+
+// expectLookup expects the workspace check of a record in workspace a, which finds the records of
+// workspace a only
+func expectLookup(client *db.PrismaClient, m *db.Mock, resource, id string) {
+	found := id == calculation || id == formular || id == node
+
+	switch resource {
+	case handlers.ResourceCalculation:
+		exec := m.Calculation.Expect(client.Calculation.FindFirst(
+			db.Calculation.ID.Equals(id),
+			db.Calculation.WorkspaceID.Equals(workspaceA),
+		))
+		if !found {
+			exec.Errors(db.ErrNotFound)
+			return
+		}
+		exec.Returns(db.CalculationModel{InnerCalculation: db.InnerCalculation{ID: id, Name: "Mine", WorkspaceID: workspaceA}})
+	case handlers.ResourceFormular:
+		exec := m.Formular.Expect(client.Formular.FindFirst(
+			db.Formular.ID.Equals(id),
+			db.Formular.WorkspaceID.Equals(workspaceA),
+		))
+		if !found {
+			exec.Errors(db.ErrNotFound)
+			return
+		}
+		exec.Returns(db.FormularModel{InnerFormular: db.InnerFormular{ID: id, Name: "Mine", WorkspaceID: workspaceA}})
+	case handlers.ResourceNode:
+		exec := m.Node.Expect(client.Node.FindFirst(
+			db.Node.ID.Equals(id),
+			db.Node.WorkspaceID.Equals(workspaceA),
+		))
+		if !found {
+			exec.Errors(db.ErrNotFound)
+			return
+		}
+		exec.Returns(db.NodeModel{InnerNode: db.InnerNode{ID: id, Name: "Mine", WorkspaceID: workspaceA}})
+	}
+
+	expectRole(client, m, resource, id, handlers.RoleOwner)
+}
+
+// expectRole expects the lookup of alice's role on a resource, which she has none on for an empty
+// role
+func expectRole(client *db.PrismaClient, m *db.Mock, resource, id, role string) {
+	exec := m.Permission.Expect(client.Permission.FindUnique(
+		db.Permission.ResourceResourceIDPrincipal(
+			db.Permission.Resource.Equals(resource),
+			db.Permission.ResourceID.Equals(id),
+			db.Permission.Principal.Equals(principal),
+		),
+	))
+	if role == "" {
+		exec.Errors(db.ErrNotFound)
+		return
+	}
+	exec.Returns(db.PermissionModel{InnerPermission: db.InnerPermission{
+		Resource:   resource,
+		ResourceID: id,
+		Principal:  principal,
+		Role:       role,
+	}})
+}
+
+func TestRecordsOfAnotherWorkspaceAreNotFound(t *testing.T) {
+	tests := []struct {
+		name    string
+		routes  func(*db.PrismaClient) chi.Router
+		method  string
+		target  string
+		body    string
+		lookups [][2]string // The resource and ID of every workspace check, in order
+	}{
+		{
+			name:    "get calculation",
+			routes:  func(c *db.PrismaClient) chi.Router { return handlers.NewCalculationHandler(c).Routes() },
+			method:  http.MethodGet,
+			target:  "/" + otherCalc,
+			lookups: [][2]string{{handlers.ResourceCalculation, otherCalc}},
+		},
+		{
+			name:    "evaluate calculation",
+			routes:  func(c *db.PrismaClient) chi.Router { return handlers.NewCalculationHandler(c).Routes() },
+			method:  http.MethodPost,
+			target:  "/" + otherCalc + "/evaluate",
+			lookups: [][2]string{{handlers.ResourceCalculation, otherCalc}},
+		},
+		{
+			name:    "delete calculation",
+			routes:  func(c *db.PrismaClient) chi.Router { return handlers.NewCalculationHandler(c).Routes() },
+			method:  http.MethodDelete,
+			target:  "/" + otherCalc,
+			lookups: [][2]string{{handlers.ResourceCalculation, otherCalc}},
+		},
+		{
+			name:    "permanently delete calculation",
+			routes:  func(c *db.PrismaClient) chi.Router { return handlers.NewCalculationHandler(c).Routes() },
+			method:  http.MethodDelete,
+			target:  "/" + otherCalc + "?permanent=true&mode=cascade",
+			lookups: [][2]string{{handlers.ResourceCalculation, otherCalc}},
+		},
+		{
+			name:    "link formular of another workspace",
+			routes:  func(c *db.PrismaClient) chi.Router { return handlers.NewCalculationHandler(c).Routes() },
+			method:  http.MethodPost,
+			target:  "/" + calculation + "/formulars",
+			body:    `{"formularId":"` + otherForm + `"}`,
+			lookups: [][2]string{{handlers.ResourceCalculation, calculation}, {handlers.ResourceFormular, otherForm}},
+		},
+		{
+			name:    "get formular",
+			routes:  func(c *db.PrismaClient) chi.Router { return handlers.NewFormularHandler(c).Routes() },
+			method:  http.MethodGet,
+			target:  "/" + otherForm,
+			lookups: [][2]string{{handlers.ResourceFormular, otherForm}},
+		},
+		{
+			name:    "delete formular",
+			routes:  func(c *db.PrismaClient) chi.Router { return handlers.NewFormularHandler(c).Routes() },
+			method:  http.MethodDelete,
+			target:  "/" + otherForm,
+			lookups: [][2]string{{handlers.ResourceFormular, otherForm}},
+		},
+		{
+			name:    "link node of another workspace",
+			routes:  func(c *db.PrismaClient) chi.Router { return handlers.NewFormularHandler(c).Routes() },
+			method:  http.MethodPost,
+			target:  "/" + formular + "/nodes",
+			body:    `{"nodeId":"` + otherNode + `"}`,
+			lookups: [][2]string{{handlers.ResourceFormular, formular}, {handlers.ResourceNode, otherNode}},
+		},
+		{
+			name:    "get node",
+			routes:  func(c *db.PrismaClient) chi.Router { return handlers.NewNodeHandler(c).Routes() },
+			method:  http.MethodGet,
+			target:  "/" + otherNode,
+			lookups: [][2]string{{handlers.ResourceNode, otherNode}},
+		},
+		{
+			name:    "update node",
+			routes:  func(c *db.PrismaClient) chi.Router { return handlers.NewNodeHandler(c).Routes() },
+			method:  http.MethodPut,
+			target:  "/" + otherNode,
+			body:    `{"name":"Taken"}`,
+			lookups: [][2]string{{handlers.ResourceNode, otherNode}},
+		},
+		{
+			name:    "delete node",
+			routes:  func(c *db.PrismaClient) chi.Router { return handlers.NewNodeHandler(c).Routes() },
+			method:  http.MethodDelete,
+			target:  "/" + otherNode + "?permanent=true",
+			lookups: [][2]string{{handlers.ResourceNode, otherNode}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, m, ensure := db.NewMock()
+			for _, lookup := range test.lookups {
+				expectLookup(client, m, lookup[0], lookup[1])
+			}
+
+			w := request(test.routes(client), test.method, test.target, test.body)
+
+			if w.Code != http.StatusNotFound {
+				t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusNotFound, w.Body)
+			}
+			ensure(t)
+		})
+	}
+}
+
+func TestListsLeaveOutAnotherWorkspace(t *testing.T) {
+	// Alice has roles in both workspaces, so only the workspace filter of the raw queries keeps b's
+	// records out. They filter her roles with a subquery instead of binding the IDs of every record
+	// she has a role on.
+	filter := func(table string) string {
+		return `FROM "` + table + `" WHERE "id" IN (SELECT "resourceId" FROM "Permission" WHERE "principal" = ? AND "resource" = ?) AND "workspaceId" = ? AND "deletedAt" IS NULL`
+	}
+	expectPage := func(client *db.PrismaClient, m *db.Mock, table, resource, mine string) {
+		*m.Expectations = append(*m.Expectations, mock.Expectation{
+			Query: client.Prisma.QueryRaw(
+				`SELECT "id" `+filter(table)+` ORDER BY "createdAt" ASC, "id" ASC LIMIT `+strconv.Itoa(handlers.DefaultPageLimit+1),
+				principal, resource, workspaceA,
+			).ExtractQuery(),
+			Want: []map[string]any{{"id": mine}},
+		})
+	}
+	expectCount := func(client *db.PrismaClient, m *db.Mock, table, resource string) {
+		*m.Expectations = append(*m.Expectations, mock.Expectation{
+			Query: client.Prisma.QueryRaw(
+				`SELECT COUNT(*) AS "count" `+filter(table),
+				principal, resource, workspaceA,
+			).ExtractQuery(),
+			Want: []map[string]any{{"count": 1}},
+		})
+	}
+
+	tests := []struct {
+		name   string
+		routes func(*db.PrismaClient) chi.Router
+		expect func(*db.PrismaClient, *db.Mock)
+		mine   string
+	}{
+		{
+			name:   "calculations",
+			routes: func(c *db.PrismaClient) chi.Router { return handlers.NewCalculationHandler(c).Routes() },
+			expect: func(client *db.PrismaClient, m *db.Mock) {
+				expectPage(client, m, "Calculation", handlers.ResourceCalculation, calculation)
+				m.Calculation.Expect(client.Calculation.FindMany(
+					db.Calculation.ID.In([]string{calculation}),
+				)).ReturnsMany([]db.CalculationModel{
+					{InnerCalculation: db.InnerCalculation{ID: calculation, Name: "Mine", WorkspaceID: workspaceA}},
+				})
+				expectCount(client, m, "Calculation", handlers.ResourceCalculation)
+			},
+			mine: calculation,
+		},
+		{
+			name:   "formulars",
+			routes: func(c *db.PrismaClient) chi.Router { return handlers.NewFormularHandler(c).Routes() },
+			expect: func(client *db.PrismaClient, m *db.Mock) {
+				expectPage(client, m, "Formular", handlers.ResourceFormular, formular)
+				m.Formular.Expect(client.Formular.FindMany(
+					db.Formular.ID.In([]string{formular}),
+				)).ReturnsMany([]db.FormularModel{
+					{InnerFormular: db.InnerFormular{ID: formular, Name: "Mine", WorkspaceID: workspaceA}},
+				})
+				expectCount(client, m, "Formular", handlers.ResourceFormular)
+			},
+			mine: formular,
+		},
+		{
+			name:   "nodes",
+			routes: func(c *db.PrismaClient) chi.Router { return handlers.NewNodeHandler(c).Routes() },
+			expect: func(client *db.PrismaClient, m *db.Mock) {
+				expectPage(client, m, "Node", handlers.ResourceNode, node)
+				m.Node.Expect(client.Node.FindMany(
+					db.Node.ID.In([]string{node}),
+				)).ReturnsMany([]db.NodeModel{
+					{InnerNode: db.InnerNode{ID: node, Name: "Mine", WorkspaceID: workspaceA}},
+				})
+				expectCount(client, m, "Node", handlers.ResourceNode)
+			},
+			mine: node,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, m, ensure := db.NewMock()
+			test.expect(client, m)
+
+			w := request(test.routes(client), http.MethodGet, "/", "")
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+			}
+			var page struct {
+				Items []struct {
+					ID string `json:"id"`
+				} `json:"items"`
+				Total int `json:"total"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+				t.Fatal(err)
+			}
+			if len(page.Items) != 1 || page.Items[0].ID != test.mine || page.Total != 1 {
+				t.Fatalf("page = %+v, want only %s", page, test.mine)
+			}
+			ensure(t)
+		})
+	}
+}
+
+// expectMember expects the lookup of a principal's membership of workspace a, which it has none of
+// for an empty role
+func expectMember(client *db.PrismaClient, m *db.Mock, member, role string) {
+	exec := m.WorkspaceMember.Expect(client.WorkspaceMember.FindUnique(
+		db.WorkspaceMember.WorkspaceIDPrincipal(
+			db.WorkspaceMember.WorkspaceID.Equals(workspaceA),
+			db.WorkspaceMember.Principal.Equals(member),
+		),
+	))
+	if role == "" {
+		exec.Errors(db.ErrNotFound)
+		return
+	}
+	exec.Returns(db.WorkspaceMemberModel{InnerWorkspaceMember: db.InnerWorkspaceMember{
+		ID:          member + "-membership",
+		WorkspaceID: workspaceA,
+		Principal:   member,
+		Role:        role,
+	}})
+}
+
+func TestManagingMembersRequiresAnOwner(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		target string
+		role   string // Alice's role in workspace a
+		status int
+	}{
+		{"add as member", http.MethodPut, "/a/members/bob", handlers.MemberRoleMember, http.StatusForbidden},
+		{"remove as member", http.MethodDelete, "/a/members/bob", handlers.MemberRoleMember, http.StatusForbidden},
+		{"add as non-member", http.MethodPut, "/a/members/bob", "", http.StatusNotFound},
+		{"remove as non-member", http.MethodDelete, "/a/members/bob", "", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, m, ensure := db.NewMock()
+			expectMember(client, m, principal, test.role)
+
+			w := request(handlers.NewWorkspaceHandler(client).Routes(), test.method, test.target, "")
+
+			if w.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, test.status, w.Body)
+			}
+			ensure(t)
+		})
+	}
+}
+
+func TestLastOwnerCannotLeave(t *testing.T) {
+	members := []db.WorkspaceMemberModel{
+		{InnerWorkspaceMember: db.InnerWorkspaceMember{ID: principal + "-membership", WorkspaceID: workspaceA, Principal: principal, Role: handlers.MemberRoleOwner}},
+		{InnerWorkspaceMember: db.InnerWorkspaceMember{ID: "bob-membership", WorkspaceID: workspaceA, Principal: "bob", Role: handlers.MemberRoleMember}},
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+	}{
+		{"remove", http.MethodDelete, "/a/members/" + principal},
+		{"demote", http.MethodPut, "/a/members/" + principal + "?role=member"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, m, ensure := db.NewMock()
+			// Alice is both the caller and the member to change, so one lookup answers both
+			expectMember(client, m, principal, handlers.MemberRoleOwner)
+			m.WorkspaceMember.Expect(client.WorkspaceMember.FindMany(
+				db.WorkspaceMember.WorkspaceID.Equals(workspaceA),
+			)).ReturnsMany(members)
+
+			w := request(handlers.NewWorkspaceHandler(client).Routes(), test.method, test.target, "")
+
+			if w.Code != http.StatusConflict {
+				t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusConflict, w.Body)
+			}
+			ensure(t)
+		})
+	}
+}
@@ -1,61 +1,200 @@
 package main
 
 import (
+	"backend/auth"
+	"backend/config"
 	"backend/handlers"
 	"backend/middleware"
 	"backend/prisma/db"
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
 )
 
+// The host is left out of the spec so that Swagger UI calls the server it was served from,
+// whatever address it listens on.
+
 // @title           Calculation API
 // @version         1.0
 // @description     API for managing calculations, formulars, and nodes
-// @host            localhost:8081
 // @BasePath        /api
 
+// @securityDefinitions.apikey ApiKeyAuth
+// @in                          header
+// @name                        X-API-Key
+// @description                 A static API key created with cmd/apikey
+
+// @securityDefinitions.apikey BearerAuth
+// @in                          header
+// @name                        Authorization
+// @description                 A JWT signed by a key of the configured JWKS, as "Bearer <token>"
+
 func main() {
-	if err := run(); err != nil {
-		panic(err)
+	// Log JSON lines, including what other packages write through the standard log package
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	slog.SetDefault(logger)
+
+	if err := run(logger); err != nil {
+		logger.Error("server failed", "error", err)
+		os.Exit(1)
 	}
 }
 
-func run() error {
+// run serves the API until SIGINT or SIGTERM, then stops accepting connections and waits for
+// in-flight requests to finish within the shutdown timeout before disconnecting from the database
+func run(logger *slog.Logger) (err error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Initialize Prisma client
-	client := db.NewClient()
+	client := db.NewClient(db.WithDatasourceURL(cfg.DatabaseURL))
 	if err := client.Prisma.Connect(); err != nil {
 		return err
 	}
 
 	defer func() {
-		if err := client.Prisma.Disconnect(); err != nil {
-			panic(err)
+		if disconnectErr := client.Prisma.Disconnect(); disconnectErr != nil {
+			err = errors.Join(err, fmt.Errorf("disconnect: %w", disconnectErr))
 		}
 	}()
 
+	// Records created before workspaces existed belong to the default workspace
+	if err := handlers.EnsureDefaultWorkspace(ctx, client); err != nil {
+		return err
+	}
+
+	// Nodes written before nodeData was versioned do not evaluate until they are upgraded
+	upgraded, invalid, err := handlers.UpgradeNodeData(ctx, client)
+	if err != nil {
+		return fmt.Errorf("upgrade nodeData: %w", err)
+	}
+	if upgraded > 0 {
+		logger.Info("upgraded free-text nodeData to the versioned schema", "nodes", upgraded)
+	}
+	if len(invalid) > 0 {
+		logger.Warn("nodes with invalid nodeData fail to evaluate until they are edited", "nodeIds", invalid)
+	}
+
+	// Records nobody owns, such as those created before permissions were enforced, answer every
+	// request with a 404 until an owner is assigned
+	unowned, err := handlers.UnownedIDs(ctx, client, nil)
+	if err != nil {
+		return err
+	}
+	for resource, ids := range unowned {
+		logger.Warn("records without an owner are invisible to the API, assign one with cmd/claim", "resource", resource, "count", len(ids))
+	}
+
+	// Purge soft-deleted records once they are older than the retention period, until shutdown
+	purgerCtx, cancelPurger := context.WithCancel(ctx)
+	purgerDone := make(chan struct{})
+	go func() {
+		defer close(purgerDone)
+		handlers.NewPurger(client, cfg.Purge.Retention).Start(purgerCtx, cfg.Purge.Interval)
+	}()
+	defer func() {
+		cancelPurger()
+		<-purgerDone
+	}()
+
+	// Authenticate with API keys, and with JWTs when a JWKS file is configured
+	authenticators := []auth.Authenticator{auth.NewAPIKeyAuthenticator(client)}
+	if cfg.Auth.JWKSFile != "" {
+		jwtAuthenticator, err := auth.NewJWTAuthenticator(cfg.Auth.JWKSFile, cfg.Auth.Issuer, cfg.Auth.Audience)
+		if err != nil {
+			return fmt.Errorf("invalid JWT_JWKS_FILE: %w", err)
+		}
+		authenticators = append(authenticators, jwtAuthenticator)
+	}
+
 	// Initialize router
 	r := chi.NewRouter()
 
 	// Setup middleware
-	middleware.Setup(r)
+	middleware.Setup(r, logger, cfg.CORSOrigins)
 
 	// Initialize handlers
 	swaggerHandler := handlers.NewSwaggerHandler()
 	calculationHandler := handlers.NewCalculationHandler(client)
 	formularHandler := handlers.NewFormularHandler(client)
 	nodeHandler := handlers.NewNodeHandler(client)
-	aiHandler := handlers.NewAIHandler()
+	aiHandler := handlers.NewAIHandler(cfg.AI, cfg.Timeouts.AI)
+	auditHandler := handlers.NewAuditHandler(client)
+	workspaceHandler := handlers.NewWorkspaceHandler(client)
+	healthHandler := handlers.NewHealthHandler(client, cfg.AI)
+	metricsHandler := handlers.NewMetricsHandler(client)
 
 	// Mount routes
-	r.Mount("/swagger", swaggerHandler.Routes())
-	r.Mount("/api/calculations", calculationHandler.Routes())
-	r.Mount("/api/formulars", formularHandler.Routes())
-	r.Mount("/api/nodes", nodeHandler.Routes())
-	r.Mount("/api/ai", aiHandler.Routes())
+	healthHandler.Routes(r)
+	r.Get("/metrics", metricsHandler.Metrics)
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.JSON)
+
+		r.Mount("/swagger", swaggerHandler.Routes())
+		r.Group(func(r chi.Router) {
+			r.Use(chimiddleware.Timeout(cfg.Timeouts.Request))
+			r.Use(middleware.Authenticate(authenticators...))
+
+			r.Mount("/api/workspaces", workspaceHandler.Routes())
+
+			// Every other endpoint is scoped to the workspace of the request
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.Workspace(client))
+
+				r.Mount("/api/calculations", calculationHandler.Routes())
+				r.Mount("/api/formulars", formularHandler.Routes())
+				r.Mount("/api/nodes", nodeHandler.Routes())
+				r.Mount("/api/ai", aiHandler.Routes())
+				r.Mount("/api/audit", auditHandler.Routes())
+			})
+		})
+	})
 
 	// Start server
-	fmt.Println("Server running on http://localhost:8080")
-	return http.ListenAndServe(":8080", r)
+	server := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           r,
+		ReadHeaderTimeout: cfg.Timeouts.ReadHeader,
+		ReadTimeout:       cfg.Timeouts.Read,
+		WriteTimeout:      cfg.Timeouts.Write,
+		IdleTimeout:       cfg.Timeouts.Idle,
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelError),
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+	logger.Info("server running", "addr", cfg.Addr)
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	// A second signal kills the process instead of waiting for the drain
+	stop()
+	logger.Info("shutting down, waiting for in-flight requests", "timeout", cfg.Timeouts.Shutdown.String())
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Shutdown)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		server.Close()
+		return fmt.Errorf("shutdown: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,254 @@
+// Package metrics records counters and histograms in memory and writes them in the Prometheus
+// text exposition format. Every metric is registered when it is created and exposed until the
+// process exits.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the upper bounds in seconds of latency histograms, from 5ms to 10s
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// HTTP metrics, keyed by the chi route pattern so that IDs in paths do not create a series each
+var (
+	HTTPRequests = NewCounter(
+		"http_requests_total",
+		"HTTP requests by method, route pattern and status code.",
+		"method", "route", "status",
+	)
+	HTTPRequestDuration = NewHistogram(
+		"http_request_duration_seconds",
+		"HTTP request latency by method and route pattern.",
+		DefaultBuckets,
+		"method", "route",
+	)
+)
+
+// AI provider metrics
+var (
+	AIRequestDuration = NewHistogram(
+		"ai_upstream_request_duration_seconds",
+		"Latency of calls to the AI provider, including failed ones.",
+		[]float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	)
+	AIErrors = NewCounter(
+		"ai_upstream_errors_total",
+		"Failed calls to the AI provider by reason: transport, status, read, decode or empty.",
+		"reason",
+	)
+)
+
+// EngineDuration records the time the evaluation engine spends per operation
+var EngineDuration = NewHistogram(
+	"engine_duration_seconds",
+	"Time spent in the evaluation engine by operation (evaluate, compile) and result (ok, error).",
+	[]float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1},
+	"operation", "result",
+)
+
+// metric is a registered counter or histogram
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []metric
+)
+
+func register(m metric) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, m)
+}
+
+// WriteTo writes every registered metric in the order it was created
+func WriteTo(w io.Writer) error {
+	registryMu.Lock()
+	metrics := slices.Clone(registry)
+	registryMu.Unlock()
+
+	buffered := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(buffered)
+	}
+	return buffered.Flush()
+}
+
+// Counter is a monotonically increasing value per combination of label values
+type Counter struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]*counterSeries
+}
+
+type counterSeries struct {
+	labelValues []string
+	value       float64
+}
+
+// NewCounter creates and registers a counter with the given label names
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{name: name, help: help, labels: labels, series: map[string]*counterSeries{}}
+	register(c)
+	return c
+}
+
+// Inc adds one to the series with the given label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds a non-negative value to the series with the given label values. It panics if the
+// number of label values does not match the label names.
+func (c *Counter) Add(value float64, labelValues ...string) {
+	key := seriesKey(c.name, c.labels, labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	series, ok := c.series[key]
+	if !ok {
+		series = &counterSeries{labelValues: slices.Clone(labelValues)}
+		c.series[key] = series
+	}
+	series.value += value
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	writeHeader(w, c.name, c.help, "counter")
+	for _, key := range sortedKeys(c.series) {
+		series := c.series[key]
+		fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, series.labelValues, "", ""), formatFloat(series.value))
+	}
+}
+
+// Histogram counts observations in cumulative buckets per combination of label values
+type Histogram struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labelValues []string
+	counts      []uint64 // Observations per bucket, not cumulative
+	count       uint64
+	sum         float64
+}
+
+// NewHistogram creates and registers a histogram with the given bucket upper bounds, in
+// increasing order, and label names
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{name: name, help: help, labels: labels, buckets: buckets, series: map[string]*histogramSeries{}}
+	register(h)
+	return h
+}
+
+// Observe records a value in the series with the given label values. It panics if the number of
+// label values does not match the label names.
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	key := seriesKey(h.name, h.labels, labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	series, ok := h.series[key]
+	if !ok {
+		series = &histogramSeries{labelValues: slices.Clone(labelValues), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = series
+	}
+
+	if i, _ := slices.BinarySearch(h.buckets, value); i < len(h.buckets) {
+		series.counts[i]++
+	}
+	series.count++
+	series.sum += value
+}
+
+// Since records the seconds elapsed since start
+func (h *Histogram) Since(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	writeHeader(w, h.name, h.help, "histogram")
+	for _, key := range sortedKeys(h.series) {
+		series := h.series[key]
+
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += series.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, series.labelValues, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, series.labelValues, "le", "+Inf"), series.count)
+
+		labels := formatLabels(h.labels, series.labelValues, "", "")
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, labels, formatFloat(series.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, labels, series.count)
+	}
+}
+
+// seriesKey identifies the series of a combination of label values
+func seriesKey(name string, labels, labelValues []string) string {
+	if len(labels) != len(labelValues) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", name, len(labels), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+func sortedKeys[V any](series map[string]V) []string {
+	keys := make([]string, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func writeHeader(w *bufio.Writer, name, help, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, typ)
+}
+
+// formatLabels formats label pairs as {name="value",...}, followed by an extra pair when
+// extraName is set, or returns an empty string when there are none
+func formatLabels(names, values []string, extraName, extraValue string) string {
+	if extraName != "" {
+		names = append(slices.Clip(names), extraName)
+		values = append(slices.Clip(values), extraValue)
+	}
+	if len(names) == 0 {
+		return ""
+	}
+
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + escape.Replace(values[i]) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
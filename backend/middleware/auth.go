@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"backend/auth"
+	"backend/handlers"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// Authenticate rejects requests that no authenticator accepts with a 401 and attaches the principal
+// of the others to their context. Authenticators are tried in order until one finds credentials it
+// understands, so a request with an invalid API key is rejected even if it also carries a token.
+func Authenticate(authenticators ...auth.Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, authenticator := range authenticators {
+				principal, err := authenticator.Authenticate(r)
+				if errors.Is(err, auth.ErrNoCredentials) {
+					continue
+				}
+				if errors.Is(err, auth.ErrInvalidCredentials) {
+					writeUnauthorized(w, r, "Invalid credentials")
+					return
+				}
+				if err != nil {
+					handlers.RecordError(r.Context(), fmt.Errorf("authenticate: %w", err))
+					writeAuthError(w, r, http.StatusInternalServerError, handlers.CodeInternal, "Internal server error")
+					return
+				}
+
+				handlers.AddLogAttrs(r.Context(), "principal", principal.ID)
+				next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+				return
+			}
+
+			writeUnauthorized(w, r, "Authentication required")
+		})
+	}
+}
+
+// writeUnauthorized writes a 401 that tells the client which credentials are accepted. The reason
+// credentials were rejected is not exposed.
+func writeUnauthorized(w http.ResponseWriter, r *http.Request, message string) {
+	w.Header().Set("WWW-Authenticate", `Bearer, ApiKey header="`+auth.APIKeyHeader+`"`)
+	writeAuthError(w, r, http.StatusUnauthorized, handlers.CodeUnauthorized, message)
+}
+
+// writeAuthError writes an APIError in the format of the handlers
+func writeAuthError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(handlers.APIError{
+		Code:      code,
+		Message:   message,
+		RequestID: chimiddleware.GetReqID(r.Context()),
+	})
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"backend/handlers"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// Logger logs every request as a single line with its request ID, route pattern, status, latency,
+// principal and response size. Handlers log through handlers.Logger, which carries the same request
+// ID. Server errors are logged at error level together with the error the handler recorded with
+// handlers.RecordError, client errors at warn level.
+func Logger(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ctx := handlers.WithLogger(r.Context(), logger.With("requestId", chimiddleware.GetReqID(r.Context())))
+			ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			args := []any{
+				"method", r.Method,
+				"path", r.URL.Path,
+				"route", routePattern(r),
+				"status", status,
+				"latencyMs", float64(time.Since(start).Microseconds()) / 1000,
+				"bytes", ww.BytesWritten(),
+				"remoteAddr", r.RemoteAddr,
+			}
+
+			level := slog.LevelInfo
+			switch {
+			case status >= http.StatusInternalServerError:
+				level = slog.LevelError
+				if err := handlers.RequestError(ctx); err != nil {
+					args = append(args, "error", err)
+				}
+			case status >= http.StatusBadRequest:
+				level = slog.LevelWarn
+			}
+
+			handlers.Logger(ctx).Log(ctx, level, "request", args...)
+		})
+	}
+}
+
+// Recoverer turns a panicking handler into a 500. The panic value is recorded with
+// handlers.RecordError and its stack added to the request's log attributes, so both end up in the
+// JSON access line of Logger, which must run before it. http.ErrAbortHandler is re-raised so that
+// net/http aborts the response as the handler intended.
+func Recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if err, ok := rec.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(rec)
+			}
+
+			handlers.RecordError(r.Context(), fmt.Errorf("panic: %v", rec))
+			handlers.AddLogAttrs(r.Context(), "stack", string(debug.Stack()))
+			if r.Header.Get("Connection") != "Upgrade" {
+				writeAuthError(w, r, http.StatusInternalServerError, handlers.CodeInternal, "Internal server error")
+			}
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"backend/metrics"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// Metrics records the count and latency of every request by method, route pattern and status.
+// Requests that match no route, such as CORS preflights, are recorded as "unmatched".
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		route := routePattern(r)
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		metrics.HTTPRequests.Inc(r.Method, route, strconv.Itoa(status))
+		metrics.HTTPRequestDuration.Since(start, r.Method, route)
+	})
+}
+
+// routePattern returns the chi route pattern a request matched, or "unmatched". The pattern is
+// only known once the router has handled the request.
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+		return rctx.RoutePattern()
+	}
+	return "unmatched"
+}
@@ -1,28 +1,37 @@
 package middleware
 
 import (
+	"log/slog"
 	"net/http"
+	"slices"
 
 	"github.com/go-chi/chi/v5"
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
 )
 
-// Setup configures and returns all middleware handlers
-func Setup(r chi.Router) {
+// Setup configures the middleware of every route. Requests are logged to logger, and browsers may
+// call the API from the given origins, or from any origin if they include "*".
+func Setup(r chi.Router, logger *slog.Logger, corsOrigins []string) {
 	// Apply standard middleware
 	r.Use(chimiddleware.RequestID)
 	r.Use(chimiddleware.RealIP)
-	r.Use(chimiddleware.Logger)
-	r.Use(chimiddleware.Recoverer)
-	r.Use(chimiddleware.AllowContentType("application/json"))
-	r.Use(chimiddleware.SetHeader("Content-Type", "application/json"))
+	r.Use(Logger(logger))
+	r.Use(Metrics)
+	r.Use(Recoverer)
 
 	// Apply CORS middleware
 	r.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Access-Control-Allow-Origin", "*")
+			if slices.Contains(corsOrigins, "*") {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Add("Vary", "Origin")
+				if origin := r.Header.Get("Origin"); slices.Contains(corsOrigins, origin) {
+					w.Header().Set("Access-Control-Allow-Origin", origin)
+				}
+			}
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, Authorization")
+			w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, Authorization, X-API-Key, X-Workspace-ID")
 			if r.Method == "OPTIONS" {
 				w.WriteHeader(http.StatusOK)
 				return
@@ -31,3 +40,11 @@ func Setup(r chi.Router) {
 		})
 	})
 }
+
+// JSON rejects request bodies that are not JSON and marks responses as JSON. It applies to the
+// API and its documentation, but not to the probes, which answer in their own formats.
+func JSON(next http.Handler) http.Handler {
+	return chimiddleware.AllowContentType("application/json")(
+		chimiddleware.SetHeader("Content-Type", "application/json")(next),
+	)
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"backend/auth"
+	"backend/handlers"
+	"backend/prisma/db"
+	"fmt"
+	"net/http"
+)
+
+// WorkspaceHeader is the request header that selects the workspace of a request
+const WorkspaceHeader = "X-Workspace-ID"
+
+// Workspace scopes every request to a single workspace. Credentials bound to a workspace always
+// use it and are rejected with a 403 when the header selects another one. Other principals select
+// a workspace they are a member of with the header, and use the default workspace without it. A
+// workspace the principal is not a member of is reported as not found, so it cannot probe for IDs.
+func Workspace(client *db.PrismaClient) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var bound, principalID string
+			if principal, ok := auth.FromContext(r.Context()); ok {
+				bound, principalID = principal.Workspace, principal.ID
+			}
+			selected := r.Header.Get(WorkspaceHeader)
+
+			workspaceID := handlers.DefaultWorkspace
+			switch {
+			case bound != "":
+				if selected != "" && selected != bound {
+					writeAuthError(w, r, http.StatusForbidden, handlers.CodeForbidden, "Credentials are bound to another workspace")
+					return
+				}
+				workspaceID = bound
+			case selected != "":
+				member, err := handlers.IsMember(r.Context(), client, selected, principalID)
+				if err != nil {
+					handlers.RecordError(r.Context(), fmt.Errorf("workspace: %w", err))
+					writeAuthError(w, r, http.StatusInternalServerError, handlers.CodeInternal, "Internal server error")
+					return
+				}
+				if !member {
+					writeAuthError(w, r, http.StatusNotFound, handlers.CodeNotFound, "Workspace not found")
+					return
+				}
+				workspaceID = selected
+			}
+
+			handlers.AddLogAttrs(r.Context(), "workspace", workspaceID)
+			next.ServeHTTP(w, r.WithContext(handlers.WithWorkspace(r.Context(), workspaceID)))
+		})
+	}
+}
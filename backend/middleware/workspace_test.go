@@ -0,0 +1,79 @@
+package middleware_test
+
+import (
+	"backend/auth"
+	"backend/middleware"
+	"backend/prisma/db"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// serve sends a request as the principal selecting a workspace through the Workspace middleware
+// and reports whether it reached the next handler
+func serve(client *db.PrismaClient, principal *auth.Principal, selected string) (*httptest.ResponseRecorder, bool) {
+	r := httptest.NewRequest(http.MethodGet, "/calculations", nil)
+	r.Header.Set(middleware.WorkspaceHeader, selected)
+	r = r.WithContext(auth.WithPrincipal(r.Context(), principal))
+
+	reached := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	})
+
+	w := httptest.NewRecorder()
+	middleware.Workspace(client)(next).ServeHTTP(w, r)
+	return w, reached
+}
+
+func expectMembership(client *db.PrismaClient, m *db.Mock, workspaceID, principal string, member bool) {
+	exec := m.WorkspaceMember.Expect(client.WorkspaceMember.FindUnique(
+		db.WorkspaceMember.WorkspaceIDPrincipal(
+			db.WorkspaceMember.WorkspaceID.Equals(workspaceID),
+			db.WorkspaceMember.Principal.Equals(principal),
+		),
+	))
+	if !member {
+		exec.Errors(db.ErrNotFound)
+		return
+	}
+	exec.Returns(db.WorkspaceMemberModel{InnerWorkspaceMember: db.InnerWorkspaceMember{
+		WorkspaceID: workspaceID,
+		Principal:   principal,
+	}})
+}
+
+func TestWorkspaceOfNonMemberIsNotFound(t *testing.T) {
+	client, m, ensure := db.NewMock()
+	expectMembership(client, m, "b", "alice", false)
+
+	w, reached := serve(client, &auth.Principal{ID: "alice", Method: auth.MethodJWT}, "b")
+
+	if w.Code != http.StatusNotFound || reached {
+		t.Fatalf("status = %d, reached = %t, want %d before the handler", w.Code, reached, http.StatusNotFound)
+	}
+	ensure(t)
+}
+
+func TestWorkspaceOfMemberIsSelected(t *testing.T) {
+	client, m, ensure := db.NewMock()
+	expectMembership(client, m, "b", "alice", true)
+
+	w, reached := serve(client, &auth.Principal{ID: "alice", Method: auth.MethodJWT}, "b")
+
+	if w.Code != http.StatusOK || !reached {
+		t.Fatalf("status = %d, reached = %t, want the handler", w.Code, reached)
+	}
+	ensure(t)
+}
+
+func TestBoundCredentialsCannotSelectAnotherWorkspace(t *testing.T) {
+	// No expectations, so any query panics: the binding is checked without the database
+	client, _, _ := db.NewMock()
+
+	w, reached := serve(client, &auth.Principal{ID: "ci", Method: auth.MethodAPIKey, Workspace: "a"}, "b")
+
+	if w.Code != http.StatusForbidden || reached {
+		t.Fatalf("status = %d, reached = %t, want %d before the handler", w.Code, reached, http.StatusForbidden)
+	}
+}
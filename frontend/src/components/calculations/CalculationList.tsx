@@ -26,7 +26,7 @@ export function CalculationList() {
   async function loadCalculations() {
     try {
       const response = await api.calculations.list()
-      setCalculations(response.data)
+      setCalculations(response.data.items)
       setError(null)
     } catch (err) {
       setError('Failed to load calculations')
@@ -39,7 +39,7 @@ export function CalculationList() {
   async function loadFormulars() {
     try {
       const response = await api.formulars.list()
-      setFormulars(response.data)
+      setFormulars(response.data.items)
     } catch (err) {
       console.error('Failed to load formulars:', err)
     }
@@ -89,9 +89,9 @@ export function CalculationList() {
     }
   }
 
-  async function handleRemoveFormular(calculationId: string, formularId: string) {
+  async function handleRemoveFormular(calculationId: string, linkId: string) {
     try {
-      await api.calculations.removeFormular(calculationId, formularId)
+      await api.calculations.removeLink(calculationId, linkId)
       await loadCalculations()
     } catch (err) {
       setError('Failed to remove formular')
@@ -181,7 +181,7 @@ export function CalculationList() {
                     variant="ghost"
                     size="sm"
                     onClick={() =>
-                      handleRemoveFormular(calculation.id, calculationFormular.formularId)
+                      handleRemoveFormular(calculation.id, calculationFormular.id)
                     }
                   >
                     Remove
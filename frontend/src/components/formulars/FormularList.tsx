@@ -26,7 +26,7 @@ export function FormularList() {
   async function loadFormulars() {
     try {
       const response = await api.formulars.list()
-      setFormulars(response.data)
+      setFormulars(response.data.items)
       setError(null)
     } catch (err) {
       setError('Failed to load formulars')
@@ -39,7 +39,7 @@ export function FormularList() {
   async function loadNodes() {
     try {
       const response = await api.nodes.list()
-      setNodes(response.data)
+      setNodes(response.data.items)
     } catch (err) {
       console.error('Failed to load nodes:', err)
     }
@@ -89,9 +89,9 @@ export function FormularList() {
     }
   }
 
-  async function handleRemoveNode(formularId: string, nodeId: string) {
+  async function handleRemoveNode(formularId: string, linkId: string) {
     try {
-      await api.formulars.removeNode(formularId, nodeId)
+      await api.formulars.removeLink(formularId, linkId)
       await loadFormulars()
     } catch (err) {
       setError('Failed to remove node')
@@ -181,7 +181,7 @@ export function FormularList() {
                     variant="ghost"
                     size="sm"
                     onClick={() =>
-                      handleRemoveNode(formular.id, formularNode.nodeId)
+                      handleRemoveNode(formular.id, formularNode.id)
                     }
                   >
                     Remove
@@ -1,5 +1,11 @@
 import { useEffect, useState } from 'react'
-import { api, type Node } from '@/lib/api/client'
+import {
+  api,
+  type Node,
+  type NodeData,
+  type NodeFunction,
+  type NodeOperator,
+} from '@/lib/api/client'
 import { Button } from '@/components/ui/button'
 import {
   Dialog,
@@ -23,7 +29,7 @@ export function NodeList() {
   async function loadNodes() {
     try {
       const response = await api.nodes.list()
-      setNodes(response.data)
+      setNodes(response.data.items)
       setError(null)
     } catch (err) {
       setError('Failed to load nodes')
@@ -136,15 +142,56 @@ interface NodeFormProps {
   onSubmit: (name: string, nodeData: string) => void
 }
 
+const nodeTypes: NodeData['type'][] = ['constant', 'operator', 'function', 'variable', 'formular']
+const operators: NodeOperator[] = ['+', '-', '*', '/', '%']
+const functions: NodeFunction[] = ['abs', 'neg', 'floor', 'ceil', 'round', 'min', 'max']
+
+// parseNodeData reads the document of an existing node, falling back to an empty constant
+function parseNodeData(nodeData: string | undefined): NodeData {
+  try {
+    const parsed = JSON.parse(nodeData ?? '')
+    if (parsed && typeof parsed === 'object' && nodeTypes.includes(parsed.type)) {
+      return parsed as NodeData
+    }
+  } catch {
+    // Not a versioned document
+  }
+  return { version: 1, type: 'constant', value: '' }
+}
+
 function NodeForm({ node, onSubmit }: NodeFormProps) {
+  const initial = parseNodeData(node?.nodeData)
   const [name, setName] = useState(node?.name ?? '')
-  const [nodeData, setNodeData] = useState(node?.nodeData ?? '')
+  const [type, setType] = useState<NodeData['type']>(initial.type)
+  const [value, setValue] = useState(initial.type === 'constant' ? initial.value : '')
+  const [operator, setOperator] = useState<NodeOperator>(
+    initial.type === 'operator' ? initial.operator : '+'
+  )
+  const [fn, setFn] = useState<NodeFunction>(initial.type === 'function' ? initial.function : 'abs')
+  const [args, setArgs] = useState(initial.type === 'function' ? initial.args : 1)
+  const [variable, setVariable] = useState(initial.type === 'variable' ? initial.variable : '')
+  const [formularId, setFormularId] = useState(initial.type === 'formular' ? initial.formularId : '')
+
+  function buildNodeData(): NodeData {
+    switch (type) {
+      case 'constant':
+        return { version: 1, type, value: value.trim() }
+      case 'operator':
+        return { version: 1, type, operator }
+      case 'function':
+        return { version: 1, type, function: fn, args }
+      case 'variable':
+        return { version: 1, type, variable: variable.trim() }
+      case 'formular':
+        return { version: 1, type, formularId: formularId.trim() }
+    }
+  }
 
   return (
     <form
       onSubmit={(e) => {
         e.preventDefault()
-        onSubmit(name, nodeData)
+        onSubmit(name, JSON.stringify(buildNodeData()))
       }}
       className="space-y-4"
     >
@@ -159,14 +206,103 @@ function NodeForm({ node, onSubmit }: NodeFormProps) {
         />
       </div>
       <div>
-        <label className="block text-sm font-medium mb-1">Node Data</label>
-        <textarea
-          value={nodeData}
-          onChange={(e) => setNodeData(e.target.value)}
-          className="w-full p-2 border rounded h-32"
-          required
-        />
+        <label className="block text-sm font-medium mb-1">Type</label>
+        <select
+          value={type}
+          onChange={(e) => setType(e.target.value as NodeData['type'])}
+          className="w-full p-2 border rounded"
+        >
+          {nodeTypes.map((nodeType) => (
+            <option key={nodeType} value={nodeType}>
+              {nodeType}
+            </option>
+          ))}
+        </select>
       </div>
+      {type === 'constant' && (
+        <div>
+          <label className="block text-sm font-medium mb-1">Value</label>
+          <input
+            type="text"
+            inputMode="decimal"
+            pattern="-?[0-9]+(\.[0-9]+)?"
+            value={value}
+            onChange={(e) => setValue(e.target.value)}
+            className="w-full p-2 border rounded"
+            required
+          />
+        </div>
+      )}
+      {type === 'operator' && (
+        <div>
+          <label className="block text-sm font-medium mb-1">Operator</label>
+          <select
+            value={operator}
+            onChange={(e) => setOperator(e.target.value as NodeOperator)}
+            className="w-full p-2 border rounded"
+          >
+            {operators.map((symbol) => (
+              <option key={symbol} value={symbol}>
+                {symbol}
+              </option>
+            ))}
+          </select>
+        </div>
+      )}
+      {type === 'function' && (
+        <div className="flex gap-2">
+          <div className="flex-1">
+            <label className="block text-sm font-medium mb-1">Function</label>
+            <select
+              value={fn}
+              onChange={(e) => setFn(e.target.value as NodeFunction)}
+              className="w-full p-2 border rounded"
+            >
+              {functions.map((functionName) => (
+                <option key={functionName} value={functionName}>
+                  {functionName}
+                </option>
+              ))}
+            </select>
+          </div>
+          <div className="w-24">
+            <label className="block text-sm font-medium mb-1">Arguments</label>
+            <input
+              type="number"
+              min={1}
+              value={args}
+              onChange={(e) => setArgs(Number(e.target.value))}
+              className="w-full p-2 border rounded"
+              required
+            />
+          </div>
+        </div>
+      )}
+      {type === 'variable' && (
+        <div>
+          <label className="block text-sm font-medium mb-1">Variable</label>
+          <input
+            type="text"
+            pattern="[A-Za-z_][A-Za-z0-9_]*"
+            value={variable}
+            onChange={(e) => setVariable(e.target.value)}
+            className="w-full p-2 border rounded"
+            required
+          />
+        </div>
+      )}
+      {type === 'formular' && (
+        <div>
+          <label className="block text-sm font-medium mb-1">Formular ID</label>
+          <input
+            type="text"
+            value={formularId}
+            onChange={(e) => setFormularId(e.target.value)}
+            className="w-full p-2 border rounded"
+            required
+          />
+        </div>
+      )}
       <Button type="submit">{node ? 'Update' : 'Create'}</Button>
     </form>
   )
@@ -2,10 +2,18 @@ import axios from 'axios';
 
 const API_BASE_URL = 'http://localhost:8080/api';
 
+// The API rejects unauthenticated requests; set VITE_API_KEY to a key created with cmd/apikey
+const API_KEY = import.meta.env.VITE_API_KEY as string | undefined;
+
+// Requests use the default workspace unless VITE_WORKSPACE_ID selects another one
+const WORKSPACE_ID = import.meta.env.VITE_WORKSPACE_ID as string | undefined;
+
 export const apiClient = axios.create({
   baseURL: API_BASE_URL,
   headers: {
     'Content-Type': 'application/json',
+    ...(API_KEY ? { 'X-API-Key': API_KEY } : {}),
+    ...(WORKSPACE_ID ? { 'X-Workspace-ID': WORKSPACE_ID } : {}),
   },
 });
 
@@ -14,16 +22,32 @@ export interface Node {
   id: string;
   name: string;
   nodeData: string;
+  workspaceId: string;
   createdAt: string;
   updatedAt: string;
+  deletedAt: string | null;
   formularNodes: FormularNode[];
 }
 
+export type NodeOperator = '+' | '-' | '*' | '/' | '%';
+
+export type NodeFunction = 'abs' | 'neg' | 'floor' | 'ceil' | 'round' | 'min' | 'max';
+
+// The versioned document stored as a JSON string in Node.nodeData; constant values are decimal strings
+export type NodeData =
+  | { version: 1; type: 'constant'; value: string }
+  | { version: 1; type: 'operator'; operator: NodeOperator }
+  | { version: 1; type: 'function'; function: NodeFunction; args: number }
+  | { version: 1; type: 'variable'; variable: string }
+  | { version: 1; type: 'formular'; formularId: string };
+
 export interface Formular {
   id: string;
   name: string;
+  workspaceId: string;
   createdAt: string;
   updatedAt: string;
+  deletedAt: string | null;
   nodes: FormularNode[];
   calculationFormulars: CalculationFormular[];
 }
@@ -31,8 +55,10 @@ export interface Formular {
 export interface Calculation {
   id: string;
   name: string;
+  workspaceId: string;
   createdAt: string;
   updatedAt: string;
+  deletedAt: string | null;
   formulars: CalculationFormular[];
 }
 
@@ -52,6 +78,7 @@ export interface CalculationFormular {
   id: string;
   calculationId: string;
   formularId: string;
+  formularVersionId: string | null;
   nextId: string | null;
   createdAt: string;
   updatedAt: string;
@@ -60,49 +87,248 @@ export interface CalculationFormular {
   next: CalculationFormular | null;
 }
 
+// A page of a list endpoint; pass nextCursor as cursor to fetch the following page
+export interface Page<T> {
+  items: T[];
+  nextCursor: string | null;
+  total: number;
+}
+
+export interface ListParams {
+  limit?: number;
+  cursor?: string;
+  name?: string;
+  createdAfter?: string;
+  createdBefore?: string;
+  updatedAfter?: string;
+  updatedBefore?: string;
+  sort?: 'name' | '-name' | 'createdAt' | '-createdAt' | 'updatedAt' | '-updatedAt';
+  includeDeleted?: boolean;
+}
+
+export type DeleteMode = 'restrict' | 'detach' | 'cascade';
+
+// Records are soft deleted unless permanent is set; mode only applies to permanent deletes
+export interface DeleteParams {
+  permanent?: boolean;
+  mode?: DeleteMode;
+}
+
+export interface ApiError {
+  code: string;
+  message: string;
+  details?: unknown;
+  requestId?: string;
+}
+
+export interface SnapshotNode {
+  nodeId: string;
+  name: string;
+  nodeData: string;
+}
+
+export interface FormularVersion {
+  id: string;
+  formularId: string;
+  version: number;
+  name: string;
+  nodes: SnapshotNode[];
+  createdAt: string;
+}
+
+export interface NodeChange {
+  op: 'added' | 'removed' | 'modified';
+  fromPosition: number | null;
+  toPosition: number | null;
+  from: SnapshotNode | null;
+  to: SnapshotNode | null;
+}
+
+export interface FormularDiff {
+  from: number;
+  to: number;
+  name: { from: string; to: string } | null;
+  nodes: NodeChange[];
+}
+
+export interface AuditEvent {
+  id: string;
+  actor: string;
+  requestId: string;
+  action: string;
+  resource: 'calculation' | 'formular' | 'node' | 'workspace';
+  resourceId: string;
+  before: unknown;
+  after: unknown;
+  createdAt: string;
+}
+
+export interface AuditParams {
+  limit?: number;
+  cursor?: string;
+  actor?: string;
+  requestId?: string;
+  action?: string;
+  resource?: AuditEvent['resource'];
+  resourceId?: string;
+  createdAfter?: string;
+  createdBefore?: string;
+}
+
+export type Role = 'viewer' | 'editor' | 'owner';
+
+export interface Permission {
+  id: string;
+  resource: Exclude<AuditEvent['resource'], 'workspace'>;
+  resourceId: string;
+  principal: string;
+  role: Role;
+  createdAt: string;
+  updatedAt: string;
+}
+
+export interface Workspace {
+  id: string;
+  name: string;
+  createdAt: string;
+  updatedAt: string;
+}
+
+export type WorkspaceMemberRole = 'member' | 'owner';
+
+export interface WorkspaceMember {
+  id: string;
+  workspaceId: string;
+  principal: string;
+  role: WorkspaceMemberRole;
+  createdAt: string;
+}
+
+// Sequence members as returned by the list endpoints, ordered from the head
+export interface OrderedFormularNode extends FormularNode {
+  position: number;
+}
+
+export interface OrderedCalculationFormular extends CalculationFormular {
+  position: number;
+  pinned: boolean;
+  pinnedVersion: number | null;
+  latestVersion: number | null;
+}
+
 // API endpoints
 export const api = {
   nodes: {
-    list: () => apiClient.get<Node[]>('/nodes'),
+    list: (params?: ListParams) =>
+      apiClient.get<Page<Node>>('/nodes', { params }),
     get: (id: string) => apiClient.get<Node>(`/nodes/${id}`),
     create: (data: { name: string; nodeData: string }) => 
       apiClient.post<Node>('/nodes', data),
     update: (id: string, data: { name?: string; nodeData?: string }) =>
       apiClient.put<Node>(`/nodes/${id}`, data),
-    delete: (id: string) => apiClient.delete(`/nodes/${id}`),
+    delete: (id: string, params?: DeleteParams) =>
+      apiClient.delete(`/nodes/${id}`, { params }),
+    restore: (id: string) =>
+      apiClient.post<Node>(`/nodes/${id}/restore`),
+    listPermissions: (id: string) =>
+      apiClient.get<Permission[]>(`/nodes/${id}/permissions`),
+    grantPermission: (id: string, principal: string, role: Role) =>
+      apiClient.put<Permission>(`/nodes/${id}/permissions/${encodeURIComponent(principal)}`, { role }),
+    revokePermission: (id: string, principal: string) =>
+      apiClient.delete(`/nodes/${id}/permissions/${encodeURIComponent(principal)}`),
   },
   formulars: {
-    list: () => apiClient.get<Formular[]>('/formulars'),
-    get: (id: string) => apiClient.get<Formular>(`/formulars/${id}`),
+    list: (params?: ListParams) =>
+      apiClient.get<Page<Formular>>('/formulars', { params }),
+    get: (id: string, expand?: string) =>
+      apiClient.get<Formular>(`/formulars/${id}`, { params: { expand } }),
     create: (data: { name: string }) => 
       apiClient.post<Formular>('/formulars', data),
     update: (id: string, data: { name: string }) =>
       apiClient.put<Formular>(`/formulars/${id}`, data),
-    delete: (id: string) => apiClient.delete(`/formulars/${id}`),
+    delete: (id: string, params?: DeleteParams) =>
+      apiClient.delete(`/formulars/${id}`, { params }),
+    restore: (id: string) =>
+      apiClient.post<Formular>(`/formulars/${id}/restore`),
     getNodes: (id: string) => 
-      apiClient.get<FormularNode[]>(`/formulars/${id}/nodes`),
+      apiClient.get<OrderedFormularNode[]>(`/formulars/${id}/nodes`),
     addNode: (id: string, data: { nodeId: string; nextId?: string }) =>
       apiClient.post<FormularNode>(`/formulars/${id}/nodes`, data),
     removeNode: (formularId: string, nodeId: string) =>
       apiClient.delete(`/formulars/${formularId}/nodes/${nodeId}`),
     reorderNodes: (id: string, data: { nodeOrder: string[] }) =>
       apiClient.put(`/formulars/${id}/nodes/reorder`, data),
+    removeLink: (formularId: string, linkId: string) =>
+      apiClient.delete(`/formulars/${formularId}/links/${linkId}`),
+    reorderLinks: (id: string, data: { linkOrder: string[] }) =>
+      apiClient.put(`/formulars/${id}/links/reorder`, data),
+    listVersions: (id: string) =>
+      apiClient.get<FormularVersion[]>(`/formulars/${id}/versions`),
+    getVersion: (id: string, version: number) =>
+      apiClient.get<FormularVersion>(`/formulars/${id}/versions/${version}`),
+    diffVersions: (id: string, from: number, to: number) =>
+      apiClient.get<FormularDiff>(`/formulars/${id}/versions/diff`, { params: { from, to } }),
+    restoreVersion: (id: string, version: number) =>
+      apiClient.post<FormularVersion>(`/formulars/${id}/versions/${version}/restore`),
+    affectedCalculations: (id: string) =>
+      apiClient.get<Calculation[]>(`/formulars/${id}/affected-calculations`),
+    listPermissions: (id: string) =>
+      apiClient.get<Permission[]>(`/formulars/${id}/permissions`),
+    grantPermission: (id: string, principal: string, role: Role) =>
+      apiClient.put<Permission>(`/formulars/${id}/permissions/${encodeURIComponent(principal)}`, { role }),
+    revokePermission: (id: string, principal: string) =>
+      apiClient.delete(`/formulars/${id}/permissions/${encodeURIComponent(principal)}`),
   },
   calculations: {
-    list: () => apiClient.get<Calculation[]>('/calculations'),
-    get: (id: string) => apiClient.get<Calculation>(`/calculations/${id}`),
+    list: (params?: ListParams) =>
+      apiClient.get<Page<Calculation>>('/calculations', { params }),
+    get: (id: string, expand?: string) =>
+      apiClient.get<Calculation>(`/calculations/${id}`, { params: { expand } }),
     create: (data: { name: string }) => 
       apiClient.post<Calculation>('/calculations', data),
     update: (id: string, data: { name: string }) =>
       apiClient.put<Calculation>(`/calculations/${id}`, data),
-    delete: (id: string) => apiClient.delete(`/calculations/${id}`),
+    delete: (id: string, params?: DeleteParams) =>
+      apiClient.delete(`/calculations/${id}`, { params }),
+    restore: (id: string) =>
+      apiClient.post<Calculation>(`/calculations/${id}/restore`),
     getFormulars: (id: string) =>
-      apiClient.get<CalculationFormular[]>(`/calculations/${id}/formulars`),
-    addFormular: (id: string, data: { formularId: string; nextId?: string }) =>
+      apiClient.get<OrderedCalculationFormular[]>(`/calculations/${id}/formulars`),
+    addFormular: (id: string, data: { formularId: string; nextId?: string; afterId?: string; pin?: boolean }) =>
       apiClient.post<CalculationFormular>(`/calculations/${id}/formulars`, data),
     removeFormular: (calculationId: string, formularId: string) =>
       apiClient.delete(`/calculations/${calculationId}/formulars/${formularId}`),
     reorderFormulars: (id: string, data: { formularOrder: string[] }) =>
       apiClient.put(`/calculations/${id}/formulars/reorder`, data),
+    removeLink: (calculationId: string, linkId: string) =>
+      apiClient.delete(`/calculations/${calculationId}/links/${linkId}`),
+    reorderLinks: (id: string, data: { linkOrder: string[] }) =>
+      apiClient.put(`/calculations/${id}/links/reorder`, data),
+    upgradeLink: (calculationId: string, linkId: string) =>
+      apiClient.post<CalculationFormular>(`/calculations/${calculationId}/links/${linkId}/upgrade`),
+    listPermissions: (id: string) =>
+      apiClient.get<Permission[]>(`/calculations/${id}/permissions`),
+    grantPermission: (id: string, principal: string, role: Role) =>
+      apiClient.put<Permission>(`/calculations/${id}/permissions/${encodeURIComponent(principal)}`, { role }),
+    revokePermission: (id: string, principal: string) =>
+      apiClient.delete(`/calculations/${id}/permissions/${encodeURIComponent(principal)}`),
+  },
+  audit: {
+    list: (params?: AuditParams) =>
+      apiClient.get<Page<AuditEvent>>('/audit', { params }),
+  },
+  workspaces: {
+    list: () =>
+      apiClient.get<Workspace[]>('/workspaces'),
+    create: (data: { name: string }) =>
+      apiClient.post<Workspace>('/workspaces', data),
+    listMembers: (id: string) =>
+      apiClient.get<WorkspaceMember[]>(`/workspaces/${id}/members`),
+    addMember: (id: string, principal: string, role?: WorkspaceMemberRole) =>
+      apiClient.put<WorkspaceMember>(`/workspaces/${id}/members/${encodeURIComponent(principal)}`, undefined, {
+        params: { role },
+      }),
+    removeMember: (id: string, principal: string) =>
+      apiClient.delete(`/workspaces/${id}/members/${encodeURIComponent(principal)}`),
   },
 };